@@ -1,85 +1,200 @@
 package main
 
 import (
+	"context"
+	"era/internal/auth"
+	"era/internal/cache"
+	"era/internal/config"
+	"era/internal/cors"
+	"era/internal/events"
 	"era/internal/handlers"
+	"era/internal/health"
+	"era/internal/logging"
+	"era/internal/metrics"
+	"era/internal/models"
 	"era/internal/parser"
+	"era/internal/ratelimit"
 	"era/internal/storage"
-	"log"
+	"era/internal/urlpolicy"
+	"era/internal/webhooks"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 )
 
 func main() {
-	// Get environment variables with defaults
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Settings come from defaults, a YAML config file (-config or
+	// CONFIG_FILE), environment variables and flags, in that order
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
-	
-	dataDir := os.Getenv("DATA_DIR")
-	if dataDir == "" {
-		dataDir = "./pb_data"
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+
+	// Write structured logs in the configured format and level, which were
+	// validated with the rest of the configuration
+	level, _ := logging.ParseLevel(cfg.Log.Level)
+	if err := logging.Setup(os.Stderr, logging.Options{
+		Level:     level,
+		Format:    cfg.Log.Format,
+		RowSample: cfg.Log.RowSample,
+	}); err != nil {
+		fatal("Invalid log settings", err)
+	}
+
+	// Each API key, or IP address for requests without one, gets a budget per
+	// class of route, and each IP address a budget of failed authentications
+	limiter := ratelimit.New(map[string]ratelimit.Budget{
+		ratelimit.ClassRead:   cfg.RateLimits.Read,
+		ratelimit.ClassParse:  cfg.RateLimits.Parse,
+		ratelimit.ClassExport: cfg.RateLimits.Export,
+		ratelimit.ClassAuth:   cfg.RateLimits.Auth,
+	}, cfg.RateLimits.TrustProxy)
+
+	// Parse URLs must match the allowed hosts and may never reach private
+	// addresses unless allowed for development
+	policy := &urlpolicy.Policy{
+		AllowedHosts:        cfg.Parse.AllowedHosts,
+		AllowPrivate:        cfg.Parse.AllowPrivate,
+		MaxRedirects:        cfg.Parse.MaxRedirects,
+		MaxDownloadSize:     int64(cfg.Parse.MaxDownload),
+		MaxDecompressedSize: int64(cfg.Parse.MaxDecompressed),
+	}
+	if len(policy.AllowedHosts) == 0 {
+		slog.Warn("parse.allowed_hosts is unset; parses may fetch any public host")
 	}
 
 	// Ensure data directory exists
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		log.Fatal("Failed to create data directory:", err)
+	if err := os.MkdirAll(cfg.Server.DataDir, 0755); err != nil {
+		fatal("Failed to create data directory", err)
 	}
 
-	// Initialize PocketBase store with data directory
-	store, err := storage.NewPocketBaseStore(dataDir)
+	// Open the PocketBase store, which is migrated and ready when it
+	// returns, then serve its admin UI
+	store, err := storage.NewPocketBaseStore(cfg.Server.DataDir)
 	if err != nil {
-		log.Fatal("Failed to initialize storage:", err)
+		fatal("Failed to initialize storage", err)
+	}
+	// Browsers may call the API from the configured origins and from the
+	// origins stored through the API
+	corsPolicy := cors.New(cors.Config{
+		Origins: cfg.CORS.Origins,
+		Methods: cfg.CORS.Methods,
+		Headers: cfg.CORS.Headers,
+		MaxAge:  cfg.CORS.MaxAge,
+	}, func() ([]string, error) {
+		stored, err := store.GetCORSOrigins()
+		if err != nil {
+			return nil, err
+		}
+		origins := make([]string, 0, len(stored))
+		for _, origin := range stored {
+			origins = append(origins, origin.Origin)
+		}
+		return origins, nil
+	})
+
+	// Browsers may call the PocketBase API only from the configured origins,
+	// with the methods it uses
+	adminCORS := cors.New(cors.Config{
+		Origins: cfg.CORS.Origins,
+		Methods: []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions},
+		MaxAge:  cfg.CORS.MaxAge,
+	}, nil)
+	if err := store.ServeAdmin(cfg.PocketBase.HTTPAddr, adminCORS.Handler); err != nil {
+		fatal("Failed to serve PocketBase admin UI", err)
 	}
 
 	// Initialize parser manager
-	manager, err := parser.NewParserManager(store.GetPocketBase())
+	manager, err := parser.NewParserManager(store, policy, parser.DownloadOptions{
+		UserAgent: cfg.Parse.UserAgent,
+		Timeout:   cfg.Parse.Timeout,
+		TempDir:   cfg.Parse.TempDir,
+	})
 	if err != nil {
-		log.Fatal("Failed to initialize parser manager:", err)
+		fatal("Failed to initialize parser manager", err)
 	}
-	defer manager.Cleanup()
 
-	// Initialize handler
-	countyHandler := handlers.NewCountyHandler(store, manager)
+	// Record parse runs and how fresh each county's results are, labeled by
+	// county for the counties with a county link
+	serverMetrics := metrics.New(func() ([]string, error) {
+		links, err := store.GetAllCountyLinks()
+		if err != nil {
+			return nil, err
+		}
+		counties := make([]string, 0, len(links))
+		for _, link := range links {
+			counties = append(counties, models.CountySlug(link.CountyName))
+		}
+		return counties, nil
+	})
+	manager.OnParse(serverMetrics.ObserveParse)
+	manager.OnIngest(serverMetrics.HandleIngest)
+	links, err := store.GetAllCountyLinks()
+	if err != nil {
+		slog.Error("Error loading county links for metrics", "error", err)
+	}
+	for _, link := range links {
+		county := models.CountySlug(link.CountyName)
+		if latest, err := store.LatestSnapshot(county); err == nil && latest != nil {
+			serverMetrics.SetSnapshot(county, latest.CreatedAt)
+		}
+	}
+
+	// Publish result changes to live subscribers as snapshots are stored
+	broker := events.NewBroker(1000)
+	manager.OnIngest(broker.PublishIngest)
+
+	// Notify partner webhooks of result changes. Webhooks may post to any
+	// public host, but never to internal addresses.
+	webhookPolicy := *policy
+	webhookPolicy.AllowedHosts = nil
+	dispatcher := webhooks.NewDispatcher(store, 4, &webhookPolicy, cfg.Webhooks.DeliveryRetention)
+	manager.OnIngest(dispatcher.HandleIngest)
+
+	// Cache rendered results until a county stores a new snapshot
+	resultCache := cache.New(cfg.Results.CacheMaxAge)
+	manager.OnIngest(resultCache.HandleIngest)
+
+	// Refresh stale counties in the background when their pages are viewed
+	refresher := parser.NewRefresher(manager, cfg.Results.MaxAge)
+
+	serverMetrics.Gauge("webhook_queue_depth", "Webhook deliveries waiting for a worker.", dispatcher.Queued)
+	serverMetrics.Gauge("refresh_jobs_in_flight", "Background refreshes of stale counties running.", refresher.InFlight)
+
+	// Initialize handlers
+	countyHandler := handlers.NewCountyHandler(store, manager, refresher)
+	eventsHandler := handlers.NewEventsHandler(broker)
+	webhookHandler := handlers.NewWebhookHandler(store, dispatcher)
+	apiKeyHandler := handlers.NewAPIKeyHandler(store)
+	rateLimitHandler := handlers.NewRateLimitHandler(limiter)
+	corsHandler := handlers.NewCORSHandler(store, corsPolicy)
+	configHandler := handlers.NewConfigHandler(cfg)
+
+	// Writes need an API key; ADMIN_API_KEY bootstraps the first keys
+	authenticator := auth.New(store, cfg.Auth.AdminAPIKey, limiter)
 
 	// Create mux router
 	mux := http.NewServeMux()
 
-	// Register routes
-	mux.HandleFunc("/api/county-links", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			countyHandler.HandleGetCountyLink(w, r)
-		case http.MethodPost:
-			countyHandler.HandleSaveCountyLink(w, r)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
+	// Register the versioned API and the original unversioned routes
+	v1Routes(countyHandler, eventsHandler, webhookHandler, apiKeyHandler, rateLimitHandler, corsHandler, configHandler, resultCache, authenticator, limiter).Register(mux)
+	legacy := legacyRoutes(countyHandler, eventsHandler, webhookHandler, apiKeyHandler, rateLimitHandler, corsHandler, configHandler, resultCache, authenticator, limiter)
+	mux.Handle("/api/", deprecated(legacy))
 
-	mux.HandleFunc("/api/county-links/{id}", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			countyHandler.HandleGetCountyLink(w, r)
-		case http.MethodPut:
-			countyHandler.HandleUpdateCountyLink(w, r)
-		case http.MethodDelete:
-			countyHandler.HandleDeleteCountyLink(w, r)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
-
-	mux.HandleFunc("/api/county-links/bulk", countyHandler.HandleBulkSaveCountyLinks)
-	mux.HandleFunc("/api/county-links/{id}/parse", countyHandler.HandleParseCountyLink)
-	mux.HandleFunc("/api/bulk-parse/{method}", countyHandler.HandleBulkParseByMethod)
-	mux.HandleFunc("/api/cleanup", countyHandler.HandleCleanupCollections)
-	mux.HandleFunc("/api/county-results/{id}", countyHandler.HandleGetCountyResults)
-	mux.HandleFunc("/api/county-measures/{id}", countyHandler.HandleGetMeasuresHTML)
-	mux.HandleFunc("/api/county-candidates/{id}", countyHandler.HandleGetCandidatesHTML)
-	mux.HandleFunc("/api/parse", countyHandler.HandleDirectParse)
-	mux.HandleFunc("/api/parse/bulk", countyHandler.HandleDirectBulkParse)
-	mux.HandleFunc("/api/parse-and-format", countyHandler.HandleParseAndFormat)
+	// Serve embeddable widgets for partner sites
+	embed := embedRoutes(countyHandler, resultCache, limiter)
+	mux.Handle("/embed/", embed)
 
 	// Add health check endpoint
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -87,9 +202,146 @@ func main() {
 		w.Write([]byte("OK"))
 	})
 
-	// Start server
-	log.Printf("Server starting on :%s...", port)
-	if err := http.ListenAndServe(":"+port, mux); err != nil {
-		log.Fatal(err)
+	// The process is live while its background work keeps moving, and ready
+	// when it can also reach its database, write downloads and store more
+	// results. Stale counties are reported but don't make it unready.
+	liveness := health.NewChecker(cfg.Health.Timeout)
+	liveness.Add(health.Scheduler(dispatcher, refresher))
+	readiness := health.NewChecker(cfg.Health.Timeout)
+	readiness.Add(
+		health.Database(store),
+		health.TempDir(cfg.Parse.TempDir),
+		health.DiskSpace(cfg.Server.DataDir, int64(cfg.Health.MinFreeDisk)),
+		health.Scheduler(dispatcher, refresher),
+		health.Freshness(store, cfg.Health.StaleAfter),
+	)
+	mux.Handle("GET /livez", liveness.Handler())
+	mux.Handle("GET /readyz", readiness.Handler())
+
+	// Label request metrics by the route a request matched
+	routes := metrics.Routes(mux, map[string]*http.ServeMux{
+		"/api/":   legacy,
+		"/embed/": embed,
+	})
+	handler := serverMetrics.Instrument(routes, corsPolicy.Handler(resultCache.InvalidateWrites(authenticator.Identify(mux))))
+
+	// Stop on SIGINT, or the SIGTERM fly.io sends before stopping a machine
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Both addresses are bound before serving starts, so a port in use stops
+	// startup; errors while serving end up in failed
+	failed := make(chan error, 2)
+	var servers []*http.Server
+
+	server := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Server.Port),
+		Handler:           logging.Middleware(handler),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	// End live event streams as soon as shutdown starts, so they don't hold
+	// it up; clients reconnect to another machine
+	server.RegisterOnShutdown(broker.Close)
+	if err := serve(server, failed); err != nil {
+		fatal("Failed to start server", err)
+	}
+	servers = append(servers, server)
+	slog.Info("Server started", "addr", server.Addr)
+
+	// Serve metrics on their own address, kept off the public API. Their
+	// server stops after the others, once parse runs and webhook deliveries
+	// are done, so the shutdown itself can be scraped.
+	var metricsServer *http.Server
+	if cfg.Metrics.Addr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", serverMetrics.Handler())
+		metricsServer = &http.Server{
+			Addr:              cfg.Metrics.Addr,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		if err := serve(metricsServer, failed); err != nil {
+			fatal("Failed to start metrics server", err)
+		}
+		slog.Info("Metrics available", "addr", cfg.Metrics.Addr, "path", "/metrics")
+	}
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		slog.Info("Shutting down", "timeout", cfg.Server.ShutdownTimeout.String())
+	case err := <-failed:
+		slog.Error("Server failed, shutting down", "error", err)
+		exitCode = 1
+	}
+	// A second signal stops the process without waiting
+	stop()
+
+	shutdown(cfg.Server.ShutdownTimeout, servers, metricsServer, manager, refresher, dispatcher, store)
+	os.Exit(exitCode)
+}
+
+// serve binds server's address and serves it in the background, sending
+// failed any error other than the server being shut down
+func serve(server *http.Server, failed chan<- error) error {
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			failed <- fmt.Errorf("%s: %w", server.Addr, err)
+		}
+	}()
+	return nil
+}
+
+// shutdown stops the server in an order that loses no data: requests are
+// given timeout to finish, then parse runs still going are cancelled and
+// discard their snapshots, queued webhooks are delivered while the timeout
+// lasts, and only then are the metrics server and the database closed.
+// metricsServer may be nil.
+func shutdown(timeout time.Duration, servers []*http.Server, metricsServer *http.Server, manager *parser.ParserManager, refresher *parser.Refresher, dispatcher *webhooks.Dispatcher, store *storage.PocketBaseStore) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Stop accepting connections and let in-flight requests finish
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Warn("Requests still running at the shutdown timeout", "addr", server.Addr, "error", err)
+		}
 	}
-} 
\ No newline at end of file
+
+	// Cancel the parses of requests that outlived the timeout and of
+	// background refreshes, waiting for them to discard their snapshots,
+	// then drop the connections left
+	manager.Shutdown()
+	refresher.Wait()
+	for _, server := range servers {
+		server.Close()
+	}
+	slog.Info("Requests and parse runs stopped", "duration_ms", time.Since(start).Milliseconds())
+
+	dispatcher.Close(ctx)
+
+	// The metrics and admin servers get whatever is left of the timeout,
+	// and at least a moment, to finish their requests
+	lastCtx, lastCancel := context.WithTimeout(context.Background(), max(time.Until(start.Add(timeout)), time.Second))
+	defer lastCancel()
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(lastCtx); err != nil {
+			metricsServer.Close()
+		}
+	}
+	if err := store.Close(lastCtx); err != nil {
+		slog.Error("Error closing storage", "error", err)
+	}
+	slog.Info("Shutdown complete", "duration_ms", time.Since(start).Milliseconds())
+}
+
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,731 @@
+package main
+
+import (
+	"era/internal/apfeed"
+	"era/internal/api"
+	"era/internal/auth"
+	"era/internal/cache"
+	"era/internal/config"
+	"era/internal/handlers"
+	"era/internal/i18n"
+	"era/internal/models"
+	"era/internal/ratelimit"
+	"net/http"
+)
+
+// apiInfo describes the versioned API in the OpenAPI document
+var apiInfo = api.Info{
+	Title:       "Election Results API",
+	Version:     "1.0.0",
+	Description: "County election results ingestion, exports and live updates.",
+}
+
+var (
+	exportQuery = []api.Param{
+		{Name: "format", Description: "Output format, defaults to csv", Enum: []string{"csv", "xlsx", "ndjson"}},
+		{Name: "columns", Description: "Comma-separated column list, defaults to all columns"},
+		{Name: "type", Description: "Only candidate or measure results", Enum: []string{"candidate", "measure"}},
+	}
+	// scopedExportQuery adds the election filter to exports not already
+	// scoped to one election
+	scopedExportQuery = append(exportQuery[:len(exportQuery):len(exportQuery)],
+		api.Param{Name: "election_id", Description: "Only this election's results, required for API keys limited to some elections"})
+	exportContentTypes = []string{
+		"text/csv",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"application/x-ndjson",
+	}
+	countyParam  = api.Param{Name: "id", Description: "County slug, e.g. san_mateo"}
+	contestParam = api.Param{Name: "id", Description: "Contest ID, <county>.<contest>"}
+
+	mapChoiceParam = api.Param{Name: "choice", Description: "Shade regions by this choice's share instead of by the leading choice"}
+
+	langParam = api.Param{Name: "lang", Description: "Language, overriding the Accept-Language header", Enum: i18n.Languages}
+	pageQuery = []api.Param{
+		{Name: "template", Description: "Render with this template set instead of the defaults"},
+		{Name: "partner", Description: "Render with the template set assigned to this partner, if any"},
+		langParam,
+	}
+	templateSetParam = api.Param{Name: "name", Description: "Template set name"}
+
+	translationLangParam = api.Param{Name: "lang", Description: "Language of the translation", Enum: i18n.Languages[1:]}
+)
+
+// v1Routes builds the /api/v1 route table
+func v1Routes(county *handlers.CountyHandler, events *handlers.EventsHandler, webhooks *handlers.WebhookHandler, keys *handlers.APIKeyHandler, limits *handlers.RateLimitHandler, origins *handlers.CORSHandler, settings *handlers.ConfigHandler, results *cache.Cache, authn *auth.Authenticator, limiter *ratelimit.Limiter) *api.Router {
+	router := api.NewRouter("/api/v1")
+	router.Guard(authn.Require)
+	router.Limit(limiter.Limit)
+
+	// County links
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/county-links", Tag: "County links",
+		OperationID: "listCountyLinks", Summary: "List county result sources",
+		Response:   []models.CountyLink{},
+		Permission: models.PermissionRead,
+		Handler:    county.HandleGetCountyLink,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPost, Path: "/county-links", Tag: "County links",
+		OperationID: "createCountyLink", Summary: "Add a county result source",
+		Request: models.CountyLink{}, Response: handlers.MessageResponse{}, Status: http.StatusCreated,
+		Permission: models.PermissionLinks,
+		Handler:    county.HandleSaveCountyLink,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPost, Path: "/county-links/bulk", Tag: "County links",
+		OperationID: "createCountyLinks", Summary: "Add several county result sources",
+		Request: []models.CountyLink{}, Response: handlers.BulkSaveResponse{}, Status: http.StatusCreated,
+		Permission: models.PermissionLinks,
+		Handler:    county.HandleBulkSaveCountyLinks,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/county-links/{id}", Tag: "County links",
+		OperationID: "getCountyLink", Summary: "Get a county result source",
+		Response:   models.CountyLink{},
+		Permission: models.PermissionRead,
+		Handler:    county.HandleGetCountyLink,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPut, Path: "/county-links/{id}", Tag: "County links",
+		OperationID: "updateCountyLink", Summary: "Replace a county result source",
+		Request: models.CountyLink{}, Response: handlers.MessageResponse{},
+		Permission: models.PermissionLinks,
+		Handler:    county.HandleUpdateCountyLink,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodDelete, Path: "/county-links/{id}", Tag: "County links",
+		OperationID: "deleteCountyLink", Summary: "Remove a county result source",
+		Response:   handlers.MessageResponse{},
+		Permission: models.PermissionLinks,
+		Handler:    county.HandleDeleteCountyLink,
+	})
+
+	// Parsing
+	router.Handle(api.Route{
+		Method: http.MethodPost, Path: "/county-links/{id}/parse-jobs", Tag: "Parsing",
+		OperationID: "parseCountyLink", Summary: "Parse a county's stored source into a new snapshot",
+		Response:   handlers.ParseResponse{},
+		Permission: models.PermissionParse,
+		Handler:    county.HandleParseCountyLink,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPost, Path: "/parse-jobs", Tag: "Parsing",
+		OperationID: "createParseJob", Summary: "Parse a results URL into a new snapshot",
+		Request: handlers.ParseRequest{}, Response: handlers.ParseResponse{},
+		Permission: models.PermissionParse,
+		Handler:    county.HandleDirectParse,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPost, Path: "/parse-jobs/bulk", Tag: "Parsing",
+		OperationID: "createParseJobs", Summary: "Parse several results URLs",
+		Request: handlers.BulkParseRequest{}, Response: handlers.BulkParseResponse{},
+		Permission: models.PermissionParse,
+		Handler:    county.HandleDirectBulkParse,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPost, Path: "/parse-methods/{method}/parse-jobs", Tag: "Parsing",
+		OperationID: "parseCountyLinksByMethod", Summary: "Parse every stored source using a parse method",
+		PathParams: []api.Param{{Name: "method", Enum: []string{string(models.ParseMethodZIP), string(models.ParseMethodHTML)}}},
+		Response:   handlers.MethodParseResponse{},
+		Permission: models.PermissionParse,
+		Handler:    county.HandleBulkParseByMethod,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPost, Path: "/rendered-results", Tag: "Parsing",
+		OperationID: "parseAndRender", Summary: "Parse a results URL and render it as HTML",
+		Query:   pageQuery,
+		Request: handlers.ParseRequest{}, ContentTypes: []string{"text/html"},
+		Permission: models.PermissionParse,
+		Handler:    county.HandleParseAndFormat,
+	})
+
+	// Results
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/counties/{id}/results", Tag: "Results",
+		OperationID: "getCountyResults", Summary: "Get a county's current results",
+		PathParams: []api.Param{countyParam},
+		Query: []api.Param{
+			{Name: "type", Enum: []string{"candidate", "measure"}},
+			{Name: "election_id", Description: "Only this election's results"},
+			{Name: "contest", Description: "Case-insensitive contest name search"},
+			{Name: "choice", Description: "Case-insensitive choice name search"},
+			{Name: "min_votes", Description: "Only choices with at least this many votes"},
+			{Name: "sort", Description: "Sort order; prefix with - to reverse", Enum: []string{
+				models.SortContest, "-" + models.SortContest,
+				models.SortVotes, "-" + models.SortVotes,
+				models.SortPercentage, "-" + models.SortPercentage,
+			}},
+			{Name: "page", Description: "Page number, starting at 1"},
+			{Name: "per_page", Description: "Page size, defaults to 50, at most 500"},
+			{Name: "cursor", Description: "next_cursor from the previous page; takes precedence over page"},
+			langParam,
+		},
+		Response: handlers.CountyResultsResponse{},
+		Handler:  results.Handler(cache.CountyScope, county.HandleGetCountyResults),
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/county-links/{id}/measures", Tag: "Results",
+		OperationID: "renderCountyMeasures", Summary: "Render a county's latest measure results as HTML",
+		Description:  "Serves the latest stored snapshot. When RESULTS_MAX_AGE is set and the snapshot is older, the county is re-parsed in the background.",
+		Query:        pageQuery,
+		ContentTypes: []string{"text/html"},
+		Handler:      county.RefreshStale(results.Handler(cache.AllCounties, county.HandleGetMeasuresHTML)),
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/county-links/{id}/candidates", Tag: "Results",
+		OperationID: "renderCountyCandidates", Summary: "Render a county's latest candidate results as HTML",
+		Description:  "Serves the latest stored snapshot. When RESULTS_MAX_AGE is set and the snapshot is older, the county is re-parsed in the background.",
+		Query:        pageQuery,
+		ContentTypes: []string{"text/html"},
+		Handler:      county.RefreshStale(results.Handler(cache.AllCounties, county.HandleGetCandidatesHTML)),
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/county-links/{id}/results", Tag: "Results",
+		OperationID: "renderCountyResults", Summary: "Render all of a county's latest results as one HTML page",
+		Description:  "Contests are in ballot order, grouped into federal, state, county, city, school, other and measures sections, with a table of contents linking to each contest by its slug.",
+		Query:        pageQuery,
+		ContentTypes: []string{"text/html"},
+		Handler:      county.RefreshStale(results.Handler(cache.AllCounties, county.HandleGetResultsHTML)),
+	})
+	router.Handle(api.Route{
+		Method: http.MethodDelete, Path: "/results", Tag: "Results",
+		OperationID: "deleteResults", Summary: "Delete every county's results and snapshots",
+		Response:   handlers.CleanupResponse{},
+		Permission: models.PermissionAdmin,
+		Handler:    county.HandleCleanupCollections,
+	})
+
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/search", Tag: "Results",
+		OperationID: "search", Summary: "Search contests and choices across every election and county",
+		Description: "Matches contest names, choice names and descriptions, tolerating typos and partial words. Results are grouped by contest, best match first.",
+		Query: []api.Param{
+			{Name: "q", Description: "Search text", Required: true},
+			{Name: "election_id"},
+			{Name: "county"},
+			{Name: "type", Enum: []string{"candidate", "measure"}},
+			{Name: "limit", Description: "Maximum contests, defaults to 20, at most 100"},
+			langParam,
+		},
+		Response: handlers.SearchResponse{},
+		Handler:  results.Handler(cache.AllCounties, county.HandleSearch),
+	})
+
+	// Exports and feeds
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/elections/{id}/export", Tag: "Exports",
+		OperationID: "exportElection", Summary: "Export an election's results",
+		Query: exportQuery, ContentTypes: exportContentTypes,
+		Permission: models.PermissionExport,
+		Handler:    handlers.ElectionScoped(handlers.ElectionFromPath, results.Private(cache.AllCounties, county.HandleExportElection)),
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/counties/{id}/export", Tag: "Exports",
+		OperationID: "exportCounty", Summary: "Export a county's results",
+		PathParams: []api.Param{countyParam},
+		Query:      scopedExportQuery, ContentTypes: exportContentTypes,
+		Permission: models.PermissionExport,
+		Handler:    handlers.ElectionScoped(handlers.ElectionFromQuery, results.Private(cache.CountyScope, county.HandleExportCounty)),
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/contests/{id}/export", Tag: "Exports",
+		OperationID: "exportContest", Summary: "Export a contest's results",
+		PathParams: []api.Param{contestParam},
+		Query:      scopedExportQuery, ContentTypes: exportContentTypes,
+		Permission: models.PermissionExport,
+		Handler:    handlers.ElectionScoped(handlers.ElectionFromQuery, results.Private(cache.ContestScope, county.HandleExportContest)),
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/elections/{id}/ap-feed", Tag: "Exports",
+		OperationID: "getAPFeed", Summary: "Get an election's results in AP Elections API format",
+		Query: []api.Param{
+			{Name: "level", Description: "Comma-separated reporting unit levels: state, county, precinct"},
+			{Name: "statepostal", Description: "State postal code, defaults to CA"},
+		},
+		Response:   apfeed.Feed{},
+		Permission: models.PermissionExport,
+		Handler:    handlers.ElectionScoped(handlers.ElectionFromPath, results.Private(cache.AllCounties, county.HandleGetAPFeed)),
+	})
+
+	// Charts and maps
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/contests/{id}/chart", Tag: "Charts",
+		OperationID: "getContestChart", Summary: "Render a contest as an SVG bar chart, or a yes/no split bar for measures",
+		PathParams:   []api.Param{contestParam},
+		Query:        []api.Param{{Name: "threshold", Description: "Share of yes votes a measure needs to pass; defaults to 50, or 55 for bonds"}},
+		ContentTypes: []string{"image/svg+xml"},
+		Handler:      results.Handler(cache.ContestScope, county.HandleContestChart),
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/contests/{id}/card.png", Tag: "Charts",
+		OperationID: "getContestCard", Summary: "Render a contest's share image as a 1200x630 PNG",
+		PathParams:   []api.Param{contestParam},
+		ContentTypes: []string{"image/png"},
+		Handler:      results.Handler(cache.ContestScope, county.HandleContestCard),
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/contests/{id}/map", Tag: "Charts",
+		OperationID: "getContestPrecinctMap", Summary: "Render a contest's precinct results as an SVG map",
+		Description:  "Requires bundled precinct boundaries for the contest's county.",
+		PathParams:   []api.Param{contestParam},
+		Query:        []api.Param{mapChoiceParam},
+		ContentTypes: []string{"image/svg+xml"},
+		Handler:      results.Handler(cache.ContestScope, county.HandleContestPrecinctMap),
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/elections/{id}/contests/{contest}/map", Tag: "Charts",
+		OperationID: "getElectionContestMap", Summary: "Render a contest across every county as an SVG map",
+		PathParams: []api.Param{
+			{Name: "id", Description: "Election ID"},
+			{Name: "contest", Description: "Contest slug shared by every county, e.g. president-and-vice-president"},
+		},
+		Query:        []api.Param{mapChoiceParam},
+		ContentTypes: []string{"image/svg+xml"},
+		Handler:      results.Handler(cache.AllCounties, county.HandleElectionContestMap),
+	})
+
+	// Race calls
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/race-calls", Tag: "Race calls",
+		OperationID: "listRaceCalls", Summary: "List race calls",
+		Query:    []api.Param{{Name: "election_id"}},
+		Response: []models.RaceCall{},
+		Handler:  results.Handler(cache.AllCounties, county.HandleGetRaceCalls),
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPut, Path: "/contests/{id}/call", Tag: "Race calls",
+		OperationID: "callRace", Summary: "Call a contest for a choice",
+		Description: "called_by is set to the API key's name and election_id to the election of the contest's results. " +
+			"A client election_id may only choose among those elections; any other value is refused.",
+		PathParams: []api.Param{contestParam},
+		Request:    models.RaceCall{}, Response: models.RaceCall{},
+		Permission: models.PermissionCalls,
+		Handler:    county.HandleSaveRaceCall,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodDelete, Path: "/contests/{id}/call", Tag: "Race calls",
+		OperationID: "retractRaceCall", Summary: "Retract a race call",
+		PathParams: []api.Param{contestParam},
+		Query:      []api.Param{{Name: "election_id", Description: "Election of the call, required when the contest was called in several"}},
+		Response:   handlers.MessageResponse{},
+		Permission: models.PermissionCalls,
+		Handler:    county.HandleDeleteRaceCall,
+	})
+
+	// Live updates
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/events", Tag: "Live updates",
+		OperationID: "streamEvents", Summary: "Stream result changes as Server-Sent Events",
+		Query: []api.Param{
+			{Name: "election_id"},
+			{Name: "county"},
+			{Name: "contest_id"},
+			{Name: "last_event_id", Description: "Replay events after this ID; the Last-Event-ID header takes precedence"},
+		},
+		ContentTypes: []string{"text/event-stream"},
+		Handler:      events.HandleEvents,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/webhooks", Tag: "Live updates",
+		OperationID: "listWebhooks", Summary: "List webhook subscriptions",
+		Response:   []models.Webhook{},
+		Permission: models.PermissionAdmin,
+		Handler:    webhooks.HandleGetWebhooks,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPost, Path: "/webhooks", Tag: "Live updates",
+		OperationID: "createWebhook", Summary: "Subscribe a URL to result changes",
+		Request: models.Webhook{}, Response: models.Webhook{}, Status: http.StatusCreated,
+		Permission: models.PermissionAdmin,
+		Handler:    webhooks.HandleCreateWebhook,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/webhooks/{id}", Tag: "Live updates",
+		OperationID: "getWebhook", Summary: "Get a webhook subscription",
+		Response:   models.Webhook{},
+		Permission: models.PermissionAdmin,
+		Handler:    webhooks.HandleGetWebhook,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPut, Path: "/webhooks/{id}", Tag: "Live updates",
+		OperationID: "updateWebhook", Summary: "Replace a webhook subscription",
+		Request: models.Webhook{}, Response: models.Webhook{},
+		Permission: models.PermissionAdmin,
+		Handler:    webhooks.HandleUpdateWebhook,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodDelete, Path: "/webhooks/{id}", Tag: "Live updates",
+		OperationID: "deleteWebhook", Summary: "Remove a webhook subscription",
+		Response:   handlers.MessageResponse{},
+		Permission: models.PermissionAdmin,
+		Handler:    webhooks.HandleDeleteWebhook,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/webhooks/{id}/deliveries", Tag: "Live updates",
+		OperationID: "listWebhookDeliveries", Summary: "List a webhook's recent delivery attempts",
+		Query:      []api.Param{{Name: "limit", Description: "Maximum entries, defaults to 50"}},
+		Response:   []models.WebhookDelivery{},
+		Permission: models.PermissionAdmin,
+		Handler:    webhooks.HandleGetWebhookDeliveries,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPost, Path: "/webhooks/{id}/pings", Tag: "Live updates",
+		OperationID: "pingWebhook", Summary: "Send a test delivery to a webhook",
+		Response: handlers.PingResponse{}, Status: http.StatusAccepted,
+		Permission: models.PermissionAdmin,
+		Handler:    webhooks.HandleTestWebhook,
+	})
+
+	// Translations
+	router.Handle(api.Route{
+		Method: http.MethodPost, Path: "/translations", Tag: "Translations",
+		OperationID: "importTranslations", Summary: "Import translated contest names and descriptions",
+		Request: []models.ContestTranslation{}, Response: handlers.BulkSaveResponse{}, Status: http.StatusCreated,
+		Permission: models.PermissionTranslate,
+		Handler:    county.HandleBulkSaveTranslations,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/contests/{id}/translations", Tag: "Translations",
+		OperationID: "listContestTranslations", Summary: "List a contest's translations",
+		PathParams: []api.Param{contestParam},
+		Response:   []models.ContestTranslation{},
+		Handler:    county.HandleGetContestTranslations,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPut, Path: "/contests/{id}/translations/{lang}", Tag: "Translations",
+		OperationID: "saveContestTranslation", Summary: "Set a contest's title, description and choice names in a language",
+		PathParams: []api.Param{contestParam, translationLangParam},
+		Request:    models.ContestTranslation{}, Response: models.ContestTranslation{},
+		Permission: models.PermissionTranslate,
+		Handler:    county.HandleSaveContestTranslation,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodDelete, Path: "/contests/{id}/translations/{lang}", Tag: "Translations",
+		OperationID: "deleteContestTranslation", Summary: "Remove a contest's translation",
+		PathParams: []api.Param{contestParam, translationLangParam},
+		Response:   handlers.MessageResponse{},
+		Permission: models.PermissionTranslate,
+		Handler:    county.HandleDeleteContestTranslation,
+	})
+
+	// Template sets
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/template-sets", Tag: "Templates",
+		OperationID: "listTemplateSets", Summary: "List template sets",
+		Response:   []models.TemplateSet{},
+		Permission: models.PermissionRead,
+		Handler:    county.HandleGetTemplateSets,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPost, Path: "/template-sets", Tag: "Templates",
+		OperationID: "createTemplateSet", Summary: "Upload a template set for the HTML results pages",
+		Description: "templates overrides the default candidates, measures, results, head, styles, header, footer and timestamp templates by name. stylesheet is added after the default styles.",
+		Request:     models.TemplateSet{}, Response: models.TemplateSet{}, Status: http.StatusCreated,
+		Permission: models.PermissionAdmin,
+		Handler:    county.HandleCreateTemplateSet,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/template-sets/{name}", Tag: "Templates",
+		OperationID: "getTemplateSet", Summary: "Get a template set",
+		PathParams: []api.Param{templateSetParam},
+		Response:   models.TemplateSet{},
+		Permission: models.PermissionRead,
+		Handler:    county.HandleGetTemplateSet,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPut, Path: "/template-sets/{name}", Tag: "Templates",
+		OperationID: "updateTemplateSet", Summary: "Replace a template set",
+		PathParams: []api.Param{templateSetParam},
+		Request:    models.TemplateSet{}, Response: models.TemplateSet{},
+		Permission: models.PermissionAdmin,
+		Handler:    county.HandleUpdateTemplateSet,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodDelete, Path: "/template-sets/{name}", Tag: "Templates",
+		OperationID: "deleteTemplateSet", Summary: "Delete a template set",
+		PathParams: []api.Param{templateSetParam},
+		Response:   handlers.MessageResponse{},
+		Permission: models.PermissionAdmin,
+		Handler:    county.HandleDeleteTemplateSet,
+	})
+
+	// API keys
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/api-keys", Tag: "API keys",
+		OperationID: "listAPIKeys", Summary: "List API keys",
+		Description: "Keys are listed by prefix; the key itself is only returned when it is created.",
+		Response:    []models.APIKey{},
+		Permission:  models.PermissionAdmin,
+		Handler:     keys.HandleGetAPIKeys,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPost, Path: "/api-keys", Tag: "API keys",
+		OperationID: "createAPIKey", Summary: "Create an API key",
+		Description: "Set either role (admin, editor, data_desk or partner) or scopes (any of read, ingest and admin). elections limits a non-admin key to those elections. The response holds the only copy of key.",
+		Request:     models.APIKey{}, Response: models.APIKey{}, Status: http.StatusCreated,
+		Permission: models.PermissionAdmin,
+		Handler:    keys.HandleCreateAPIKey,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/api-keys/{id}", Tag: "API keys",
+		OperationID: "getAPIKey", Summary: "Get an API key, including when it was last used",
+		Response:   models.APIKey{},
+		Permission: models.PermissionAdmin,
+		Handler:    keys.HandleGetAPIKey,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodDelete, Path: "/api-keys/{id}", Tag: "API keys",
+		OperationID: "deleteAPIKey", Summary: "Revoke an API key",
+		Response:   handlers.MessageResponse{},
+		Permission: models.PermissionAdmin,
+		Handler:    keys.HandleDeleteAPIKey,
+	})
+
+	// CORS origins
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/cors-origins", Tag: "CORS origins",
+		OperationID: "listCORSOrigins", Summary: "List the origins browsers may call the API from",
+		Description: "configured origins come from CORS_ALLOWED_ORIGINS; stored origins can be added and removed here.",
+		Response:    handlers.CORSOrigins{},
+		Permission:  models.PermissionAdmin,
+		Handler:     origins.HandleGetCORSOrigins,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodPost, Path: "/cors-origins", Tag: "CORS origins",
+		OperationID: "createCORSOrigin", Summary: "Allow an origin",
+		Description: "origin is an exact origin such as https://news.example.com, https://*.example.com for any of its subdomains, or *.",
+		Request:     models.CORSOrigin{}, Response: models.CORSOrigin{}, Status: http.StatusCreated,
+		Permission: models.PermissionAdmin,
+		Handler:    origins.HandleCreateCORSOrigin,
+	})
+	router.Handle(api.Route{
+		Method: http.MethodDelete, Path: "/cors-origins/{id}", Tag: "CORS origins",
+		OperationID: "deleteCORSOrigin", Summary: "Stop allowing a stored origin",
+		Response:   handlers.MessageResponse{},
+		Permission: models.PermissionAdmin,
+		Handler:    origins.HandleDeleteCORSOrigin,
+	})
+
+	// Configuration
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/admin/config", Tag: "Admin",
+		OperationID: "getConfig", Summary: "Get the effective settings",
+		Description: "Lists every setting with its value and source: default, file, env or flag. Secrets are redacted.",
+		Response:    []config.Setting{},
+		Permission:  models.PermissionAdmin,
+		Handler:     settings.HandleGetConfig,
+	})
+
+	// Rate limits
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/rate-limits", Tag: "Rate limits",
+		OperationID: "getRateLimits", Summary: "Get the rate limit budgets and each client's usage",
+		Description: "Clients are API keys (key:<id>) or, for requests without a key, IP addresses (ip:<address>). Clients idle for 10 minutes are dropped.",
+		Query:       []api.Param{{Name: "client", Description: "Only this client, e.g. key:<id>"}},
+		Response:    handlers.RateLimitUsage{},
+		Permission:  models.PermissionAdmin,
+		Handler:     limits.HandleGetRateLimits,
+	})
+
+	router.Handle(api.Route{
+		Method: http.MethodGet, Path: "/openapi.json", Tag: "Meta",
+		OperationID: "getOpenAPI", Summary: "This document",
+		ContentTypes: []string{"application/json"},
+		Handler:      router.ServeOpenAPI(apiInfo),
+	})
+
+	return router
+}
+
+// legacyRoutes serves the original unversioned /api routes, kept for
+// existing clients until they move to /api/v1
+func legacyRoutes(county *handlers.CountyHandler, events *handlers.EventsHandler, webhooks *handlers.WebhookHandler, keys *handlers.APIKeyHandler, limits *handlers.RateLimitHandler, origins *handlers.CORSHandler, settings *handlers.ConfigHandler, results *cache.Cache, authn *auth.Authenticator, limiter *ratelimit.Limiter) *http.ServeMux {
+	legacy := http.NewServeMux()
+	require := func(permission string, next http.HandlerFunc) http.HandlerFunc {
+		return authn.Require(permission, limiter.Limit(permission, next))
+	}
+	public := func(next http.HandlerFunc) http.HandlerFunc {
+		return limiter.Limit("", next)
+	}
+
+	legacy.HandleFunc("/api/county-links", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			require(models.PermissionRead, county.HandleGetCountyLink)(w, r)
+		case http.MethodPost:
+			require(models.PermissionLinks, county.HandleSaveCountyLink)(w, r)
+		default:
+			api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	legacy.HandleFunc("/api/county-links/{id}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			require(models.PermissionRead, county.HandleGetCountyLink)(w, r)
+		case http.MethodPut:
+			require(models.PermissionLinks, county.HandleUpdateCountyLink)(w, r)
+		case http.MethodDelete:
+			require(models.PermissionLinks, county.HandleDeleteCountyLink)(w, r)
+		default:
+			api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	legacy.HandleFunc("/api/county-links/bulk", require(models.PermissionLinks, county.HandleBulkSaveCountyLinks))
+	legacy.HandleFunc("/api/county-links/{id}/parse", require(models.PermissionParse, county.HandleParseCountyLink))
+	legacy.HandleFunc("/api/bulk-parse/{method}", require(models.PermissionParse, county.HandleBulkParseByMethod))
+	legacy.HandleFunc("/api/cleanup", require(models.PermissionAdmin, county.HandleCleanupCollections))
+	legacy.HandleFunc("/api/county-results/{id}", public(results.Handler(cache.CountyScope, county.HandleGetCountyResults)))
+	legacy.HandleFunc("/api/county-measures/{id}", public(county.RefreshStale(results.Handler(cache.AllCounties, county.HandleGetMeasuresHTML))))
+	legacy.HandleFunc("/api/county-candidates/{id}", public(county.RefreshStale(results.Handler(cache.AllCounties, county.HandleGetCandidatesHTML))))
+	legacy.HandleFunc("/api/county-page/{id}", public(county.RefreshStale(results.Handler(cache.AllCounties, county.HandleGetResultsHTML))))
+	legacy.HandleFunc("/api/parse", require(models.PermissionParse, county.HandleDirectParse))
+	legacy.HandleFunc("/api/parse/bulk", require(models.PermissionParse, county.HandleDirectBulkParse))
+	legacy.HandleFunc("/api/parse-and-format", require(models.PermissionParse, county.HandleParseAndFormat))
+	legacy.HandleFunc("/api/export/elections/{id}", require(models.PermissionExport, handlers.ElectionScoped(handlers.ElectionFromPath, results.Private(cache.AllCounties, county.HandleExportElection))))
+	legacy.HandleFunc("/api/export/counties/{id}", require(models.PermissionExport, handlers.ElectionScoped(handlers.ElectionFromQuery, results.Private(cache.CountyScope, county.HandleExportCounty))))
+	legacy.HandleFunc("/api/export/contests/{id}", require(models.PermissionExport, handlers.ElectionScoped(handlers.ElectionFromQuery, results.Private(cache.ContestScope, county.HandleExportContest))))
+	legacy.HandleFunc("/api/ap/elections/{id}", require(models.PermissionExport, handlers.ElectionScoped(handlers.ElectionFromPath, results.Private(cache.AllCounties, county.HandleGetAPFeed))))
+	legacy.HandleFunc("/api/race-calls", public(results.Handler(cache.AllCounties, county.HandleGetRaceCalls)))
+	legacy.HandleFunc("/api/events", public(events.HandleEvents))
+	legacy.HandleFunc("/api/search", public(results.Handler(cache.AllCounties, county.HandleSearch)))
+
+	legacy.HandleFunc("/api/contests/{id}/card.png", public(results.Handler(cache.ContestScope, county.HandleContestCard)))
+	legacy.HandleFunc("/api/contests/{id}/call", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut, http.MethodPost:
+			require(models.PermissionCalls, county.HandleSaveRaceCall)(w, r)
+		case http.MethodDelete:
+			require(models.PermissionCalls, county.HandleDeleteRaceCall)(w, r)
+		default:
+			api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	legacy.HandleFunc("/api/webhooks", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			require(models.PermissionAdmin, webhooks.HandleGetWebhooks)(w, r)
+		case http.MethodPost:
+			require(models.PermissionAdmin, webhooks.HandleCreateWebhook)(w, r)
+		default:
+			api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	legacy.HandleFunc("/api/webhooks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			require(models.PermissionAdmin, webhooks.HandleGetWebhook)(w, r)
+		case http.MethodPut:
+			require(models.PermissionAdmin, webhooks.HandleUpdateWebhook)(w, r)
+		case http.MethodDelete:
+			require(models.PermissionAdmin, webhooks.HandleDeleteWebhook)(w, r)
+		default:
+			api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	legacy.HandleFunc("/api/webhooks/{id}/deliveries", require(models.PermissionAdmin, webhooks.HandleGetWebhookDeliveries))
+	legacy.HandleFunc("/api/webhooks/{id}/test", require(models.PermissionAdmin, webhooks.HandleTestWebhook))
+
+	legacy.HandleFunc("/api/translations", require(models.PermissionTranslate, county.HandleBulkSaveTranslations))
+	legacy.HandleFunc("/api/contests/{id}/translations", public(county.HandleGetContestTranslations))
+	legacy.HandleFunc("/api/contests/{id}/translations/{lang}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			require(models.PermissionTranslate, county.HandleSaveContestTranslation)(w, r)
+		case http.MethodDelete:
+			require(models.PermissionTranslate, county.HandleDeleteContestTranslation)(w, r)
+		default:
+			api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	legacy.HandleFunc("/api/template-sets", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			require(models.PermissionRead, county.HandleGetTemplateSets)(w, r)
+		case http.MethodPost:
+			require(models.PermissionAdmin, county.HandleCreateTemplateSet)(w, r)
+		default:
+			api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	legacy.HandleFunc("/api/template-sets/{name}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			require(models.PermissionRead, county.HandleGetTemplateSet)(w, r)
+		case http.MethodPut:
+			require(models.PermissionAdmin, county.HandleUpdateTemplateSet)(w, r)
+		case http.MethodDelete:
+			require(models.PermissionAdmin, county.HandleDeleteTemplateSet)(w, r)
+		default:
+			api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	legacy.HandleFunc("/api/api-keys", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			require(models.PermissionAdmin, keys.HandleGetAPIKeys)(w, r)
+		case http.MethodPost:
+			require(models.PermissionAdmin, keys.HandleCreateAPIKey)(w, r)
+		default:
+			api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	legacy.HandleFunc("/api/admin/config", require(models.PermissionAdmin, settings.HandleGetConfig))
+	legacy.HandleFunc("/api/rate-limits", require(models.PermissionAdmin, limits.HandleGetRateLimits))
+
+	legacy.HandleFunc("/api/cors-origins", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			require(models.PermissionAdmin, origins.HandleGetCORSOrigins)(w, r)
+		case http.MethodPost:
+			require(models.PermissionAdmin, origins.HandleCreateCORSOrigin)(w, r)
+		default:
+			api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	legacy.HandleFunc("/api/cors-origins/{id}", require(models.PermissionAdmin, origins.HandleDeleteCORSOrigin))
+
+	legacy.HandleFunc("/api/api-keys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			require(models.PermissionAdmin, keys.HandleGetAPIKey)(w, r)
+		case http.MethodDelete:
+			require(models.PermissionAdmin, keys.HandleDeleteAPIKey)(w, r)
+		default:
+			api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	legacy.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		api.HTTPError(w, "No route matches "+r.URL.Path, http.StatusNotFound)
+	})
+
+	return legacy
+}
+
+// deprecated marks responses from unversioned routes so clients can find
+// the versioned API
+func deprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", `</api/v1/openapi.json>; rel="service-desc"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// embedRoutes serves the iframe widgets partners embed on their own pages
+func embedRoutes(county *handlers.CountyHandler, results *cache.Cache, limiter *ratelimit.Limiter) *http.ServeMux {
+	embed := http.NewServeMux()
+	embed.HandleFunc("GET /embed/contests/{id}", limiter.Limit("", results.Handler(cache.ContestScope, county.HandleEmbedContest)))
+	embed.HandleFunc("GET /embed/loader.js", limiter.Limit("", county.HandleEmbedLoader))
+	return embed
+}
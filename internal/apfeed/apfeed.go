@@ -0,0 +1,371 @@
+// Package apfeed presents stored results in the shape of the AP Elections
+// API so downstream systems built against AP can consume them unchanged
+package apfeed
+
+import (
+	"era/internal/models"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reporting unit levels
+const (
+	LevelState    = "state"
+	LevelCounty   = "county"
+	LevelPrecinct = "precinct"
+)
+
+// Feed is the top-level AP-style document
+type Feed struct {
+	ElectionID string    `json:"electionID"`
+	Timestamp  time.Time `json:"timestamp"`
+	Races      []Race    `json:"races"`
+}
+
+// Race groups every reporting unit for a single contest
+type Race struct {
+	RaceID         string          `json:"raceID"`
+	OfficeName     string          `json:"officeName"`
+	RaceType       string          `json:"raceType"`
+	StatePostal    string          `json:"statePostal"`
+	LastUpdated    time.Time       `json:"lastUpdated"`
+	ReportingUnits []ReportingUnit `json:"reportingUnits"`
+}
+
+// ReportingUnit holds results for a geography within a race
+type ReportingUnit struct {
+	StatePostal           string      `json:"statePostal"`
+	Level                 string      `json:"level"`
+	ReportingUnitID       string      `json:"reportingunitID"`
+	ReportingUnitName     string      `json:"reportingunitName"`
+	PrecinctsReporting    int         `json:"precinctsReporting"`
+	PrecinctsTotal        int         `json:"precinctsTotal"`
+	PrecinctsReportingPct float64     `json:"precinctsReportingPct"`
+	LastUpdated           time.Time   `json:"lastUpdated"`
+	Candidates            []Candidate `json:"candidates"`
+}
+
+// Candidate is a single choice within a reporting unit
+type Candidate struct {
+	CandidateID string `json:"candidateID"`
+	First       string `json:"first,omitempty"`
+	Last        string `json:"last"`
+	Party       string `json:"party,omitempty"`
+	BallotOrder int    `json:"ballotOrder"`
+	VoteCount   int    `json:"voteCount"`
+	Winner      string `json:"winner,omitempty"`
+}
+
+// Options controls which reporting units are included in a feed
+type Options struct {
+	StatePostal string
+	Levels      map[string]bool
+}
+
+// ParseLevels parses a comma-separated list of reporting unit levels. An
+// empty list selects every level.
+func ParseLevels(spec string) (map[string]bool, error) {
+	levels := map[string]bool{}
+	if strings.TrimSpace(spec) == "" {
+		spec = strings.Join([]string{LevelState, LevelCounty, LevelPrecinct}, ",")
+	}
+	for _, level := range strings.Split(spec, ",") {
+		level = strings.ToLower(strings.TrimSpace(level))
+		switch level {
+		case LevelState, LevelCounty, LevelPrecinct:
+			levels[level] = true
+		case "":
+		default:
+			return nil, fmt.Errorf("invalid reporting unit level: %s", level)
+		}
+	}
+	return levels, nil
+}
+
+// unit accumulates the rows for one reporting unit while a feed is built
+type unit struct {
+	ReportingUnit
+	order   []string
+	choices map[string]*Candidate
+	called  map[string]bool
+	// precincts are tracked per county, and per precinct for counties
+	// rolled up from their precincts, so units don't double count
+	precincts map[string][2]int
+}
+
+func newUnit(level, id, name, statePostal string) *unit {
+	return &unit{
+		ReportingUnit: ReportingUnit{
+			StatePostal:       statePostal,
+			Level:             level,
+			ReportingUnitID:   id,
+			ReportingUnitName: name,
+		},
+		choices:   map[string]*Candidate{},
+		called:    map[string]bool{},
+		precincts: map[string][2]int{},
+	}
+}
+
+// add folds a result row into the unit
+func (u *unit) add(row models.ResultRow, raceID string, winner string) {
+	c, ok := u.choices[row.ChoiceName]
+	if !ok {
+		first, last := "", row.ChoiceName
+		if row.Type != "measure" {
+			first, last = splitName(row.ChoiceName)
+		}
+		c = &Candidate{
+			CandidateID: raceID + "-" + models.ContestSlug(row.ChoiceName),
+			First:       first,
+			Last:        last,
+			Party:       row.Party,
+		}
+		u.choices[row.ChoiceName] = c
+		u.order = append(u.order, row.ChoiceName)
+	}
+	c.VoteCount += row.Votes
+	if winner != "" && winner == row.ChoiceName {
+		u.called[row.ChoiceName] = true
+	}
+
+	u.precincts[row.County+"/"+row.Precinct] = [2]int{row.PrecinctsReporting, row.PrecinctsTotal}
+	if row.Updated.After(u.LastUpdated) {
+		u.LastUpdated = row.Updated
+	}
+}
+
+// finish converts the accumulated unit into its output form
+func (u *unit) finish() ReportingUnit {
+	ru := u.ReportingUnit
+	for _, p := range u.precincts {
+		ru.PrecinctsReporting += p[0]
+		ru.PrecinctsTotal += p[1]
+	}
+	if ru.PrecinctsTotal > 0 {
+		ru.PrecinctsReportingPct = float64(ru.PrecinctsReporting) / float64(ru.PrecinctsTotal) * 100
+	}
+
+	ru.Candidates = make([]Candidate, 0, len(u.order))
+	for i, name := range u.order {
+		c := *u.choices[name]
+		c.BallotOrder = i + 1
+		if u.called[name] {
+			c.Winner = "X"
+		}
+		ru.Candidates = append(ru.Candidates, c)
+	}
+	return ru
+}
+
+// race accumulates all units for one race
+type race struct {
+	Race
+	state     *unit
+	counties  map[string]*unit
+	precincts map[string]*unit
+	// precinctRows are each county's precinct rows, rolled up into the
+	// county unit when the county has no county-level rows
+	precinctRows  map[string][]models.ResultRow
+	precinctOrder []string
+}
+
+// Build assembles an AP-style feed from result rows and race calls.
+// Contests in different counties that share a name and the same set of
+// choices (e.g. President, a state senate district) are treated as one race
+// so they roll up into a single state-level unit. Local ballot measures all
+// have Yes/No choices, so only statewide propositions are merged. Counties
+// reporting a race only by precinct get a county unit summing their
+// precincts, which also counts towards the state unit.
+func Build(electionID string, rows []models.ResultRow, calls map[string]models.RaceCall, opts Options) Feed {
+	raceIDs := raceIDsByContest(rows)
+	races := map[string]*race{}
+	var raceOrder []string
+
+	for _, row := range rows {
+		raceID := raceIDs[row.ContestID]
+		r, ok := races[raceID]
+		if !ok {
+			raceType := "Candidate"
+			if row.Type == "measure" {
+				raceType = "Ballot Measure"
+			}
+			r = &race{
+				Race: Race{
+					RaceID:      raceID,
+					OfficeName:  row.ContestName,
+					RaceType:    raceType,
+					StatePostal: opts.StatePostal,
+				},
+				state:        newUnit(LevelState, opts.StatePostal, opts.StatePostal, opts.StatePostal),
+				counties:     map[string]*unit{},
+				precincts:    map[string]*unit{},
+				precinctRows: map[string][]models.ResultRow{},
+			}
+			races[raceID] = r
+			raceOrder = append(raceOrder, raceID)
+		}
+
+		winner := calls[row.ContestID].Winner
+		if row.Updated.After(r.LastUpdated) {
+			r.LastUpdated = row.Updated
+		}
+
+		if row.Precinct != "" {
+			key := row.County + "/" + row.Precinct
+			u, ok := r.precincts[key]
+			if !ok {
+				u = newUnit(LevelPrecinct, row.County+"-"+models.ContestSlug(row.Precinct), row.Precinct, opts.StatePostal)
+				r.precincts[key] = u
+			}
+			u.add(row, raceID, winner)
+
+			if _, ok := r.precinctRows[row.County]; !ok {
+				r.precinctOrder = append(r.precinctOrder, row.County)
+			}
+			r.precinctRows[row.County] = append(r.precinctRows[row.County], row)
+			continue
+		}
+
+		u, ok := r.counties[row.County]
+		if !ok {
+			u = newUnit(LevelCounty, row.County, countyDisplayName(row.County), opts.StatePostal)
+			r.counties[row.County] = u
+		}
+		u.add(row, raceID, winner)
+		r.state.add(row, raceID, winner)
+	}
+
+	feed := Feed{
+		ElectionID: electionID,
+		Timestamp:  time.Now().UTC(),
+		Races:      make([]Race, 0, len(raceOrder)),
+	}
+	for _, raceID := range raceOrder {
+		r := races[raceID]
+		r.rollUpPrecincts(calls)
+		if opts.Levels[LevelState] && len(r.counties) > 0 {
+			r.ReportingUnits = append(r.ReportingUnits, r.state.finish())
+		}
+		if opts.Levels[LevelCounty] {
+			r.ReportingUnits = append(r.ReportingUnits, finishAll(r.counties)...)
+		}
+		if opts.Levels[LevelPrecinct] {
+			r.ReportingUnits = append(r.ReportingUnits, finishAll(r.precincts)...)
+		}
+		feed.Races = append(feed.Races, r.Race)
+	}
+	return feed
+}
+
+// rollUpPrecincts adds a county unit for every county that reported the
+// race only by precinct, summing its precinct rows into it and the state
+func (r *race) rollUpPrecincts(calls map[string]models.RaceCall) {
+	for _, county := range r.precinctOrder {
+		if _, ok := r.counties[county]; ok {
+			continue
+		}
+		u := newUnit(LevelCounty, county, countyDisplayName(county), r.StatePostal)
+		r.counties[county] = u
+		for _, row := range r.precinctRows[county] {
+			winner := calls[row.ContestID].Winner
+			u.add(row, r.RaceID, winner)
+			r.state.add(row, r.RaceID, winner)
+		}
+	}
+}
+
+// raceIDsByContest maps each contest ID to the race it belongs to. Races
+// spanning several counties are identified by the contest slug, single-county
+// races by their contest ID.
+func raceIDsByContest(rows []models.ResultRow) map[string]string {
+	choices := map[string][]string{}
+	// Precinct rows repeat each choice once per precinct
+	seen := map[string]bool{}
+	var contestOrder []string
+	slugs := map[string]string{}
+	statewide := map[string]bool{}
+	for _, row := range rows {
+		if _, ok := choices[row.ContestID]; !ok {
+			contestOrder = append(contestOrder, row.ContestID)
+			slug := models.ContestSlug(row.ContestName)
+			slugs[row.ContestID] = slug
+			statewide[row.ContestID] = row.Type != "measure" || strings.HasPrefix(slug, "proposition")
+		}
+		if key := row.ContestID + "|" + row.ChoiceName; !seen[key] {
+			seen[key] = true
+			choices[row.ContestID] = append(choices[row.ContestID], row.ChoiceName)
+		}
+	}
+
+	// Group contests that could be the same race
+	keys := map[string]string{}
+	groups := map[string][]string{}
+	for _, contestID := range contestOrder {
+		key := contestID
+		if statewide[contestID] {
+			names := append([]string(nil), choices[contestID]...)
+			sort.Strings(names)
+			key = slugs[contestID] + "|" + strings.Join(names, "|")
+		}
+		keys[contestID] = key
+		groups[key] = append(groups[key], contestID)
+	}
+
+	raceIDs := map[string]string{}
+	usedSlugs := map[string]bool{}
+	for _, contestID := range contestOrder {
+		members := groups[keys[contestID]]
+		if members[0] != contestID {
+			continue // assigned with the first member of its group
+		}
+		raceID := contestID
+		if slug := slugs[contestID]; len(members) > 1 && !usedSlugs[slug] {
+			raceID = slug
+			usedSlugs[slug] = true
+		}
+		for _, member := range members {
+			raceIDs[member] = raceID
+		}
+	}
+	return raceIDs
+}
+
+// finishAll converts units sorted by their ID
+func finishAll(units map[string]*unit) []ReportingUnit {
+	keys := make([]string, 0, len(units))
+	for k := range units {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]ReportingUnit, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, units[k].finish())
+	}
+	return out
+}
+
+// splitName splits a ballot name into first and last names the way AP
+// does. Single-word choices such as "Yes" only have a last name.
+func splitName(name string) (first, last string) {
+	name = strings.TrimSpace(name)
+	idx := strings.LastIndex(name, " ")
+	if idx < 0 {
+		return "", name
+	}
+	return name[:idx], name[idx+1:]
+}
+
+// countyDisplayName turns a county slug back into a readable name
+func countyDisplayName(slug string) string {
+	words := strings.Split(slug, "_")
+	for i, w := range words {
+		if w != "" {
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
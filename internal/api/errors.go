@@ -0,0 +1,77 @@
+// Package api holds the versioned REST API plumbing: the route table, the
+// JSON error envelope and the generated OpenAPI document
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error codes used in the error envelope
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeRateLimited      = "rate_limited"
+	CodeUnavailable      = "unavailable"
+	CodeInternal         = "internal_error"
+)
+
+// Error is the body of every API error response
+type Error struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// ErrorResponse wraps an Error so clients can tell errors from data
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+// CodeForStatus returns the default error code for an HTTP status
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusRequestEntityTooLarge:
+		return CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	default:
+		return CodeInternal
+	}
+}
+
+// WriteError writes an error envelope with the given status
+func WriteError(w http.ResponseWriter, status int, code, message string, details interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Error: Error{
+			Code:    code,
+			Message: message,
+			Details: details,
+		},
+	})
+}
+
+// HTTPError is a drop-in replacement for http.Error that writes the JSON
+// error envelope with the status's default code
+func HTTPError(w http.ResponseWriter, message string, status int) {
+	WriteError(w, status, CodeForStatus(status), message, nil)
+}
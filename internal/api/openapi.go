@@ -0,0 +1,349 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Info describes the API in the OpenAPI document
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Document is an OpenAPI 3 document
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Servers    []Server                         `json:"servers"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+// Server is a base URL for the API
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Components holds the reusable schemas and security schemes
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how clients authenticate
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// securitySchemes are the ways of sending an API key
+var securitySchemes = map[string]*SecurityScheme{
+	"bearerKey": {Type: "http", Scheme: "bearer", Description: "An API key, ADMIN_API_KEY or a PocketBase admin auth token"},
+	"headerKey": {Type: "apiKey", In: "header", Name: "X-API-Key", Description: "An API key or ADMIN_API_KEY"},
+}
+
+// Operation is a single method on a path
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes an operation's request body
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response describes a single response
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType binds a schema to a content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of JSON Schema used by the generated document
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// OpenAPI generates the OpenAPI document for the route table
+func (rt *Router) OpenAPI(info Info) *Document {
+	gen := &schemaGenerator{schemas: make(map[string]*Schema)}
+	errorSchema := gen.schemaFor(reflect.TypeOf(ErrorResponse{}))
+
+	doc := &Document{
+		OpenAPI:    "3.0.3",
+		Info:       info,
+		Servers:    []Server{{URL: rt.prefix}},
+		Paths:      make(map[string]map[string]*Operation),
+		Components: Components{Schemas: gen.schemas},
+	}
+
+	for _, route := range rt.routes {
+		path := pathParamPattern.ReplaceAllString(route.Path, "{$1}")
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(map[string]*Operation)
+			doc.Paths[path] = item
+		}
+
+		op := &Operation{
+			OperationID: route.OperationID,
+			Summary:     route.Summary,
+			Description: route.Description,
+			Responses:   make(map[string]*Response),
+		}
+		if route.Tag != "" {
+			op.Tags = []string{route.Tag}
+		}
+		if route.Permission != "" {
+			doc.Components.SecuritySchemes = securitySchemes
+			op.Security = []map[string][]string{{"bearerKey": {}}, {"headerKey": {}}}
+			op.Description = strings.TrimSpace(op.Description + " Requires an API key with the " + route.Permission + " permission.")
+		}
+
+		// Every wildcard in the path is a required path parameter
+		documented := make(map[string]Param)
+		for _, p := range route.PathParams {
+			documented[p.Name] = p
+		}
+		for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			p := documented[match[1]]
+			p.Name = match[1]
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:        p.Name,
+				In:          "path",
+				Description: p.Description,
+				Required:    true,
+				Schema:      paramSchema(p),
+			})
+		}
+		for _, p := range route.Query {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:        p.Name,
+				In:          "query",
+				Description: p.Description,
+				Required:    p.Required,
+				Schema:      paramSchema(p),
+			})
+		}
+
+		if route.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content: map[string]*MediaType{
+					"application/json": {Schema: gen.schemaFor(reflect.TypeOf(route.Request))},
+				},
+			}
+		}
+
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := &Response{Description: http.StatusText(status)}
+		if route.Response != nil || len(route.ContentTypes) > 0 {
+			success.Content = make(map[string]*MediaType)
+		}
+		if route.Response != nil {
+			success.Content["application/json"] = &MediaType{Schema: gen.schemaFor(reflect.TypeOf(route.Response))}
+		}
+		for _, contentType := range route.ContentTypes {
+			success.Content[contentType] = &MediaType{Schema: &Schema{Type: "string"}}
+		}
+		op.Responses[strconv.Itoa(status)] = success
+		op.Responses["default"] = &Response{
+			Description: "Error",
+			Content: map[string]*MediaType{
+				"application/json": {Schema: errorSchema},
+			},
+		}
+
+		item[strings.ToLower(route.Method)] = op
+	}
+	return doc
+}
+
+// ServeOpenAPI returns a handler serving the generated document. The
+// document is built on first request, once every route is registered.
+func (rt *Router) ServeOpenAPI(info Info) http.HandlerFunc {
+	var (
+		once sync.Once
+		body []byte
+		err  error
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			body, err = json.MarshalIndent(rt.OpenAPI(info), "", "  ")
+		})
+		if err != nil {
+			HTTPError(w, "Error generating OpenAPI document", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
+
+func paramSchema(p Param) *Schema {
+	return &Schema{Type: "string", Enum: p.Enum}
+}
+
+// schemaGenerator derives schemas from Go types, registering named structs
+// as components so they are described once
+type schemaGenerator struct {
+	schemas map[string]*Schema
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+func (g *schemaGenerator) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := componentName(t)
+		if _, ok := g.schemas[name]; !ok {
+			// Reserve the name first so recursive types terminate
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, omitempty := jsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embeddedType := field.Type
+			for embeddedType.Kind() == reflect.Ptr {
+				embeddedType = embeddedType.Elem()
+			}
+			embedded := g.structSchema(embeddedType)
+			for prop, s := range embedded.Properties {
+				schema.Properties[prop] = s
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = g.schemaFor(field.Type)
+		if !omitempty {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag, ok := field.Tag.Lookup("json")
+	if !ok {
+		return "", false
+	}
+	parts := strings.Split(tag, ",")
+	omitempty := false
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+	return parts[0], omitempty
+}
+
+// componentName qualifies a type with its package so types such as
+// apfeed.Race and handlers.Race don't collide
+func componentName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	if pkg == "" {
+		return t.Name()
+	}
+	return pkg + "." + t.Name()
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Param documents a path or query parameter
+type Param struct {
+	Name        string
+	Description string
+	Required    bool
+	Enum        []string
+}
+
+// Route is a single operation in the route table. The table drives both
+// request routing and the generated OpenAPI document.
+type Route struct {
+	Method      string
+	Path        string // relative to the router prefix, e.g. "/counties/{id}"
+	OperationID string
+	Summary     string
+	Description string
+	Tag         string
+	PathParams  []Param
+	Query       []Param
+
+	// Request and Response are zero values of the JSON body types, used only
+	// to generate schemas. Leave nil when there is no JSON body.
+	Request  interface{}
+	Response interface{}
+
+	// Status is the success status code, defaulting to 200
+	Status int
+
+	// ContentTypes lists non-JSON success media types (CSV, HTML, SSE)
+	ContentTypes []string
+
+	// Permission is what a client's API key must grant to call the route.
+	// Routes that change data must declare one; reads are public without it.
+	Permission string
+
+	Handler http.HandlerFunc
+}
+
+// Guard wraps the handler of a route that requires a permission
+type Guard func(permission string, next http.HandlerFunc) http.HandlerFunc
+
+// Router registers a versioned route table on a ServeMux
+type Router struct {
+	prefix string
+	routes []Route
+	guard  Guard
+	limit  Guard
+}
+
+// NewRouter creates a router whose routes all live under prefix
+func NewRouter(prefix string) *Router {
+	return &Router{prefix: strings.TrimSuffix(prefix, "/")}
+}
+
+// Prefix returns the path prefix shared by every route
+func (rt *Router) Prefix() string {
+	return rt.prefix
+}
+
+// Handle adds a route to the table
+func (rt *Router) Handle(route Route) {
+	rt.routes = append(rt.routes, route)
+}
+
+// Guard sets how routes declaring a Permission are protected. It must be set
+// before Register.
+func (rt *Router) Guard(guard Guard) {
+	rt.guard = guard
+}
+
+// Limit sets middleware every route passes through, given the route's
+// Permission, or "" for public routes. It runs inside the guard, so it sees
+// who was authenticated. It must be set before Register.
+func (rt *Router) Limit(limit Guard) {
+	rt.limit = limit
+}
+
+// Routes returns the route table in registration order
+func (rt *Router) Routes() []Route {
+	return rt.routes
+}
+
+// Register installs the route table on mux. Routes sharing a path are
+// dispatched by method so unsupported methods get a JSON 405 with an Allow
+// header, and unknown paths under the prefix get a JSON 404. It panics if a
+// route changing data declares no Permission, so none is left unprotected.
+func (rt *Router) Register(mux *http.ServeMux) {
+	byPath := make(map[string]map[string]http.HandlerFunc)
+	var paths []string
+	for _, route := range rt.routes {
+		methods, ok := byPath[route.Path]
+		if !ok {
+			methods = make(map[string]http.HandlerFunc)
+			byPath[route.Path] = methods
+			paths = append(paths, route.Path)
+		}
+
+		handler := route.Handler
+		if route.Response != nil && len(route.ContentTypes) == 0 {
+			handler = jsonHandler(handler)
+		}
+		if route.Permission == "" && route.Method != http.MethodGet {
+			panic(fmt.Sprintf("api: %s %s changes data but declares no permission", route.Method, rt.prefix+route.Path))
+		}
+		if rt.limit != nil {
+			handler = rt.limit(route.Permission, handler)
+		}
+		if route.Permission != "" && rt.guard != nil {
+			handler = rt.guard(route.Permission, handler)
+		}
+		methods[route.Method] = handler
+	}
+
+	for _, path := range paths {
+		mux.Handle(rt.prefix+path, methodHandler(byPath[path]))
+	}
+
+	mux.HandleFunc(rt.prefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		HTTPError(w, "No route matches "+r.URL.Path, http.StatusNotFound)
+	})
+}
+
+func methodHandler(methods map[string]http.HandlerFunc) http.HandlerFunc {
+	allowed := make([]string, 0, len(methods)+1)
+	for method := range methods {
+		allowed = append(allowed, method)
+	}
+	allowed = append(allowed, http.MethodOptions)
+	sort.Strings(allowed)
+	allow := strings.Join(allowed, ", ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler, ok := methods[r.Method]
+		switch {
+		case ok:
+			handler(w, r)
+		case r.Method == http.MethodOptions:
+			w.Header().Set("Allow", allow)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", allow)
+			HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// jsonHandler defaults the content type of JSON-only routes, since not every
+// handler sets it
+func jsonHandler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		next(w, r)
+	}
+}
@@ -0,0 +1,249 @@
+// Package auth authenticates API clients and enforces the permission each
+// route requires. Clients send an API key, the ADMIN_API_KEY the server was
+// started with, or a PocketBase admin auth token, either as a bearer token
+// or in the X-API-Key header.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"era/internal/api"
+	"era/internal/models"
+	"era/internal/storage"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyPrefix starts every generated API key, telling keys apart from
+// PocketBase admin tokens
+const KeyPrefix = "era_"
+
+// touchInterval limits how often a key's last use is written
+const touchInterval = time.Minute
+
+var errNoCredentials = errors.New("no credentials")
+
+// throttledError refuses a client that failed to authenticate too often
+type throttledError struct {
+	wait time.Duration
+}
+
+func (e *throttledError) Error() string {
+	return "too many failed authentications"
+}
+
+// Throttle bounds how often a client may fail to authenticate, so keys
+// can't be guessed and lookups can't load the database without limit
+type Throttle interface {
+	// Blocked reports how long until the client of r may try again, or 0
+	Blocked(r *http.Request) time.Duration
+	// Failed charges a failed authentication to the client of r
+	Failed(r *http.Request)
+}
+
+// Principal is the client a request was authenticated as
+type Principal struct {
+	// Name is the API key's name, or the PocketBase admin's email
+	Name   string
+	KeyID  string
+	Role   string
+	Scopes []string
+	// Elections limits the principal to these elections; empty allows all
+	Elections []string
+}
+
+// Allows reports whether the principal was granted a permission
+func (p *Principal) Allows(permission string) bool {
+	return models.GrantsPermission(p.Role, p.Scopes, permission)
+}
+
+// CanAccessElection reports whether the principal may act on an election.
+// Principals limited to some elections can't act on results without one.
+func (p *Principal) CanAccessElection(electionID string) bool {
+	if len(p.Elections) == 0 {
+		return true
+	}
+	for _, id := range p.Elections {
+		if id == electionID {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// failureKey holds why Identify couldn't authenticate a request, so Require
+// answers without looking the credentials up again
+type failureKey struct{}
+
+// FromContext returns the principal of an authenticated request, or nil
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(contextKey{}).(*Principal)
+	return p
+}
+
+// Authenticator checks request credentials against the stored API keys
+type Authenticator struct {
+	store    *storage.PocketBaseStore
+	adminKey string
+	throttle Throttle
+}
+
+// New creates an authenticator. adminKey, when set, is accepted as an admin
+// key so the first keys can be created. throttle, when set, is checked
+// before credentials are looked up and charged for every failure.
+func New(store *storage.PocketBaseStore, adminKey string, throttle Throttle) *Authenticator {
+	return &Authenticator{store: store, adminKey: adminKey, throttle: throttle}
+}
+
+// Require serves next only to clients granted permission. Requests without
+// valid credentials get a 401, clients that failed to authenticate too often
+// a 429, and clients lacking the permission a 403. CORS preflight requests
+// carry no credentials and are passed through.
+func (a *Authenticator) Require(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+
+		principal := FromContext(r.Context())
+		var err error
+		if principal == nil {
+			if err, _ = r.Context().Value(failureKey{}).(error); err == nil {
+				principal, err = a.check(r)
+			}
+		}
+		var throttled *throttledError
+		if errors.As(err, &throttled) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.wait.Seconds()))))
+			api.HTTPError(w, "Too many failed authentications, try again later", http.StatusTooManyRequests)
+			return
+		}
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="era"`)
+			message := "Invalid API key"
+			if errors.Is(err, errNoCredentials) {
+				message = "An API key is required"
+			}
+			api.HTTPError(w, message, http.StatusUnauthorized)
+			return
+		}
+		if !principal.Allows(permission) {
+			api.HTTPError(w, fmt.Sprintf("API key %q lacks the %s permission", principal.Name, permission), http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, principal)))
+	}
+}
+
+// Identify attaches the principal of requests carrying valid credentials,
+// so public routes know which client they serve. Requests with missing or
+// invalid credentials pass through anonymously; Require still refuses them.
+func (a *Authenticator) Identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if credentials(r) != "" {
+			principal, err := a.check(r)
+			if err == nil {
+				r = r.WithContext(context.WithValue(r.Context(), contextKey{}, principal))
+			} else {
+				r = r.WithContext(context.WithValue(r.Context(), failureKey{}, err))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// check authenticates a request unless its client failed to authenticate
+// too often, charging the throttle for each failure
+func (a *Authenticator) check(r *http.Request) (*Principal, error) {
+	if a.throttle != nil {
+		if wait := a.throttle.Blocked(r); wait > 0 {
+			return nil, &throttledError{wait: wait}
+		}
+	}
+	principal, err := a.Authenticate(r)
+	if err != nil && a.throttle != nil {
+		a.throttle.Failed(r)
+	}
+	return principal, err
+}
+
+// Authenticate identifies the client making a request
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := credentials(r)
+	if token == "" {
+		return nil, errNoCredentials
+	}
+
+	if a.adminKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.adminKey)) == 1 {
+		return &Principal{Name: "ADMIN_API_KEY", Role: models.RoleAdmin}, nil
+	}
+
+	if !strings.HasPrefix(token, KeyPrefix) {
+		email, err := a.store.FindAdminByToken(token)
+		if err != nil {
+			return nil, err
+		}
+		return &Principal{Name: email, Role: models.RoleAdmin}, nil
+	}
+
+	key, err := a.store.FindAPIKeyByHash(HashKey(token))
+	if err != nil {
+		return nil, err
+	}
+	a.touch(key)
+	return &Principal{
+		Name:      key.Name,
+		KeyID:     key.ID,
+		Role:      key.Role,
+		Scopes:    key.Scopes,
+		Elections: key.Elections,
+	}, nil
+}
+
+// touch records a key's use, at most once per touchInterval
+func (a *Authenticator) touch(key *models.APIKey) {
+	now := time.Now()
+	if key.LastUsedAt != nil && now.Sub(*key.LastUsedAt) < touchInterval {
+		return
+	}
+	if err := a.store.TouchAPIKey(key.ID, now); err != nil {
+		slog.Error("Error recording use of API key", "key_id", key.ID, "error", err)
+	}
+}
+
+// credentials returns the bearer token or X-API-Key header of a request
+func credentials(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// NewKey generates an API key and the prefix shown when listing keys
+func NewKey() (key, prefix string, err error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key = KeyPrefix + hex.EncodeToString(b)
+	return key, key[:len(KeyPrefix)+8], nil
+}
+
+// HashKey returns the hash an API key is stored and looked up by
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,380 @@
+// Package cache keeps rendered result responses in memory until the results
+// they were built from change, and serves them with ETag revalidation
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"era/internal/i18n"
+	"era/internal/models"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxEntries bounds how many responses are kept; the least recently
+	// used response is evicted first
+	maxEntries = 1000
+
+	// maxEntrySize is the largest body kept in memory. Larger responses,
+	// such as full election exports, are streamed through uncached.
+	maxEntrySize = 8 << 20
+)
+
+// Scope returns the county slug a request's response is built from, or ""
+// when it may draw on every county
+type Scope func(r *http.Request) string
+
+// AllCounties scopes responses that may include any county
+func AllCounties(r *http.Request) string {
+	return ""
+}
+
+// CountyScope scopes responses to the county slug in the {id} path value
+func CountyScope(r *http.Request) string {
+	return models.CountySlug(r.PathValue("id"))
+}
+
+// ContestScope scopes responses to the county of the contest ID in the {id}
+// path value
+func ContestScope(r *http.Request) string {
+	county, _, err := models.ParseContestID(r.PathValue("id"))
+	if err != nil {
+		return ""
+	}
+	return county
+}
+
+// version identifies the state of the results a response was built from.
+// Responses whose version no longer matches are stale.
+type version struct {
+	epoch uint64
+	n     uint64
+}
+
+type entry struct {
+	key     string
+	county  string
+	version version
+	header  http.Header
+	body    []byte
+	etag    string
+}
+
+// Cache is an in-process cache of GET responses. Entries are invalidated
+// when a county stores a new snapshot and after any write request.
+type Cache struct {
+	maxAge time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	epoch    uint64
+	all      uint64
+	counties map[string]uint64
+}
+
+// New creates a cache whose responses tell clients they may reuse them for
+// maxAge before revalidating
+func New(maxAge time.Duration) *Cache {
+	return &Cache{
+		maxAge:   maxAge,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		counties: make(map[string]uint64),
+	}
+}
+
+// currentVersion returns the version of the results behind a scope. Every
+// county change also moves the version of the all-counties scope.
+func (c *Cache) currentVersion(county string) version {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.versionLocked(county)
+}
+
+func (c *Cache) versionLocked(county string) version {
+	if county == "" {
+		return version{epoch: c.epoch, n: c.all}
+	}
+	return version{epoch: c.epoch, n: c.counties[county]}
+}
+
+// InvalidateCounty drops every response built from a county's results
+func (c *Cache) InvalidateCounty(county string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counties[county]++
+	c.all++
+}
+
+// InvalidateAll drops every cached response
+func (c *Cache) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+// HandleIngest invalidates the responses of a county that stored a new
+// snapshot. It is registered as a parser manager ingest listener.
+func (c *Cache) HandleIngest(result *models.IngestResult) {
+	c.InvalidateCounty(result.Snapshot.County)
+}
+
+// InvalidateWrites invalidates the whole cache after every request that may
+// change data, such as race calls and deleted results. Rejected requests,
+// such as unauthenticated ones, leave the cache alone.
+func (c *Cache) InvalidateWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status < http.StatusBadRequest {
+			c.InvalidateAll()
+		}
+	})
+}
+
+// statusWriter records the status of a response as it is written
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+func (c *Cache) get(key string, v version) *entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	e := elem.Value.(*entry)
+	if e.version != v {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return e
+}
+
+func (c *Cache) put(e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Results changed while the response was built, so it is already stale
+	if e.version != c.versionLocked(e.county) {
+		return
+	}
+	if elem, ok := c.entries[e.key]; ok {
+		c.lru.Remove(elem)
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	for c.lru.Len() > maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+	}
+}
+
+// Handler caches the successful GET responses of next, keyed by path, query
+// and requested language, until the results in scope change. Every cached
+// response carries an ETag, and requests whose If-None-Match matches it get
+// 304 Not Modified. Clients and shared caches such as CDNs may reuse the
+// responses, so next must serve the same response to everyone.
+func (c *Cache) Handler(scope Scope, next http.HandlerFunc) http.HandlerFunc {
+	return c.handler(scope, false, next)
+}
+
+// Private caches like Handler for routes that need an API key, such as
+// exports. Responses are marked private and vary by credentials, so shared
+// caches never serve them to clients without access; the route's guard
+// still checks every request before this cache is reached.
+func (c *Cache) Private(scope Scope, next http.HandlerFunc) http.HandlerFunc {
+	return c.handler(scope, true, next)
+}
+
+func (c *Cache) handler(scope Scope, private bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next(w, r)
+			return
+		}
+
+		key := r.URL.Path + "?" + r.URL.Query().Encode() + "#" + i18n.Lang(r)
+		county := scope(r)
+		v := c.currentVersion(county)
+		if e := c.get(key, v); e != nil {
+			for name, values := range e.header {
+				w.Header()[name] = values
+			}
+			c.serve(w, r, e, private, "HIT")
+			return
+		}
+
+		outer := w.Header().Clone()
+		rec := &recorder{w: w}
+		next(rec, r)
+		if rec.passthrough {
+			return
+		}
+
+		sum := sha256.Sum256(rec.buf.Bytes())
+		e := &entry{
+			key:     key,
+			county:  county,
+			version: v,
+			header:  handlerHeader(outer, w.Header()),
+			body:    rec.buf.Bytes(),
+			etag:    `"` + hex.EncodeToString(sum[:16]) + `"`,
+		}
+		c.put(e)
+		c.serve(w, r, e, private, "MISS")
+	}
+}
+
+// handlerHeader returns the headers next set on top of those already set
+// by outer middleware. Middleware headers, such as CORS and rate limit
+// headers, depend on the client and must not be replayed to others.
+func handlerHeader(outer, header http.Header) http.Header {
+	set := make(http.Header)
+	for name, values := range header {
+		if before, ok := outer[name]; !ok || !equalValues(before, values) {
+			set[name] = append([]string(nil), values...)
+		}
+	}
+	return set
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// serve writes a cached response, or 304 when the client already has it
+func (c *Cache) serve(w http.ResponseWriter, r *http.Request, e *entry, private bool, status string) {
+	w.Header().Set("ETag", e.etag)
+	if private {
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(c.maxAge.Seconds())))
+		w.Header().Add("Vary", "Authorization, X-API-Key")
+	} else {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(c.maxAge.Seconds())))
+	}
+	w.Header().Set("X-Cache", status)
+
+	if etagMatches(r.Header.Get("If-None-Match"), e.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(e.body)
+}
+
+// etagMatches reports whether an If-None-Match header lists the ETag, using
+// the weak comparison required for GET requests
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// recorder buffers a successful response so it can be cached. Error
+// responses and bodies over maxEntrySize are passed straight through.
+type recorder struct {
+	w           http.ResponseWriter
+	status      int
+	buf         bytes.Buffer
+	passthrough bool
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.w.Header()
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status != 0 {
+		return
+	}
+	rec.status = status
+	if status != http.StatusOK {
+		rec.startPassthrough()
+	}
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if !rec.passthrough && rec.buf.Len()+len(p) > maxEntrySize {
+		rec.startPassthrough()
+	}
+	if rec.passthrough {
+		return rec.w.Write(p)
+	}
+	return rec.buf.Write(p)
+}
+
+// Flush only reaches the client once the response is no longer buffered
+func (rec *recorder) Flush() {
+	if !rec.passthrough {
+		return
+	}
+	if flusher, ok := rec.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// startPassthrough sends the status and anything buffered so far, then
+// writes the rest of the response directly
+func (rec *recorder) startPassthrough() {
+	rec.passthrough = true
+	rec.w.WriteHeader(rec.status)
+	if rec.buf.Len() > 0 {
+		rec.w.Write(rec.buf.Bytes())
+		rec.buf.Reset()
+	}
+}
@@ -0,0 +1,190 @@
+// Package cards renders contest results as PNG share images sized for Open
+// Graph and social previews
+package cards
+
+import (
+	"era/internal/charts"
+	"era/internal/models"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Card dimensions follow the recommended Open Graph image size
+const (
+	Width  = 1200
+	Height = 630
+
+	margin     = 60
+	bandHeight = 130
+	rowHeight  = 100
+
+	// maxChoices is how many leading choices fit on a card
+	maxChoices = 4
+)
+
+var (
+	background = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	band       = color.RGBA{0x1a, 0x36, 0x68, 0xff}
+	track      = color.RGBA{0xee, 0xee, 0xee, 0xff}
+	text       = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	muted      = color.RGBA{0x77, 0x77, 0x77, 0xff}
+	bandText   = color.RGBA{0xd0, 0xd8, 0xe8, 0xff}
+)
+
+// Card is the content of a contest's share image
+type Card struct {
+	Title              string
+	Subtitle           string
+	Choices            []models.ChoiceTotal
+	Winner             string
+	PrecinctsReporting int
+	PrecinctsTotal     int
+	Updated            time.Time
+}
+
+type faces struct {
+	title, subtitle, name, share, footer font.Face
+}
+
+var (
+	facesOnce sync.Once
+	loaded    faces
+	facesErr  error
+)
+
+// loadFaces parses the bundled Go fonts once
+func loadFaces() (faces, error) {
+	facesOnce.Do(func() {
+		regular, err := opentype.Parse(goregular.TTF)
+		if err != nil {
+			facesErr = fmt.Errorf("failed to parse regular font: %w", err)
+			return
+		}
+		bold, err := opentype.Parse(gobold.TTF)
+		if err != nil {
+			facesErr = fmt.Errorf("failed to parse bold font: %w", err)
+			return
+		}
+
+		face := func(f *opentype.Font, size float64) font.Face {
+			if facesErr != nil {
+				return nil
+			}
+			var face font.Face
+			face, facesErr = opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+			return face
+		}
+		loaded = faces{
+			title:    face(bold, 52),
+			subtitle: face(regular, 28),
+			name:     face(bold, 36),
+			share:    face(bold, 40),
+			footer:   face(regular, 24),
+		}
+	})
+	return loaded, facesErr
+}
+
+// Render draws the card and writes it as a PNG
+func Render(w io.Writer, card Card) error {
+	f, err := loadFaces()
+	if err != nil {
+		return err
+	}
+
+	img := imaging.New(Width, Height, background)
+
+	// Title band
+	fillRect(img, image.Rect(0, 0, Width, bandHeight), band)
+	drawText(img, f.title, background, margin, 68, fit(f.title, card.Title, Width-2*margin))
+	drawText(img, f.subtitle, bandText, margin, 108, fit(f.subtitle, card.Subtitle, Width-2*margin))
+
+	// Leading choices with their share of the vote
+	choices := card.Choices
+	if len(choices) > maxChoices {
+		choices = choices[:maxChoices]
+	}
+	barWidth := Width - 2*margin
+	for i, choice := range choices {
+		top := bandHeight + 30 + i*rowHeight
+
+		share := fmt.Sprintf("%.1f%%", choice.Percentage)
+		shareWidth := font.MeasureString(f.share, share).Ceil()
+		drawText(img, f.share, text, Width-margin-shareWidth, top+42, share)
+
+		name := choice.Name
+		if card.Winner != "" && choice.Name == card.Winner {
+			name += " (winner)"
+		}
+		drawText(img, f.name, text, margin, top+40, fit(f.name, name, barWidth-shareWidth-30))
+
+		barTop := top + 56
+		fillRect(img, image.Rect(margin, barTop, margin+barWidth, barTop+18), track)
+		filled := int(float64(barWidth) * choice.Percentage / 100)
+		fillRect(img, image.Rect(margin, barTop, margin+filled, barTop+18), paletteColor(i))
+	}
+
+	// Reporting status and freshness
+	footer := ""
+	if card.PrecinctsTotal > 0 {
+		footer = fmt.Sprintf("%d of %d precincts reporting", card.PrecinctsReporting, card.PrecinctsTotal)
+	}
+	if !card.Updated.IsZero() {
+		if footer != "" {
+			footer += "  ·  "
+		}
+		footer += "Last updated " + card.Updated.UTC().Format("Jan 2, 3:04 PM MST")
+	}
+	drawText(img, f.footer, muted, margin, Height-36, footer)
+
+	return imaging.Encode(w, img, imaging.PNG)
+}
+
+func fillRect(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func drawText(img draw.Image, face font.Face, c color.Color, x, y int, s string) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+// fit shortens s with an ellipsis until it fits within width pixels
+func fit(face font.Face, s string, width int) string {
+	if font.MeasureString(face, s).Ceil() <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := string(runes) + "…"
+		if font.MeasureString(face, candidate).Ceil() <= width {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// paletteColor matches the colors of the SVG charts
+func paletteColor(i int) color.Color {
+	var r, g, b uint8
+	fmt.Sscanf(charts.Palette[i%len(charts.Palette)], "#%02x%02x%02x", &r, &g, &b)
+	return color.RGBA{r, g, b, 0xff}
+}
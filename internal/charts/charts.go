@@ -0,0 +1,139 @@
+// Package charts renders contest results as standalone SVG images: bar
+// charts for candidate races, split bars for measures and county or precinct
+// choropleth maps
+package charts
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Palette colors choices in the order they are listed; the first matches the
+// leading color used by the HTML templates
+var Palette = []string{
+	"#1a3668", "#9e0000", "#738c3f", "#d98a00", "#6a3d9a",
+	"#1f78b4", "#b15928", "#33a02c", "#e31a1c", "#666666",
+}
+
+// Chart dimensions
+const (
+	chartWidth = 600
+	barHeight  = 22
+	barGap     = 10
+	labelWidth = 200
+	valueWidth = 110
+	fontFamily = "Arial, sans-serif"
+)
+
+// Bar is a single choice in a bar chart
+type Bar struct {
+	Label      string
+	Votes      int
+	Percentage float64
+	Highlight  bool
+}
+
+// BarChart draws one horizontal bar per choice, scaled so the leading
+// choice fills the available width
+func BarChart(title string, bars []Bar) string {
+	height := len(bars)*(barHeight+barGap) + barGap
+	var b strings.Builder
+	openSVG(&b, title, chartWidth, height)
+
+	maxShare := 0.0
+	for _, bar := range bars {
+		if bar.Percentage > maxShare {
+			maxShare = bar.Percentage
+		}
+	}
+
+	trackWidth := float64(chartWidth - labelWidth - valueWidth)
+	for i, bar := range bars {
+		y := barGap + i*(barHeight+barGap)
+		width := 0.0
+		if maxShare > 0 {
+			width = trackWidth * bar.Percentage / maxShare
+		}
+		color := Palette[i%len(Palette)]
+		weight := "normal"
+		if bar.Highlight {
+			weight = "bold"
+		}
+
+		fmt.Fprintf(&b, `<text x="%d" y="%d" font-weight="%s">%s</text>`,
+			labelWidth-8, y+barHeight-6, weight, escape(truncate(bar.Label, 28)))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s" rx="2"><title>%s: %d votes</title></rect>`,
+			labelWidth, y, width, barHeight, color, escape(bar.Label), bar.Votes)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="start">%s%%</text>`,
+			float64(labelWidth)+width+6, y+barHeight-6, formatShare(bar.Percentage))
+	}
+
+	closeSVG(&b)
+	return b.String()
+}
+
+// SplitBar draws a measure's yes and no shares as one divided bar, with a
+// marker at the share of yes votes needed to pass
+func SplitBar(title string, yes, no int, threshold float64) string {
+	const height = 70
+	const top = 22
+	var b strings.Builder
+	openSVG(&b, title, chartWidth, height)
+
+	yesShare := 0.0
+	if total := yes + no; total > 0 {
+		yesShare = float64(yes) * 100 / float64(total)
+	}
+	noShare := 100 - yesShare
+	if yes+no == 0 {
+		noShare = 0
+	}
+
+	yesWidth := chartWidth * yesShare / 100
+	fmt.Fprintf(&b, `<rect x="0" y="%d" width="%d" height="%d" fill="#eee"/>`, top, chartWidth, barHeight+4)
+	fmt.Fprintf(&b, `<rect x="0" y="%d" width="%.1f" height="%d" fill="%s"><title>Yes: %d votes</title></rect>`,
+		top, yesWidth, barHeight+4, Palette[2], yes)
+	if yes+no > 0 {
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"><title>No: %d votes</title></rect>`,
+			yesWidth, top, chartWidth-yesWidth, barHeight+4, Palette[1], no)
+	}
+
+	fmt.Fprintf(&b, `<text x="0" y="%d" text-anchor="start">Yes %s%%</text>`, top-6, formatShare(yesShare))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">No %s%%</text>`, chartWidth, top-6, formatShare(noShare))
+
+	thresholdX := chartWidth * threshold / 100
+	fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#000" stroke-width="2" stroke-dasharray="4 2"/>`,
+		thresholdX, top-4, thresholdX, top+barHeight+8)
+	fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle" font-size="11">%s%% to pass</text>`,
+		thresholdX, top+barHeight+20, formatShare(threshold))
+
+	closeSVG(&b)
+	return b.String()
+}
+
+func openSVG(b *strings.Builder, title string, width, height int) {
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="100%%" role="img" font-family="%s" font-size="13" text-anchor="end">`,
+		width, height, fontFamily)
+	fmt.Fprintf(b, `<title>%s</title>`, escape(title))
+}
+
+func closeSVG(b *strings.Builder) {
+	b.WriteString(`</svg>`)
+}
+
+func escape(s string) string {
+	return html.EscapeString(s)
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+func formatShare(share float64) string {
+	return fmt.Sprintf("%.1f", share)
+}
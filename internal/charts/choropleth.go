@@ -0,0 +1,155 @@
+package charts
+
+import (
+	"era/internal/models"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Map dimensions
+const (
+	mapWidth     = 600
+	mapPadding   = 10
+	legendRow    = 20
+	noDataColor  = "#f0f0f0"
+	outlineColor = "#888"
+)
+
+// Shade is how a single region is filled on a map
+type Shade struct {
+	Color   string
+	Opacity float64
+	Label   string
+}
+
+// LegendItem explains one color on a map
+type LegendItem struct {
+	Color string
+	Label string
+}
+
+// Choropleth draws every feature of fc, filled with the shade found under
+// the feature's key property. Features without a shade are drawn as having
+// no data.
+func Choropleth(title string, fc *FeatureCollection, property string, shades map[string]Shade, legend []LegendItem) (string, error) {
+	type region struct {
+		name  string
+		rings [][][2]float64
+	}
+
+	var regions []region
+	minLon, minLat := math.Inf(1), math.Inf(1)
+	maxLon, maxLat := math.Inf(-1), math.Inf(-1)
+	for _, feature := range fc.Features {
+		rings, err := feature.rings()
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s boundary: %w", feature.Name(property), err)
+		}
+		for _, ring := range rings {
+			for _, p := range ring {
+				minLon, maxLon = math.Min(minLon, p[0]), math.Max(maxLon, p[0])
+				minLat, maxLat = math.Min(minLat, p[1]), math.Max(maxLat, p[1])
+			}
+		}
+		regions = append(regions, region{name: feature.Name(property), rings: rings})
+	}
+	if len(regions) == 0 {
+		return "", ErrNoBoundaries
+	}
+
+	// Equirectangular projection, corrected for latitude so shapes keep
+	// their proportions
+	xScale := math.Cos((minLat + maxLat) / 2 * math.Pi / 180)
+	spanX := math.Max((maxLon-minLon)*xScale, 1e-9)
+	spanY := math.Max(maxLat-minLat, 1e-9)
+	scale := float64(mapWidth-2*mapPadding) / spanX
+	mapHeight := int(spanY*scale) + 2*mapPadding
+	project := func(p [2]float64) (float64, float64) {
+		return mapPadding + (p[0]-minLon)*xScale*scale, mapPadding + (maxLat-p[1])*scale
+	}
+
+	height := mapHeight + len(legend)*legendRow
+	var b strings.Builder
+	openSVG(&b, title, mapWidth, height)
+
+	for _, r := range regions {
+		var path strings.Builder
+		for _, ring := range r.rings {
+			for i, p := range ring {
+				x, y := project(p)
+				if i == 0 {
+					fmt.Fprintf(&path, "M%.1f %.1f", x, y)
+				} else {
+					fmt.Fprintf(&path, "L%.1f %.1f", x, y)
+				}
+			}
+			path.WriteString("Z")
+		}
+
+		fill, opacity, label := noDataColor, 1.0, r.name+": no results"
+		if shade, ok := shades[r.name]; ok {
+			fill, opacity, label = shade.Color, shade.Opacity, r.name+": "+shade.Label
+		}
+		fmt.Fprintf(&b, `<path d="%s" fill="%s" fill-opacity="%.2f" stroke="%s" stroke-width="0.8"><title>%s</title></path>`,
+			path.String(), fill, opacity, outlineColor, escape(label))
+	}
+
+	for i, item := range legend {
+		y := mapHeight + i*legendRow
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="14" height="14" fill="%s"/>`, mapPadding, y, item.Color)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="start">%s</text>`, mapPadding+20, y+12, escape(item.Label))
+	}
+
+	closeSVG(&b)
+	return b.String(), nil
+}
+
+// LeaderShades colors each region by its leading choice, darker for wider
+// margins. Choices take palette colors in the order given, so the same
+// choice has the same color in every region.
+func LeaderShades(regions map[string][]models.ChoiceTotal, choices []string) (map[string]Shade, []LegendItem) {
+	colors := make(map[string]string)
+	var legend []LegendItem
+	for i, choice := range choices {
+		colors[choice] = Palette[i%len(Palette)]
+		legend = append(legend, LegendItem{Color: colors[choice], Label: choice})
+	}
+
+	shades := make(map[string]Shade)
+	for name, totals := range regions {
+		if len(totals) == 0 || totals[0].Votes == 0 {
+			continue
+		}
+		margin := totals[0].Percentage
+		if len(totals) > 1 {
+			margin -= totals[1].Percentage
+		}
+		shades[name] = Shade{
+			Color:   colors[totals[0].Name],
+			Opacity: 0.3 + 0.7*math.Min(margin/40, 1),
+			Label:   fmt.Sprintf("%s leads with %s%%", totals[0].Name, formatShare(totals[0].Percentage)),
+		}
+	}
+	return shades, legend
+}
+
+// ShareShades colors each region by one choice's share of the vote
+func ShareShades(regions map[string][]models.ChoiceTotal, choice string) (map[string]Shade, []LegendItem) {
+	color := Palette[0]
+	shades := make(map[string]Shade)
+	for name, totals := range regions {
+		for _, total := range totals {
+			if total.Name != choice {
+				continue
+			}
+			shades[name] = Shade{
+				Color:   color,
+				Opacity: 0.1 + 0.9*total.Percentage/100,
+				Label:   fmt.Sprintf("%s %s%%", choice, formatShare(total.Percentage)),
+			}
+		}
+	}
+	legend := []LegendItem{{Color: color, Label: "Share of the vote for " + choice + " (darker is higher)"}}
+	return shades, legend
+}
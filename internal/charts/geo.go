@@ -0,0 +1,105 @@
+package charts
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+)
+
+//go:embed boundaries/*.geojson
+var boundaryFiles embed.FS
+
+// ErrNoBoundaries is returned when no boundaries are bundled for an area
+var ErrNoBoundaries = errors.New("no boundaries available")
+
+// Boundary property names identifying each feature
+const (
+	CountyProperty   = "name"
+	PrecinctProperty = "precinct"
+)
+
+// FeatureCollection is the subset of a GeoJSON FeatureCollection the maps
+// need
+type FeatureCollection struct {
+	Features []Feature `json:"features"`
+}
+
+// Feature is a single GeoJSON boundary
+type Feature struct {
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+// Name returns a string property of the feature, or "" when it is missing
+func (f Feature) Name(property string) string {
+	name, _ := f.Properties[property].(string)
+	return name
+}
+
+// rings returns the feature's polygon rings as [lon, lat] points
+func (f Feature) rings() ([][][2]float64, error) {
+	switch f.Geometry.Type {
+	case "Polygon":
+		var polygon [][][2]float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &polygon); err != nil {
+			return nil, err
+		}
+		return polygon, nil
+	case "MultiPolygon":
+		var polygons [][][][2]float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &polygons); err != nil {
+			return nil, err
+		}
+		var rings [][][2]float64
+		for _, polygon := range polygons {
+			rings = append(rings, polygon...)
+		}
+		return rings, nil
+	default:
+		return nil, fmt.Errorf("unsupported geometry type: %s", f.Geometry.Type)
+	}
+}
+
+var (
+	countyOnce       sync.Once
+	countyBoundaries *FeatureCollection
+	countyErr        error
+)
+
+// CountyBoundaries returns the bundled county boundaries, keyed by the
+// county's display name in the "name" property
+func CountyBoundaries() (*FeatureCollection, error) {
+	countyOnce.Do(func() {
+		countyBoundaries, countyErr = loadBoundaries("boundaries/california_counties.geojson")
+	})
+	return countyBoundaries, countyErr
+}
+
+// PrecinctBoundaries returns the bundled precinct boundaries of a county
+// slug, keyed by the "precinct" property, or ErrNoBoundaries
+func PrecinctBoundaries(county string) (*FeatureCollection, error) {
+	fc, err := loadBoundaries("boundaries/" + county + "_precincts.geojson")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoBoundaries
+	}
+	return fc, err
+}
+
+func loadBoundaries(name string) (*FeatureCollection, error) {
+	data, err := boundaryFiles.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var fc FeatureCollection
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return &fc, nil
+}
@@ -0,0 +1,447 @@
+// Package config loads the server's settings. Each setting has a default,
+// which a YAML config file, then environment variables, then command line
+// flags override.
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"era/internal/logging"
+	"era/internal/models"
+	"era/internal/ratelimit"
+	"era/internal/urlpolicy"
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds every setting. Fields tagged env can be set from that
+// environment variable; every field can be set by the flag named after its
+// YAML key, e.g. -parse-max-download for parse.max_download.
+type Config struct {
+	Server     Server     `yaml:"server"`
+	PocketBase PocketBase `yaml:"pocketbase"`
+	Results    Results    `yaml:"results"`
+	Auth       Auth       `yaml:"auth"`
+	RateLimits RateLimits `yaml:"rate_limits"`
+	Parse      Parse      `yaml:"parse"`
+	CORS       CORS       `yaml:"cors"`
+	Metrics    Metrics    `yaml:"metrics"`
+	Log        Log        `yaml:"log"`
+	Health     Health     `yaml:"health"`
+	Webhooks   Webhooks   `yaml:"webhooks"`
+
+	// sources records where each setting not left at its default came from
+	sources map[string]string
+}
+
+type Server struct {
+	Port            int           `yaml:"port" env:"PORT" usage:"Port the API listens on"`
+	DataDir         string        `yaml:"data_dir" env:"DATA_DIR" usage:"Directory PocketBase stores its data in"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"How long in-flight requests may take to finish on shutdown before running parses are cancelled"`
+}
+
+type PocketBase struct {
+	HTTPAddr string `yaml:"http_addr" env:"POCKETBASE_HTTP_ADDR" usage:"Address the PocketBase admin UI and API listen on"`
+}
+
+type Results struct {
+	MaxAge      time.Duration `yaml:"max_age" env:"RESULTS_MAX_AGE" usage:"Re-parse a county in the background when its page is viewed with results older than this; 0 disables"`
+	CacheMaxAge time.Duration `yaml:"cache_max_age" env:"CACHE_MAX_AGE" usage:"How long clients may reuse cached results before revalidating"`
+}
+
+type Auth struct {
+	AdminAPIKey string `yaml:"admin_api_key" env:"ADMIN_API_KEY" secret:"true" usage:"Key accepted as an admin API key, to create the first keys"`
+}
+
+type RateLimits struct {
+	Read       ratelimit.Budget `yaml:"read" env:"RATE_LIMIT_READ" usage:"Budget of read routes per client, e.g. 600/1m, or off"`
+	Parse      ratelimit.Budget `yaml:"parse" env:"RATE_LIMIT_PARSE" usage:"Budget of parse routes per client"`
+	Export     ratelimit.Budget `yaml:"export" env:"RATE_LIMIT_EXPORT" usage:"Budget of export routes per client"`
+	Auth       ratelimit.Budget `yaml:"auth" env:"RATE_LIMIT_AUTH" usage:"Budget of failed authentications per IP address"`
+	TrustProxy bool             `yaml:"trust_proxy" env:"TRUST_PROXY" usage:"Count anonymous clients by the address a reverse proxy appends to X-Forwarded-For"`
+}
+
+type Parse struct {
+	AllowedHosts    []string      `yaml:"allowed_hosts" env:"PARSE_ALLOWED_HOSTS" usage:"Hosts or *.example.com patterns parse URLs must match; empty allows any public host"`
+	AllowPrivate    bool          `yaml:"allow_private" env:"PARSE_ALLOW_PRIVATE" usage:"Allow parse URLs on private addresses, for development"`
+	MaxRedirects    int           `yaml:"max_redirects" env:"PARSE_MAX_REDIRECTS" usage:"Redirects a download may follow"`
+	MaxDownload     Size          `yaml:"max_download" env:"PARSE_MAX_DOWNLOAD" usage:"Largest download, e.g. 100MB"`
+	MaxDecompressed Size          `yaml:"max_decompressed" env:"PARSE_MAX_DECOMPRESSED" usage:"Largest total size a ZIP archive may decompress to"`
+	Timeout         time.Duration `yaml:"timeout" env:"PARSE_TIMEOUT" usage:"Timeout of each download"`
+	UserAgent       string        `yaml:"user_agent" env:"PARSE_USER_AGENT" usage:"User-Agent header sent with downloads"`
+	TempDir         string        `yaml:"temp_dir" env:"PARSE_TEMP_DIR" usage:"Directory downloads are stored in while parsed; empty uses the system default"`
+}
+
+type CORS struct {
+	Origins []string      `yaml:"origins" env:"CORS_ALLOWED_ORIGINS" usage:"Origins, or https://*.example.com patterns, browsers may call the API from"`
+	Methods []string      `yaml:"methods" env:"CORS_ALLOWED_METHODS" usage:"Methods allowed in cross-origin requests; empty uses the defaults"`
+	Headers []string      `yaml:"headers" env:"CORS_ALLOWED_HEADERS" usage:"Request headers allowed in cross-origin requests; empty uses the defaults"`
+	MaxAge  time.Duration `yaml:"max_age" env:"CORS_MAX_AGE" usage:"How long browsers may cache preflight responses"`
+}
+
+type Metrics struct {
+	Addr string `yaml:"addr" env:"METRICS_ADDR" usage:"Address Prometheus metrics are served on at /metrics; empty disables them"`
+}
+
+type Log struct {
+	Level     string `yaml:"level" env:"LOG_LEVEL" usage:"Lowest level logged: debug, info, warn or error"`
+	Format    string `yaml:"format" env:"LOG_FORMAT" usage:"Log format: json or text"`
+	RowSample int    `yaml:"row_sample" env:"LOG_ROW_SAMPLE" usage:"Log only every nth per-row debug line of a parse; 1 logs them all"`
+}
+
+type Health struct {
+	Timeout     time.Duration `yaml:"timeout" env:"HEALTH_TIMEOUT" usage:"How long each readiness check may take before it fails"`
+	MinFreeDisk Size          `yaml:"min_free_disk" env:"HEALTH_MIN_FREE_DISK" usage:"Free space the data directory needs for the server to be ready, e.g. 256MB"`
+	StaleAfter  time.Duration `yaml:"stale_after" env:"HEALTH_STALE_AFTER" usage:"Report counties whose latest snapshot is older than this as stale"`
+}
+
+type Webhooks struct {
+	DeliveryRetention time.Duration `yaml:"delivery_retention" env:"WEBHOOK_DELIVERY_RETENTION" usage:"How long webhook delivery attempts are kept; 0 keeps them"`
+}
+
+// Size is a number of bytes, written like "100MB"
+type Size int64
+
+func (s Size) MarshalText() ([]byte, error) {
+	return []byte(urlpolicy.FormatSize(int64(s))), nil
+}
+
+func (s *Size) UnmarshalText(text []byte) error {
+	size, err := urlpolicy.ParseSize(string(text))
+	if err != nil {
+		return err
+	}
+	*s = Size(size)
+	return nil
+}
+
+// Default returns the settings used when nothing overrides them
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Port:            8080,
+			DataDir:         "./pb_data",
+			ShutdownTimeout: 20 * time.Second,
+		},
+		PocketBase: PocketBase{
+			HTTPAddr: "0.0.0.0:8090",
+		},
+		Results: Results{
+			CacheMaxAge: 10 * time.Second,
+		},
+		RateLimits: RateLimits{
+			Read:   ratelimit.DefaultBudgets[ratelimit.ClassRead],
+			Parse:  ratelimit.DefaultBudgets[ratelimit.ClassParse],
+			Export: ratelimit.DefaultBudgets[ratelimit.ClassExport],
+			Auth:   ratelimit.DefaultBudgets[ratelimit.ClassAuth],
+		},
+		Parse: Parse{
+			MaxRedirects:    urlpolicy.DefaultMaxRedirects,
+			MaxDownload:     urlpolicy.DefaultMaxDownloadSize,
+			MaxDecompressed: urlpolicy.DefaultMaxDecompressedSize,
+			Timeout:         30 * time.Second,
+			UserAgent:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
+		},
+		CORS: CORS{
+			Origins: []string{"http://localhost:5173", "https://era-fe-sparkling-sun-7787.fly.dev"},
+			MaxAge:  10 * time.Minute,
+		},
+		Metrics: Metrics{
+			Addr: ":9091",
+		},
+		Log: Log{
+			Level:     "info",
+			Format:    logging.FormatJSON,
+			RowSample: 1000,
+		},
+		Health: Health{
+			Timeout:     2 * time.Second,
+			MinFreeDisk: 256 << 20,
+			StaleAfter:  15 * time.Minute,
+		},
+		Webhooks: Webhooks{
+			DeliveryRetention: 7 * 24 * time.Hour,
+		},
+		sources: make(map[string]string),
+	}
+}
+
+// Load reads the settings from the config file named by -config or
+// CONFIG_FILE, the environment and args, and validates them
+func Load(args []string) (*Config, error) {
+	c := Default()
+	settings := c.settings()
+
+	flags := flag.NewFlagSet("era-server", flag.ContinueOnError)
+	path := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML config file")
+	flagged := make(map[*setting]string)
+	for _, s := range settings {
+		s := s
+		flags.Func(s.flag(), s.usage, func(value string) error {
+			flagged[s] = value
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *path != "" {
+		if err := c.readFile(*path, settings); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if s.env == "" {
+			continue
+		}
+		if value := os.Getenv(s.env); value != "" {
+			if err := s.set(value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+			c.sources[s.key] = "env"
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := flagged[s]; ok {
+			if err := s.set(value); err != nil {
+				return nil, fmt.Errorf("invalid -%s: %w", s.flag(), err)
+			}
+			c.sources[s.key] = "flag"
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readFile applies a YAML config file. Unknown keys are errors, so typos
+// don't go unnoticed.
+func (c *Config) readFile(path string, settings []*setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	var raw map[string]map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	for _, s := range settings {
+		section, key, _ := strings.Cut(s.key, ".")
+		if _, ok := raw[section][key]; ok {
+			c.sources[s.key] = "file"
+		}
+	}
+	return nil
+}
+
+// Validate checks every setting, reporting all the invalid ones
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "server.port %d must be between 1 and 65535", c.Server.Port)
+	check(c.Server.DataDir != "", "server.data_dir is required")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(c.PocketBase.HTTPAddr != "", "pocketbase.http_addr is required")
+	check(c.Results.MaxAge >= 0, "results.max_age must not be negative")
+	check(c.Results.CacheMaxAge >= 0, "results.cache_max_age must not be negative")
+	for _, host := range c.Parse.AllowedHosts {
+		pattern := strings.TrimPrefix(host, "*.")
+		check(pattern != "" && !strings.ContainsAny(pattern, "/:*@ "),
+			"parse.allowed_hosts entry %q must be a host or *.example.com", host)
+	}
+	check(c.Parse.MaxRedirects >= 0, "parse.max_redirects must not be negative")
+	check(c.Parse.MaxDownload >= 0, "parse.max_download must not be negative")
+	check(c.Parse.MaxDecompressed >= 0, "parse.max_decompressed must not be negative")
+	check(c.Parse.Timeout > 0, "parse.timeout must be positive")
+	check(c.Parse.UserAgent != "", "parse.user_agent is required")
+	for _, origin := range c.CORS.Origins {
+		if err := models.ValidateOriginPattern(origin); err != nil {
+			errs = append(errs, fmt.Errorf("cors.origins: %w", err))
+		}
+	}
+	check(c.CORS.MaxAge >= 0, "cors.max_age must not be negative")
+	if c.Metrics.Addr != "" {
+		_, port, err := net.SplitHostPort(c.Metrics.Addr)
+		check(err == nil, "metrics.addr %q must look like :9091 or host:port", c.Metrics.Addr)
+		check(err != nil || port != strconv.Itoa(c.Server.Port), "metrics.addr %q must not use the API's port", c.Metrics.Addr)
+	}
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+	check(c.Log.Format == logging.FormatJSON || c.Log.Format == logging.FormatText,
+		"log.format %q must be json or text", c.Log.Format)
+	check(c.Log.RowSample >= 1, "log.row_sample must be at least 1")
+	check(c.Health.Timeout > 0, "health.timeout must be positive")
+	check(c.Health.MinFreeDisk >= 0, "health.min_free_disk must not be negative")
+	check(c.Health.StaleAfter > 0, "health.stale_after must be positive")
+	check(c.Webhooks.DeliveryRetention >= 0, "webhooks.delivery_retention must not be negative")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Setting is one effective setting, as shown to admins
+type Setting struct {
+	Key    string      `json:"key"`
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+	Env    string      `json:"env,omitempty"`
+	Flag   string      `json:"flag"`
+}
+
+// Effective lists every setting's value and where it came from: default,
+// file, env or flag. Secrets are redacted.
+func (c *Config) Effective() []Setting {
+	settings := c.settings()
+	effective := make([]Setting, 0, len(settings))
+	for _, s := range settings {
+		source := c.sources[s.key]
+		if source == "" {
+			source = "default"
+		}
+		effective = append(effective, Setting{
+			Key:    s.key,
+			Value:  s.display(),
+			Source: source,
+			Env:    s.env,
+			Flag:   "-" + s.flag(),
+		})
+	}
+	return effective
+}
+
+// setting is a field of Config, found by walking its sections
+type setting struct {
+	key    string
+	env    string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+func (c *Config) settings() []*setting {
+	var settings []*setting
+	root := reflect.ValueOf(c).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Type().Field(i)
+		if !section.IsExported() {
+			continue
+		}
+		for j := 0; j < section.Type.NumField(); j++ {
+			field := section.Type.Field(j)
+			settings = append(settings, &setting{
+				key:    yamlName(section) + "." + yamlName(field),
+				env:    field.Tag.Get("env"),
+				usage:  field.Tag.Get("usage"),
+				secret: field.Tag.Get("secret") == "true",
+				value:  root.Field(i).Field(j),
+			})
+		}
+	}
+	return settings
+}
+
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	return name
+}
+
+// flag is the command line flag setting s
+func (s *setting) flag() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses a value given in the environment or a flag
+func (s *setting) set(text string) error {
+	if u, ok := s.value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(text))
+	}
+	if s.value.Type() == durationType {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(d))
+		return nil
+	}
+
+	switch s.value.Kind() {
+	case reflect.String:
+		s.value.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		s.value.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(n))
+	case reflect.Slice:
+		// Lists are comma-separated
+		var items []string
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+	return nil
+}
+
+// display is the value shown in Effective
+func (s *setting) display() interface{} {
+	if s.secret {
+		if s.value.IsZero() {
+			return ""
+		}
+		return "[redacted]"
+	}
+	if m, ok := s.value.Interface().(encoding.TextMarshaler); ok {
+		text, _ := m.MarshalText()
+		return string(text)
+	}
+	if d, ok := s.value.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	if s.value.Kind() == reflect.Slice && s.value.IsNil() {
+		return []string{}
+	}
+	return s.value.Interface()
+}
@@ -0,0 +1,186 @@
+// Package cors lets browsers on allowed origins call the API. Origins come
+// from configuration and from the origins stored in PocketBase, so partner
+// sites can be added without a deploy.
+package cors
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reloadInterval is how often stored origins are reloaded, picking up edits
+// made in the PocketBase admin UI
+const reloadInterval = time.Minute
+
+// Defaults for a configuration's allowed methods and headers, and for the
+// headers browsers may read from responses
+var (
+	DefaultMethods = []string{
+		http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions,
+	}
+	DefaultHeaders = []string{
+		"Accept", "Accept-Language", "Content-Type", "Authorization", "X-API-Key", "If-None-Match", "X-Request-ID",
+	}
+	exposedHeaders = []string{
+		"ETag", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "Deprecation", "Link", "X-Request-ID",
+	}
+)
+
+// Config is the configured part of the policy
+type Config struct {
+	// Origins are "*", exact origins, or "https://*.example.com" patterns
+	// matching any subdomain
+	Origins []string
+	Methods []string
+	Headers []string
+	MaxAge  time.Duration
+}
+
+// Loader returns the stored origin patterns
+type Loader func() ([]string, error)
+
+// CORS answers preflight requests and marks responses to allowed origins
+type CORS struct {
+	config Config
+	load   Loader
+
+	mu       sync.RWMutex
+	stored   []string
+	loadedAt time.Time
+	loading  bool
+}
+
+// New creates the middleware. Empty methods and headers use the defaults.
+// load may be nil when no origins are stored.
+func New(config Config, load Loader) *CORS {
+	if len(config.Methods) == 0 {
+		config.Methods = DefaultMethods
+	}
+	if len(config.Headers) == 0 {
+		config.Headers = DefaultHeaders
+	}
+	c := &CORS{config: config, load: load}
+	c.Reload()
+	return c
+}
+
+// Origins returns the configured origin patterns
+func (c *CORS) Origins() []string {
+	return c.config.Origins
+}
+
+// Reload reads the stored origins again. Call it after changing them.
+func (c *CORS) Reload() {
+	if c.load == nil {
+		return
+	}
+	origins, err := c.load()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadedAt = time.Now()
+	if err != nil {
+		// Keep serving the origins loaded last
+		slog.Error("Error loading CORS origins", "error", err)
+		return
+	}
+	c.stored = origins
+}
+
+// Handler applies the policy to every request. Preflight requests are
+// answered without reaching next.
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	methods := strings.Join(c.config.Methods, ", ")
+	headers := strings.Join(c.config.Headers, ", ")
+	exposed := strings.Join(exposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(c.config.MaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		allowed := c.allows(origin)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				if c.config.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", maxAge)
+				}
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", exposed)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allows reports whether origin matches a configured or stored pattern
+func (c *CORS) allows(origin string) bool {
+	if Matches(c.config.Origins, origin) {
+		return true
+	}
+	c.refresh()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return Matches(c.stored, origin)
+}
+
+// refresh reloads stored origins in the background once they are stale
+func (c *CORS) refresh() {
+	if c.load == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.loading || time.Since(c.loadedAt) < reloadInterval {
+		return
+	}
+	c.loading = true
+	go func() {
+		c.Reload()
+		c.mu.Lock()
+		c.loading = false
+		c.mu.Unlock()
+	}()
+}
+
+// Matches reports whether origin matches any of patterns
+func Matches(patterns []string, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if pattern == "*" || strings.EqualFold(pattern, origin) {
+			return true
+		}
+		scheme, host, ok := strings.Cut(pattern, "://*.")
+		if !ok || !strings.EqualFold(scheme, u.Scheme) {
+			continue
+		}
+		// The wildcard matches one or more subdomain labels, not the bare
+		// domain
+		if strings.HasSuffix(strings.ToLower(u.Host), "."+strings.ToLower(host)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+// Package events fans out result changes to live subscribers such as
+// Server-Sent Events clients
+package events
+
+import (
+	"encoding/json"
+	"era/internal/models"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	TypeSnapshot = "snapshot"
+	TypeContest  = "contest"
+)
+
+// Event is a single published change
+type Event struct {
+	ID         uint64          `json:"id"`
+	Type       string          `json:"type"`
+	ElectionID string          `json:"election_id,omitempty"`
+	County     string          `json:"county"`
+	ContestID  string          `json:"contest_id,omitempty"`
+	Time       time.Time       `json:"time"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Filter selects which events a subscriber receives. Empty fields match
+// everything.
+type Filter struct {
+	ElectionID string
+	County     string
+	ContestID  string
+}
+
+// Matches reports whether an event passes the filter. Snapshot events match
+// a contest filter when they belong to the contest's county.
+func (f Filter) Matches(e Event) bool {
+	if f.ElectionID != "" && e.ElectionID != f.ElectionID {
+		return false
+	}
+	if f.County != "" && e.County != f.County {
+		return false
+	}
+	if f.ContestID != "" {
+		if e.Type == TypeContest && e.ContestID != f.ContestID {
+			return false
+		}
+		if county, _, err := models.ParseContestID(f.ContestID); err == nil && e.County != county {
+			return false
+		}
+	}
+	return true
+}
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before it is disconnected
+const subscriberBuffer = 256
+
+type subscriber struct {
+	filter Filter
+	ch     chan Event
+}
+
+// Broker keeps a bounded history of events for replay and delivers new
+// events to subscribers
+type Broker struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event
+	historySize int
+	subscribers map[*subscriber]struct{}
+	closed      bool
+}
+
+// NewBroker creates a broker that retains the last historySize events
+func NewBroker(historySize int) *Broker {
+	return &Broker{
+		nextID:      1,
+		historySize: historySize,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Publish assigns an ID to the event, records it and delivers it
+func (b *Broker) Publish(e Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e.ID = b.nextID
+	b.nextID++
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	b.history = append(b.history, e)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for sub := range b.subscribers {
+		if !sub.filter.Matches(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			// Drop subscribers that can't keep up; clients reconnect and
+			// replay from their Last-Event-ID
+			slog.Warn("Dropping slow event subscriber")
+			delete(b.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+	return e
+}
+
+// Subscribe returns the events after lastEventID that are still in history,
+// a channel of new events and a function to cancel the subscription. The
+// channel is closed when the subscription ends.
+func (b *Broker) Subscribe(filter Filter, lastEventID uint64) ([]Event, <-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	if lastEventID > 0 {
+		for _, e := range b.history {
+			if e.ID > lastEventID && filter.Matches(e) {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	sub := &subscriber{filter: filter, ch: make(chan Event, subscriberBuffer)}
+	if b.closed {
+		close(sub.ch)
+		return replay, sub.ch, func() {}
+	}
+	b.subscribers[sub] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[sub]; ok {
+			delete(b.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+	return replay, sub.ch, cancel
+}
+
+// Close ends every subscription, and any made later, so streams finish
+// when the server shuts down. Clients reconnect elsewhere and replay from
+// their Last-Event-ID.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// PublishIngest publishes a snapshot event and one contest event per changed
+// contest. It is registered as a parser manager ingest listener.
+func (b *Broker) PublishIngest(result *models.IngestResult) {
+	snapshot := result.Snapshot
+	data, err := json.Marshal(map[string]interface{}{
+		"snapshot":         snapshot,
+		"contests_changed": len(result.Changes),
+	})
+	if err != nil {
+		slog.Error("Error encoding snapshot event", "county", snapshot.County, "error", err)
+		return
+	}
+	b.Publish(Event{
+		Type:       TypeSnapshot,
+		ElectionID: snapshot.ElectionID,
+		County:     snapshot.County,
+		Data:       data,
+	})
+
+	for _, change := range result.Changes {
+		data, err := json.Marshal(change)
+		if err != nil {
+			slog.Error("Error encoding contest event", "contest_id", change.ContestID, "error", err)
+			continue
+		}
+		b.Publish(Event{
+			Type:       TypeContest,
+			ElectionID: change.ElectionID,
+			County:     change.County,
+			ContestID:  change.ContestID,
+			Data:       data,
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"era/internal/models"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// csvWriter writes rows as CSV with a header line
+type csvWriter struct {
+	w    *csv.Writer
+	cols []Column
+}
+
+func newCSVWriter(w io.Writer, cols []Column) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), cols: cols}
+
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = c.Name
+	}
+	if err := cw.w.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) WriteRow(row models.ResultRow) error {
+	record := make([]string, len(cw.cols))
+	for i, c := range cw.cols {
+		record[i] = formatCell(c.Value(row))
+	}
+	return cw.w.Write(record)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// ndjsonWriter writes one JSON object per line, keeping the column order
+type ndjsonWriter struct {
+	w    *bufio.Writer
+	cols []Column
+}
+
+func newNDJSONWriter(w io.Writer, cols []Column) *ndjsonWriter {
+	return &ndjsonWriter{w: bufio.NewWriter(w), cols: cols}
+}
+
+func (nw *ndjsonWriter) WriteRow(row models.ResultRow) error {
+	nw.w.WriteByte('{')
+	for i, c := range nw.cols {
+		if i > 0 {
+			nw.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(c.Name)
+		value, err := json.Marshal(c.Value(row))
+		if err != nil {
+			return fmt.Errorf("failed to encode column %s: %w", c.Name, err)
+		}
+		nw.w.Write(key)
+		nw.w.WriteByte(':')
+		nw.w.Write(value)
+	}
+	nw.w.WriteString("}\n")
+	return nil
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nw.w.Flush()
+}
+
+// formatCell renders a column value as text
+func formatCell(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case int:
+		return strconv.Itoa(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,137 @@
+// Package export writes election results in spreadsheet and notebook
+// friendly formats (CSV, XLSX and newline-delimited JSON)
+package export
+
+import (
+	"era/internal/models"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Format identifies an export file format
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatXLSX   Format = "xlsx"
+	FormatNDJSON Format = "ndjson"
+)
+
+// ParseFormat validates a format name, defaulting to CSV when empty
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case "":
+		return FormatCSV, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatXLSX:
+		return FormatXLSX, nil
+	case FormatNDJSON, "jsonl":
+		return FormatNDJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported export format: %s", s)
+	}
+}
+
+// ContentType returns the MIME type for the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// Column describes a selectable export column
+type Column struct {
+	Name  string
+	Value func(models.ResultRow) interface{}
+}
+
+// columns lists every exportable column in its default order
+var columns = []Column{
+	{"id", func(r models.ResultRow) interface{} { return r.ID }},
+	{"election_id", func(r models.ResultRow) interface{} { return r.ElectionID }},
+	{"county", func(r models.ResultRow) interface{} { return r.County }},
+	{"contest_id", func(r models.ResultRow) interface{} { return r.ContestID }},
+	{"type", func(r models.ResultRow) interface{} { return r.Type }},
+	{"contest_name", func(r models.ResultRow) interface{} { return r.ContestName }},
+	{"choice_name", func(r models.ResultRow) interface{} { return r.ChoiceName }},
+	{"votes", func(r models.ResultRow) interface{} { return r.Votes }},
+	{"percentage", func(r models.ResultRow) interface{} { return r.Percentage }},
+	{"is_bond", func(r models.ResultRow) interface{} { return r.IsBond }},
+	{"party", func(r models.ResultRow) interface{} { return r.Party }},
+	{"precinct", func(r models.ResultRow) interface{} { return r.Precinct }},
+	{"precincts_reporting", func(r models.ResultRow) interface{} { return r.PrecinctsReporting }},
+	{"precincts_total", func(r models.ResultRow) interface{} { return r.PrecinctsTotal }},
+	{"updated", func(r models.ResultRow) interface{} { return r.Updated.UTC().Format(time.RFC3339) }},
+}
+
+// ColumnNames returns the names of every available column
+func ColumnNames() []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// ParseColumns resolves a comma-separated column list. An empty list selects
+// every column.
+func ParseColumns(spec string) ([]Column, error) {
+	if strings.TrimSpace(spec) == "" {
+		return columns, nil
+	}
+
+	byName := make(map[string]Column, len(columns))
+	for _, c := range columns {
+		byName[c.Name] = c
+	}
+
+	var selected []Column
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		c, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q (available: %s)", name, strings.Join(ColumnNames(), ", "))
+		}
+		seen[name] = true
+		selected = append(selected, c)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no columns selected")
+	}
+	return selected, nil
+}
+
+// Writer streams result rows in a specific format
+type Writer interface {
+	// WriteRow writes a single result row
+	WriteRow(row models.ResultRow) error
+
+	// Close flushes any buffered output and finishes the document
+	Close() error
+}
+
+// NewWriter creates a writer for the format that emits the selected columns
+func NewWriter(w io.Writer, format Format, cols []Column) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, cols)
+	case FormatXLSX:
+		return newXLSXWriter(w, cols)
+	case FormatNDJSON:
+		return newNDJSONWriter(w, cols), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
@@ -0,0 +1,118 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"era/internal/models"
+	"fmt"
+	"io"
+)
+
+// The static parts of a minimal single-sheet SpreadsheetML package. Cell
+// values are written as inline strings so no shared string table is needed
+// and rows can be streamed straight into the archive.
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Results" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+// xlsxWriter streams rows into a single worksheet
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	cols  []Column
+}
+
+func newXLSXWriter(w io.Writer, cols []Column) (*xlsxWriter, error) {
+	zw := zip.NewWriter(w)
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", part.name, err)
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", part.name, err)
+		}
+	}
+
+	// The worksheet is the last entry so rows can be appended until Close
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worksheet: %w", err)
+	}
+
+	xw := &xlsxWriter{zw: zw, sheet: bufio.NewWriter(f), cols: cols}
+	xw.sheet.WriteString(xlsxSheetStart)
+
+	header := make([]interface{}, len(cols))
+	for i, c := range cols {
+		header[i] = c.Name
+	}
+	if err := xw.writeCells(header); err != nil {
+		return nil, err
+	}
+	return xw, nil
+}
+
+func (xw *xlsxWriter) WriteRow(row models.ResultRow) error {
+	values := make([]interface{}, len(xw.cols))
+	for i, c := range xw.cols {
+		values[i] = c.Value(row)
+	}
+	return xw.writeCells(values)
+}
+
+// writeCells writes a single <row> element
+func (xw *xlsxWriter) writeCells(values []interface{}) error {
+	xw.sheet.WriteString("<row>")
+	for _, v := range values {
+		switch val := v.(type) {
+		case int, float64:
+			fmt.Fprintf(xw.sheet, "<c><v>%s</v></c>", formatCell(val))
+		case bool:
+			b := "0"
+			if val {
+				b = "1"
+			}
+			fmt.Fprintf(xw.sheet, `<c t="b"><v>%s</v></c>`, b)
+		default:
+			xw.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(xw.sheet, []byte(formatCell(val))); err != nil {
+				return fmt.Errorf("failed to escape cell: %w", err)
+			}
+			xw.sheet.WriteString("</t></is></c>")
+		}
+	}
+	_, err := xw.sheet.WriteString("</row>")
+	return err
+}
+
+func (xw *xlsxWriter) Close() error {
+	xw.sheet.WriteString(xlsxSheetEnd)
+	if err := xw.sheet.Flush(); err != nil {
+		return fmt.Errorf("failed to flush worksheet: %w", err)
+	}
+	return xw.zw.Close()
+}
@@ -2,9 +2,9 @@ package formatter
 
 import (
 	"context"
+	"era/internal/logging"
 	"era/internal/models"
 	"fmt"
-	"log"
 	"strings"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/models/schema"
@@ -27,16 +27,15 @@ func intPtr(i int) *int {
 }
 
 // ensureCollection creates a single collection for all results
-func (f *ResultsFormatter) ensureCollection(countyName string) error {
-	log.Printf("=== Ensuring collection for county: %s ===", countyName)
+func (f *ResultsFormatter) ensureCollection(ctx context.Context, countyName string) error {
+	logger := logging.FromContext(ctx)
 
 	collectionName := fmt.Sprintf("county_%s_results", countyName)
 	
 	// Check if collection exists
 	collection, err := f.pb.Dao().FindCollectionByNameOrId(collectionName)
 	if err == nil {
-		log.Printf("Collection %s already exists", collectionName)
-		return nil
+		return f.migrateCollection(ctx, collection)
 	}
 
 	// Create new collection with all necessary fields
@@ -89,22 +88,85 @@ func (f *ResultsFormatter) ensureCollection(countyName string) error {
 			},
 		),
 	}
+	for _, field := range optionalFields() {
+		collection.Schema.AddField(field)
+	}
+	collection.Indexes = resultIndexes(collectionName)
 
 	if err := f.pb.Dao().SaveCollection(collection); err != nil {
 		return fmt.Errorf("failed to create collection: %w", err)
 	}
 
-	log.Printf("Successfully created collection: %s", collectionName)
+	logger.Info("Created collection", "collection", collectionName)
+	return nil
+}
+
+// optionalFields lists the fields added to county collections after the
+// original schema. They are created with new collections and added to
+// existing ones by migrateCollection.
+func optionalFields() []*schema.SchemaField {
+	return []*schema.SchemaField{
+		{Name: "election_id", Type: schema.FieldTypeText},
+		{Name: "snapshot", Type: schema.FieldTypeText},
+		{Name: "party", Type: schema.FieldTypeText},
+		{Name: "precinct", Type: schema.FieldTypeText},
+		{Name: "precincts_total", Type: schema.FieldTypeNumber},
+		{Name: "precincts_reporting", Type: schema.FieldTypeNumber},
+		{Name: "description", Type: schema.FieldTypeText},
+		{Name: "ballot_order", Type: schema.FieldTypeNumber},
+	}
+}
+
+// resultIndexes returns the indexes backing result queries, which always
+// filter by snapshot and then by type or sort by a result column
+func resultIndexes(collectionName string) []string {
+	var indexes []string
+	for _, column := range []string{"type", "contest_name", "votes", "percentage"} {
+		indexes = append(indexes, fmt.Sprintf(
+			"CREATE INDEX idx_%[1]s_snapshot_%[2]s ON %[1]s (snapshot, %[2]s)", collectionName, column))
+	}
+	return indexes
+}
+
+// migrateCollection adds fields and indexes introduced after a county
+// collection was created
+func (f *ResultsFormatter) migrateCollection(ctx context.Context, collection *pbModels.Collection) error {
+	logger := logging.FromContext(ctx)
+	changed := false
+	for _, field := range optionalFields() {
+		if collection.Schema.GetFieldByName(field.Name) != nil {
+			continue
+		}
+		logger.Info("Adding field to collection", "collection", collection.Name, "field", field.Name)
+		collection.Schema.AddField(field)
+		changed = true
+	}
+
+	existing := make(map[string]bool, len(collection.Indexes))
+	for _, index := range collection.Indexes {
+		existing[index] = true
+	}
+	for _, index := range resultIndexes(collection.Name) {
+		if existing[index] {
+			continue
+		}
+		logger.Info("Adding index to collection", "collection", collection.Name, "index", index)
+		collection.Indexes = append(collection.Indexes, index)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	if err := f.pb.Dao().SaveCollection(collection); err != nil {
+		return fmt.Errorf("failed to update collection: %w", err)
+	}
 	return nil
 }
 
 // ProcessEntry processes and stores a single entry
 func (f *ResultsFormatter) ProcessEntry(ctx context.Context, entry *models.ElectionEntry) error {
-	log.Printf("Processing entry for county: %s", entry.CountyID)
-	log.Printf("Entry details - Title: %s, Choice: %s", entry.Title, entry.ChoiceName)
-
-	if err := f.ensureCollection(entry.CountyID); err != nil {
-		log.Printf("Error ensuring collection: %v", err)
+	if err := f.ensureCollection(ctx, entry.CountyID); err != nil {
 		return fmt.Errorf("failed to ensure collection: %w", err)
 	}
 
@@ -132,6 +194,14 @@ func (f *ResultsFormatter) ProcessEntry(ctx context.Context, entry *models.Elect
 	record.Set("choice_name", entry.ChoiceName)
 	record.Set("votes", entry.Votes)
 	record.Set("percentage", entry.Percentage)
+	record.Set("election_id", entry.ElectionID)
+	record.Set("snapshot", entry.SnapshotID)
+	record.Set("party", entry.Party)
+	record.Set("description", entry.Description)
+	record.Set("precinct", entry.Precinct)
+	record.Set("precincts_total", entry.PrecinctsTotal)
+	record.Set("precincts_reporting", entry.PrecinctsReporting)
+	record.Set("ballot_order", entry.BallotOrder)
 	if entryType == "measure" {
 		record.Set("is_bond", isBond)
 	}
@@ -140,7 +210,8 @@ func (f *ResultsFormatter) ProcessEntry(ctx context.Context, entry *models.Elect
 		return fmt.Errorf("failed to save record: %w", err)
 	}
 
-	log.Printf("Successfully saved %s record for %s", entryType, entry.CountyID)
+	// Logged once per row, so sampled by the parser
+	logging.FromContext(ctx).Debug("Saved result", "type", entryType, "contest", entry.Title, "choice", entry.ChoiceName)
 	return nil
 }
 
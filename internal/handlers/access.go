@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"era/internal/api"
+	"era/internal/auth"
+	"fmt"
+	"net/http"
+)
+
+// principalName names the client that made an authenticated request, for
+// audit logs
+func principalName(r *http.Request) string {
+	if p := auth.FromContext(r.Context()); p != nil {
+		return p.Name
+	}
+	return "anonymous"
+}
+
+// electionAllowed reports whether the request's API key may act on an
+// election. Public routes have no key and aren't limited.
+func electionAllowed(r *http.Request, electionID string) bool {
+	p := auth.FromContext(r.Context())
+	return p == nil || p.CanAccessElection(electionID)
+}
+
+// requireElection writes a 403 and returns false when the request's API key
+// is limited to other elections
+func requireElection(w http.ResponseWriter, r *http.Request, electionID string) bool {
+	if electionAllowed(r, electionID) {
+		return true
+	}
+	message := fmt.Sprintf("This API key can't access election %q", electionID)
+	if electionID == "" {
+		message = "This API key is limited to some elections; an election_id is required"
+	}
+	api.HTTPError(w, message, http.StatusForbidden)
+	return false
+}
+
+// ElectionFromPath reads the election a request names from the {id} path
+// value
+func ElectionFromPath(r *http.Request) string {
+	return r.PathValue("id")
+}
+
+// ElectionFromQuery reads the election a request names from ?election_id=
+func ElectionFromQuery(r *http.Request) string {
+	return r.URL.Query().Get("election_id")
+}
+
+// ElectionScoped refuses API keys limited to other elections than the one
+// the request names. It runs ahead of the response cache, so cached
+// responses are checked too.
+func ElectionScoped(electionOf func(*http.Request) string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if requireElection(w, r, electionOf(r)) {
+			next(w, r)
+		}
+	}
+}
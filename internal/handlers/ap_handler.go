@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"era/internal/apfeed"
+	"era/internal/api"
+	"era/internal/models"
+	"net/http"
+	"strings"
+)
+
+// defaultStatePostal is used when a feed request doesn't name a state
+const defaultStatePostal = "CA"
+
+// AP Compatibility Handlers
+//
+// HandleGetAPFeed returns an election's results in an AP Elections API-like
+// shape. Supported query parameters:
+//   - level:       comma-separated reporting unit levels (state, county, precinct)
+//   - statepostal: state postal code reported on every unit, defaults to CA
+func (h *CountyHandler) HandleGetAPFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	electionID := r.PathValue("id")
+	if electionID == "" {
+		api.HTTPError(w, "Election ID is required", http.StatusBadRequest)
+		return
+	}
+
+	levels, err := apfeed.ParseLevels(r.URL.Query().Get("level"))
+	if err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	statePostal := strings.ToUpper(r.URL.Query().Get("statepostal"))
+	if statePostal == "" {
+		statePostal = defaultStatePostal
+	}
+
+	rows, err := h.store.GetResults(models.ResultFilter{ElectionID: electionID})
+	if err != nil {
+		requestLogger(r).Error("Error fetching results", "election_id", electionID, "error", err)
+		api.HTTPError(w, "Error fetching results", http.StatusInternalServerError)
+		return
+	}
+	if len(rows) == 0 {
+		api.HTTPError(w, "Election results not found", http.StatusNotFound)
+		return
+	}
+
+	calls, err := h.store.GetRaceCalls(electionID)
+	if err != nil {
+		requestLogger(r).Error("Error fetching race calls", "election_id", electionID, "error", err)
+		api.HTTPError(w, "Error fetching race calls", http.StatusInternalServerError)
+		return
+	}
+
+	feed := apfeed.Build(electionID, rows, calls, apfeed.Options{
+		StatePostal: statePostal,
+		Levels:      levels,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feed)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"era/internal/api"
+	"era/internal/auth"
+	"era/internal/models"
+	"era/internal/storage"
+	"net/http"
+)
+
+// APIKeyHandler manages the API keys clients authenticate with
+type APIKeyHandler struct {
+	store *storage.PocketBaseStore
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(store *storage.PocketBaseStore) *APIKeyHandler {
+	return &APIKeyHandler{store: store}
+}
+
+// HandleCreateAPIKey generates a key with the requested scopes. The key is
+// only returned in this response; afterwards only its prefix is shown.
+func (h *APIKeyHandler) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var key models.APIKey
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		api.HTTPError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := key.Validate(); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	secret, prefix, err := auth.NewKey()
+	if err != nil {
+		requestLogger(r).Error("Error generating API key", "error", err)
+		api.HTTPError(w, "Error creating API key", http.StatusInternalServerError)
+		return
+	}
+	key.Prefix = prefix
+	key.LastUsedAt = nil
+
+	if err := h.store.SaveAPIKey(&key, auth.HashKey(secret)); err != nil {
+		requestLogger(r).Error("Error saving API key", "error", err)
+		api.HTTPError(w, "Error saving API key", http.StatusInternalServerError)
+		return
+	}
+	key.Key = secret
+
+	requestLogger(r).Info("API key created", "key_id", key.ID, "name", key.Name, "role", key.Role,
+		"scopes", key.Scopes, "elections", key.Elections, "by", principalName(r))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+func (h *APIKeyHandler) HandleGetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	keys, err := h.store.GetAPIKeys()
+	if err != nil {
+		requestLogger(r).Error("Error fetching API keys", "error", err)
+		api.HTTPError(w, "Error fetching API keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+func (h *APIKeyHandler) HandleGetAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key, err := h.store.GetAPIKey(r.PathValue("id"))
+	if err != nil {
+		api.HTTPError(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(key)
+}
+
+// HandleDeleteAPIKey revokes a key. Requests using it are refused at once.
+func (h *APIKeyHandler) HandleDeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := h.store.DeleteAPIKey(id); err != nil {
+		api.HTTPError(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	requestLogger(r).Info("API key revoked", "key_id", id, "by", principalName(r))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "API key revoked successfully"})
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"bytes"
+	"era/internal/api"
+	"era/internal/cards"
+	"era/internal/charts"
+	"era/internal/models"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	pb "github.com/pocketbase/pocketbase/models"
+)
+
+// Share of yes votes a measure needs to pass, unless the request overrides it
+const (
+	majorityThreshold = 50.0
+	bondThreshold     = 55.0
+)
+
+// contestChart draws a contest as a yes/no split bar when it is a measure
+// with yes and no choices, or as a bar chart otherwise
+func contestChart(title, resultType string, rows []models.ResultRow, threshold float64) string {
+	totals := models.ChoiceTotals(rows)
+
+	if resultType == "measure" {
+		yes, no, found := 0, 0, 0
+		for _, total := range totals {
+			switch {
+			case hasWord(total.Name, "yes"):
+				yes += total.Votes
+				found |= 1
+			case hasWord(total.Name, "no"):
+				no += total.Votes
+				found |= 2
+			}
+		}
+		if found == 3 {
+			if threshold == 0 {
+				threshold = majorityThreshold
+				if len(rows) > 0 && rows[0].IsBond {
+					threshold = bondThreshold
+				}
+			}
+			return charts.SplitBar(title, yes, no, threshold)
+		}
+	}
+
+	bars := make([]charts.Bar, len(totals))
+	for i, total := range totals {
+		bars[i] = charts.Bar{
+			Label:      total.Name,
+			Votes:      total.Votes,
+			Percentage: total.Percentage,
+			Highlight:  i == 0 && total.Votes > 0,
+		}
+	}
+	return charts.BarChart(title, bars)
+}
+
+// hasWord reports whether name contains word as a whole word, ignoring case
+func hasWord(name, word string) bool {
+	for _, field := range strings.Fields(strings.ToLower(name)) {
+		if field == word {
+			return true
+		}
+	}
+	return false
+}
+
+// recordCharts draws a chart for every contest in a county's records, keyed
+// by contest name, for inlining into the HTML templates
+func recordCharts(records []*pb.Record) map[string]template.HTML {
+	byContest := make(map[string][]models.ResultRow)
+	types := make(map[string]string)
+	for _, record := range records {
+		name := record.GetString("contest_name")
+		types[name] = record.GetString("type")
+		byContest[name] = append(byContest[name], models.ResultRow{
+			ChoiceName: record.GetString("choice_name"),
+			Votes:      record.GetInt("votes"),
+			Precinct:   record.GetString("precinct"),
+			IsBond:     record.GetBool("is_bond"),
+		})
+	}
+
+	result := make(map[string]template.HTML, len(byContest))
+	for name, rows := range byContest {
+		// Charts are built from escaped strings, so they are safe to inline
+		result[name] = template.HTML(contestChart(name, types[name], rows, 0))
+	}
+	return result
+}
+
+// parseThreshold reads the optional ?threshold= share a measure needs to pass
+func parseThreshold(r *http.Request) (float64, error) {
+	value := r.URL.Query().Get("threshold")
+	if value == "" {
+		return 0, nil
+	}
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil || threshold <= 0 || threshold >= 100 {
+		return 0, errors.New("threshold must be a percentage between 0 and 100")
+	}
+	return threshold, nil
+}
+
+func writeSVG(w http.ResponseWriter, svg string) {
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write([]byte(svg))
+}
+
+// Chart Handlers
+//
+// HandleContestChart renders a contest as an SVG image: a split bar with a
+// pass threshold for yes/no measures, horizontal bars otherwise. Measures
+// need a simple majority, or 55% for bonds, unless ?threshold= is given.
+func (h *CountyHandler) HandleContestChart(w http.ResponseWriter, r *http.Request) {
+	contestID := r.PathValue("id")
+	if _, _, err := models.ParseContestID(contestID); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	threshold, err := parseThreshold(r)
+	if err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.store.GetResults(models.ResultFilter{ContestID: contestID})
+	if err != nil {
+		requestLogger(r).Error("Error fetching results", "contest_id", contestID, "error", err)
+		api.HTTPError(w, "Error fetching results", http.StatusInternalServerError)
+		return
+	}
+	if len(rows) == 0 {
+		api.HTTPError(w, "Contest not found", http.StatusNotFound)
+		return
+	}
+
+	writeSVG(w, contestChart(rows[0].ContestName, rows[0].Type, rows, threshold))
+}
+
+// HandleContestCard renders a contest's leaders, vote shares and last update
+// as a PNG share image for Open Graph and social previews
+func (h *CountyHandler) HandleContestCard(w http.ResponseWriter, r *http.Request) {
+	contestID := r.PathValue("id")
+	county, _, err := models.ParseContestID(contestID)
+	if err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.store.GetResults(models.ResultFilter{ContestID: contestID})
+	if err != nil {
+		requestLogger(r).Error("Error fetching results", "contest_id", contestID, "error", err)
+		api.HTTPError(w, "Error fetching results", http.StatusInternalServerError)
+		return
+	}
+	if len(rows) == 0 {
+		api.HTTPError(w, "Contest not found", http.StatusNotFound)
+		return
+	}
+
+	calls, err := h.store.GetRaceCalls("")
+	if err != nil {
+		requestLogger(r).Error("Error fetching race calls", "error", err)
+		api.HTTPError(w, "Error fetching race calls", http.StatusInternalServerError)
+		return
+	}
+
+	card := cards.Card{
+		Title:    rows[0].ContestName,
+		Subtitle: countyDisplayName(county) + " County",
+		Choices:  models.ChoiceTotals(rows),
+		Winner:   calls[contestID].Winner,
+	}
+	for _, row := range rows {
+		if row.Updated.After(card.Updated) {
+			card.Updated = row.Updated
+		}
+		if row.PrecinctsTotal > card.PrecinctsTotal {
+			card.PrecinctsTotal = row.PrecinctsTotal
+			card.PrecinctsReporting = row.PrecinctsReporting
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := cards.Render(&buf, card); err != nil {
+		requestLogger(r).Error("Error rendering card", "contest_id", contestID, "error", err)
+		api.HTTPError(w, "Error rendering card", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(buf.Bytes())
+}
+
+// countyDisplayName turns a county slug back into its name, e.g. "san_mateo"
+// becomes "San Mateo"
+func countyDisplayName(slug string) string {
+	words := strings.Fields(strings.ReplaceAll(slug, "_", " "))
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// HandleContestPrecinctMap renders a contest's precinct results as an SVG
+// choropleth over the county's bundled precinct boundaries. Precincts are
+// colored by their leading choice, or by one choice's share with ?choice=.
+func (h *CountyHandler) HandleContestPrecinctMap(w http.ResponseWriter, r *http.Request) {
+	contestID := r.PathValue("id")
+	county, _, err := models.ParseContestID(contestID)
+	if err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	boundaries, err := charts.PrecinctBoundaries(county)
+	if errors.Is(err, charts.ErrNoBoundaries) {
+		api.HTTPError(w, "No precinct boundaries for county "+county, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		requestLogger(r).Error("Error loading precinct boundaries", "county", county, "error", err)
+		api.HTTPError(w, "Error loading boundaries", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := h.store.GetResults(models.ResultFilter{ContestID: contestID})
+	if err != nil {
+		requestLogger(r).Error("Error fetching results", "contest_id", contestID, "error", err)
+		api.HTTPError(w, "Error fetching results", http.StatusInternalServerError)
+		return
+	}
+
+	byPrecinct := make(map[string][]models.ResultRow)
+	for _, row := range rows {
+		if row.Precinct != "" {
+			byPrecinct[row.Precinct] = append(byPrecinct[row.Precinct], row)
+		}
+	}
+	if len(byPrecinct) == 0 {
+		api.HTTPError(w, "Contest has no precinct results", http.StatusNotFound)
+		return
+	}
+
+	writeMap(w, r, rows[0].ContestName, boundaries, charts.PrecinctProperty, rows, byPrecinct)
+}
+
+// HandleElectionContestMap renders one contest across every county of an
+// election as an SVG choropleth over the bundled county boundaries. The
+// contest is addressed by its slug, which is shared by every county running
+// it. Counties are colored by their leading choice, or by one choice's share
+// with ?choice=.
+func (h *CountyHandler) HandleElectionContestMap(w http.ResponseWriter, r *http.Request) {
+	electionID := r.PathValue("id")
+	contest := r.PathValue("contest")
+
+	boundaries, err := charts.CountyBoundaries()
+	if err != nil {
+		requestLogger(r).Error("Error loading county boundaries", "error", err)
+		api.HTTPError(w, "Error loading boundaries", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := h.store.GetResults(models.ResultFilter{ElectionID: electionID})
+	if err != nil {
+		requestLogger(r).Error("Error fetching results", "election_id", electionID, "error", err)
+		api.HTTPError(w, "Error fetching results", http.StatusInternalServerError)
+		return
+	}
+
+	// Boundaries are keyed by display name, results by county slug
+	names := make(map[string]string)
+	for _, feature := range boundaries.Features {
+		name := feature.Name(charts.CountyProperty)
+		names[models.CountySlug(name)] = name
+	}
+
+	var contestRows []models.ResultRow
+	byCounty := make(map[string][]models.ResultRow)
+	for _, row := range rows {
+		if models.ContestSlug(row.ContestName) != contest {
+			continue
+		}
+		contestRows = append(contestRows, row)
+		if name, ok := names[row.County]; ok {
+			byCounty[name] = append(byCounty[name], row)
+		}
+	}
+	if len(contestRows) == 0 {
+		api.HTTPError(w, "Contest not found", http.StatusNotFound)
+		return
+	}
+
+	writeMap(w, r, contestRows[0].ContestName, boundaries, charts.CountyProperty, contestRows, byCounty)
+}
+
+// writeMap shades each region from its rows and writes the map
+func writeMap(w http.ResponseWriter, r *http.Request, title string, boundaries *charts.FeatureCollection, property string, all []models.ResultRow, byRegion map[string][]models.ResultRow) {
+	regions := make(map[string][]models.ChoiceTotal, len(byRegion))
+	for name, rows := range byRegion {
+		regions[name] = models.ChoiceTotals(rows)
+	}
+
+	var shades map[string]charts.Shade
+	var legend []charts.LegendItem
+	if choice := r.URL.Query().Get("choice"); choice != "" {
+		shades, legend = charts.ShareShades(regions, choice)
+	} else {
+		// Order choices by their overall votes so colors match the charts
+		overall := models.ChoiceTotals(all)
+		choices := make([]string, len(overall))
+		for i, total := range overall {
+			choices[i] = total.Name
+		}
+		shades, legend = charts.LeaderShades(regions, choices)
+	}
+
+	svg, err := charts.Choropleth(title, boundaries, property, shades, legend)
+	if err != nil {
+		requestLogger(r).Error("Error rendering map", "title", title, "error", err)
+		api.HTTPError(w, "Error rendering map", http.StatusInternalServerError)
+		return
+	}
+	writeSVG(w, svg)
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"encoding/json"
+	"era/internal/api"
+	"era/internal/config"
+	"net/http"
+)
+
+// ConfigHandler shows admins the settings the server is running with
+type ConfigHandler struct {
+	config *config.Config
+}
+
+// NewConfigHandler creates a new config handler
+func NewConfigHandler(cfg *config.Config) *ConfigHandler {
+	return &ConfigHandler{config: cfg}
+}
+
+// HandleGetConfig lists every effective setting and where it came from.
+// Secrets are redacted.
+func (h *ConfigHandler) HandleGetConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.config.Effective())
+}
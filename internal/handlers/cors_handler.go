@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"era/internal/api"
+	"era/internal/cors"
+	"era/internal/models"
+	"era/internal/storage"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CORSHandler manages the origins allowed to call the API from browsers
+type CORSHandler struct {
+	store *storage.PocketBaseStore
+	cors  *cors.CORS
+}
+
+// NewCORSHandler creates a new CORS origin handler
+func NewCORSHandler(store *storage.PocketBaseStore, policy *cors.CORS) *CORSHandler {
+	return &CORSHandler{store: store, cors: policy}
+}
+
+// CORSOrigins lists the configured origins and the stored ones, which can be
+// changed through the API
+type CORSOrigins struct {
+	Configured []string            `json:"configured"`
+	Stored     []models.CORSOrigin `json:"stored"`
+}
+
+func (h *CORSHandler) HandleGetCORSOrigins(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stored, err := h.store.GetCORSOrigins()
+	if err != nil {
+		requestLogger(r).Error("Error fetching CORS origins", "error", err)
+		api.HTTPError(w, "Error fetching CORS origins", http.StatusInternalServerError)
+		return
+	}
+
+	configured := h.cors.Origins()
+	if configured == nil {
+		configured = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CORSOrigins{Configured: configured, Stored: stored})
+}
+
+// HandleCreateCORSOrigin allows another origin, taking effect at once
+func (h *CORSHandler) HandleCreateCORSOrigin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var origin models.CORSOrigin
+	if err := json.NewDecoder(r.Body).Decode(&origin); err != nil {
+		api.HTTPError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	origin.Origin = strings.TrimSuffix(strings.TrimSpace(origin.Origin), "/")
+	if err := origin.Validate(); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stored, err := h.store.GetCORSOrigins()
+	if err != nil {
+		requestLogger(r).Error("Error fetching CORS origins", "error", err)
+		api.HTTPError(w, "Error fetching CORS origins", http.StatusInternalServerError)
+		return
+	}
+	for _, existing := range stored {
+		if strings.EqualFold(existing.Origin, origin.Origin) {
+			api.HTTPError(w, fmt.Sprintf("Origin %q is already allowed", origin.Origin), http.StatusConflict)
+			return
+		}
+	}
+
+	if err := h.store.SaveCORSOrigin(&origin); err != nil {
+		requestLogger(r).Error("Error saving CORS origin", "origin", origin.Origin, "error", err)
+		api.HTTPError(w, "Error saving CORS origin", http.StatusInternalServerError)
+		return
+	}
+	h.cors.Reload()
+
+	requestLogger(r).Info("CORS origin allowed", "origin", origin.Origin, "by", principalName(r))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(origin)
+}
+
+// HandleDeleteCORSOrigin stops allowing a stored origin
+func (h *CORSHandler) HandleDeleteCORSOrigin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := h.store.DeleteCORSOrigin(id); err != nil {
+		api.HTTPError(w, "CORS origin not found", http.StatusNotFound)
+		return
+	}
+	h.cors.Reload()
+
+	requestLogger(r).Info("CORS origin removed", "id", id, "by", principalName(r))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "CORS origin removed successfully"})
+}
@@ -1,23 +1,27 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"era/internal/api"
+	"era/internal/i18n"
 	"era/internal/models"
 	"era/internal/parser"
 	"era/internal/storage"
+	"era/internal/templates"
+	"era/internal/urlpolicy"
+	"errors"
 	"fmt"
-	"github.com/pocketbase/dbx"
 	pb "github.com/pocketbase/pocketbase/models"
 	"html/template"
-	"log"
 	"net/http"
-	"strings"
+	"strconv"
 )
 
 // Type definitions
 type MeasureGroup struct {
 	Title    string
+	Anchor   string
+	Chart    template.HTML
 	Measures []Measure
 }
 
@@ -30,6 +34,8 @@ type Measure struct {
 
 type Race struct {
 	Title      string
+	Anchor     string
+	Chart      template.HTML
 	Candidates []Candidate
 }
 
@@ -51,23 +57,107 @@ type ParseRequest struct {
 	Link        string `json:"link"`
 	ParseMethod string `json:"parse_method"`
 	ResultType  string `json:"result_type"` // "measures" or "candidates"
+	ElectionID  string `json:"election_id,omitempty"`
 }
 
 type BulkParseRequest struct {
 	Links []ParseRequest `json:"links"`
 }
 
+// MessageResponse acknowledges a request that returns no data
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// ParseResponse reports a successful parse and the snapshot it created
+type ParseResponse struct {
+	Message  string `json:"message"`
+	Snapshot string `json:"snapshot"`
+}
+
+type BulkSaveResponse struct {
+	TotalSubmitted int      `json:"total_submitted"`
+	SavedCount     int      `json:"saved_count"`
+	Errors         []string `json:"errors,omitempty"`
+}
+
+type BulkParseResponse struct {
+	Results    []Result `json:"results"`
+	Total      int      `json:"total"`
+	Successful int      `json:"successful"`
+}
+
+type MethodParseResponse struct {
+	TotalCounties int      `json:"total_counties"`
+	Processed     int      `json:"processed"`
+	Successful    int      `json:"successful"`
+	Failed        []string `json:"failed,omitempty"`
+}
+
+// CountyResult is a single result row. The translated names are set when
+// the request asks for a language the contest has a translation into.
+type CountyResult struct {
+	ID                    string  `json:"id"`
+	Type                  string  `json:"type"`
+	ContestName           string  `json:"contest_name"`
+	ChoiceName            string  `json:"choice_name"`
+	Votes                 int     `json:"votes"`
+	Percentage            float64 `json:"percentage"`
+	IsBond                bool    `json:"is_bond,omitempty"`
+	ContestNameTranslated string  `json:"contest_name_translated,omitempty"`
+	ChoiceNameTranslated  string  `json:"choice_name_translated,omitempty"`
+}
+
+type CountyResultsResponse struct {
+	Total      int            `json:"total"`
+	Page       int            `json:"page,omitempty"`
+	PerPage    int            `json:"per_page"`
+	TotalPages int            `json:"total_pages"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Results    []CountyResult `json:"results"`
+}
+
+type CleanupResponse struct {
+	Deleted []string `json:"deleted"`
+	Skipped []string `json:"skipped"`
+	Message string   `json:"message"`
+}
+
+// ingestRequest converts a direct parse request for the parser manager
+func (req ParseRequest) ingestRequest() parser.IngestRequest {
+	return parser.IngestRequest{
+		CountyName: req.CountyName,
+		ElectionID: req.ElectionID,
+		Method:     req.ParseMethod,
+		URL:        req.Link,
+	}
+}
+
+// countyLinkIngestRequest converts a stored county link for the parser manager
+func countyLinkIngestRequest(link *models.CountyLink) parser.IngestRequest {
+	return parser.IngestRequest{
+		CountyName: link.CountyName,
+		ElectionID: link.ElectionID,
+		Method:     string(link.ParseMethod),
+		URL:        link.Link,
+	}
+}
+
 // CountyHandler definition
 type CountyHandler struct {
-	store   *storage.PocketBaseStore
-	manager *parser.ParserManager
+	store     *storage.PocketBaseStore
+	manager   *parser.ParserManager
+	refresher *parser.Refresher
+	templates *templates.Sets
 }
 
 // Helper functions
-func NewCountyHandler(store *storage.PocketBaseStore, manager *parser.ParserManager) *CountyHandler {
+func NewCountyHandler(store *storage.PocketBaseStore, manager *parser.ParserManager, refresher *parser.Refresher) *CountyHandler {
 	return &CountyHandler{
-		store:   store,
-		manager: manager,
+		store:     store,
+		manager:   manager,
+		refresher: refresher,
+		templates: templates.NewSets(),
 	}
 }
 
@@ -88,61 +178,43 @@ func filter(results []Result, fn func(Result) bool) []Result {
 	return filtered
 }
 
-// Add this helper function at the top with other helper functions
-func enableCORS(w http.ResponseWriter, r *http.Request) {
-	// Allow both localhost and deployed frontend
-	origins := []string{
-		"http://localhost:5173",
-		"https://era-fe-sparkling-sun-7787.fly.dev",
-	}
-	
-	// Get the origin from the request header
-	origin := r.Header.Get("Origin")
-	
-	// Check if the origin is allowed
-	for _, allowedOrigin := range origins {
-		if origin == allowedOrigin {
-			w.Header().Set("Access-Control-Allow-Origin", origin)
-			break
-		}
-	}
-	
-	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization")
-}
-
 // County Link Management Handlers
 func (h *CountyHandler) HandleSaveCountyLink(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var countyLink models.CountyLink
 	if err := json.NewDecoder(r.Body).Decode(&countyLink); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		api.HTTPError(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
 	if err := countyLink.Validate(); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.manager.CheckURL(countyLink.Link); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !requireElection(w, r, countyLink.ElectionID) {
 		return
 	}
 
 	if err := h.store.SaveCountyLink(&countyLink); err != nil {
-		http.Error(w, "Error saving county link", http.StatusInternalServerError)
+		api.HTTPError(w, "Error saving county link", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "County link saved successfully",
-	})
+	json.NewEncoder(w).Encode(MessageResponse{Message: "County link saved successfully"})
 }
 
 func (h *CountyHandler) HandleGetCountyLink(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -150,16 +222,27 @@ func (h *CountyHandler) HandleGetCountyLink(w http.ResponseWriter, r *http.Reque
 	if id == "" {
 		links, err := h.store.GetAllCountyLinks()
 		if err != nil {
-			http.Error(w, "Error fetching county links", http.StatusInternalServerError)
+			api.HTTPError(w, "Error fetching county links", http.StatusInternalServerError)
 			return
 		}
-		json.NewEncoder(w).Encode(links)
+
+		// Keys limited to some elections only see those elections' links
+		visible := make([]models.CountyLink, 0, len(links))
+		for _, link := range links {
+			if electionAllowed(r, link.ElectionID) {
+				visible = append(visible, link)
+			}
+		}
+		json.NewEncoder(w).Encode(visible)
 		return
 	}
 
 	link, err := h.store.GetCountyLink(id)
 	if err != nil {
-		http.Error(w, "County link not found", http.StatusNotFound)
+		api.HTTPError(w, "County link not found", http.StatusNotFound)
+		return
+	}
+	if !requireElection(w, r, link.ElectionID) {
 		return
 	}
 
@@ -168,77 +251,103 @@ func (h *CountyHandler) HandleGetCountyLink(w http.ResponseWriter, r *http.Reque
 
 func (h *CountyHandler) HandleUpdateCountyLink(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	id := r.PathValue("id")
 	if id == "" {
-		http.Error(w, "ID is required", http.StatusBadRequest)
+		api.HTTPError(w, "ID is required", http.StatusBadRequest)
 		return
 	}
 
 	var countyLink models.CountyLink
 	if err := json.NewDecoder(r.Body).Decode(&countyLink); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		api.HTTPError(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
 	if err := countyLink.Validate(); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.manager.CheckURL(countyLink.Link); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.store.GetCountyLink(id)
+	if err != nil {
+		api.HTTPError(w, "County link not found", http.StatusNotFound)
+		return
+	}
+	if !requireElection(w, r, existing.ElectionID) || !requireElection(w, r, countyLink.ElectionID) {
 		return
 	}
 
 	if err := h.store.UpdateCountyLink(id, &countyLink); err != nil {
-		http.Error(w, "Error updating county link", http.StatusInternalServerError)
+		api.HTTPError(w, "Error updating county link", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "County link updated successfully",
-	})
+	json.NewEncoder(w).Encode(MessageResponse{Message: "County link updated successfully"})
 }
 
 func (h *CountyHandler) HandleDeleteCountyLink(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	id := r.PathValue("id")
 	if id == "" {
-		http.Error(w, "ID is required", http.StatusBadRequest)
+		api.HTTPError(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.store.GetCountyLink(id)
+	if err != nil {
+		api.HTTPError(w, "County link not found", http.StatusNotFound)
+		return
+	}
+	if !requireElection(w, r, existing.ElectionID) {
 		return
 	}
 
 	if err := h.store.DeleteCountyLink(id); err != nil {
-		http.Error(w, "Error deleting county link", http.StatusInternalServerError)
+		api.HTTPError(w, "Error deleting county link", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "County link deleted successfully",
-	})
+	json.NewEncoder(w).Encode(MessageResponse{Message: "County link deleted successfully"})
 }
 
 func (h *CountyHandler) HandleBulkSaveCountyLinks(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var countyLinks []models.CountyLink
 	if err := json.NewDecoder(r.Body).Decode(&countyLinks); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		api.HTTPError(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
 	// Validate all links before saving
 	for i, link := range countyLinks {
 		if err := link.Validate(); err != nil {
-			http.Error(w, fmt.Sprintf("Invalid link at index %d: %s", i, err.Error()), http.StatusBadRequest)
+			api.HTTPError(w, fmt.Sprintf("Invalid link at index %d: %s", i, err.Error()), http.StatusBadRequest)
+			return
+		}
+		if err := h.manager.CheckURL(link.Link); err != nil {
+			api.HTTPError(w, fmt.Sprintf("Invalid link at index %d: %s", i, err.Error()), http.StatusBadRequest)
+			return
+		}
+		if !electionAllowed(r, link.ElectionID) {
+			api.HTTPError(w, fmt.Sprintf("Link at index %d is for election %q, which this API key can't access", i, link.ElectionID), http.StatusForbidden)
 			return
 		}
 	}
@@ -255,12 +364,10 @@ func (h *CountyHandler) HandleBulkSaveCountyLinks(w http.ResponseWriter, r *http
 	}
 
 	// Prepare response
-	response := map[string]interface{}{
-		"total_submitted": len(countyLinks),
-		"saved_count":    savedCount,
-	}
-	if len(errors) > 0 {
-		response["errors"] = errors
+	response := BulkSaveResponse{
+		TotalSubmitted: len(countyLinks),
+		SavedCount:     savedCount,
+		Errors:         errors,
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -270,120 +377,93 @@ func (h *CountyHandler) HandleBulkSaveCountyLinks(w http.ResponseWriter, r *http
 // Parsing Handlers
 func (h *CountyHandler) HandleParseCountyLink(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Get ID from path
 	id := r.PathValue("id")
 	if id == "" {
-		http.Error(w, "ID is required", http.StatusBadRequest)
+		api.HTTPError(w, "ID is required", http.StatusBadRequest)
 		return
 	}
 
 	// Get the county link
 	countyLink, err := h.store.GetCountyLink(id)
 	if err != nil {
-		http.Error(w, "County link not found", http.StatusNotFound)
+		api.HTTPError(w, "County link not found", http.StatusNotFound)
 		return
 	}
-
-	// Get the parser
-	p, err := h.manager.GetParser(string(countyLink.ParseMethod))
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get parser: %v", err), http.StatusInternalServerError)
+	if !requireElection(w, r, countyLink.ElectionID) {
 		return
 	}
 
-	// Set county name for the parser
-	p.SetCountyName(countyLink.CountyName)
-
-	// Parse the URL
+	// Parse the URL into a new snapshot
 	ctx := r.Context()
-	if err := p.Parse(ctx, countyLink.Link); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to parse data: %v", err), http.StatusInternalServerError)
+	result, err := h.manager.Ingest(ctx, countyLinkIngestRequest(countyLink))
+	if err != nil {
+		api.HTTPError(w, fmt.Sprintf("Failed to parse data: %v", err), parseErrorStatus(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": fmt.Sprintf("Successfully parsed data for county: %s", countyLink.CountyName),
+	json.NewEncoder(w).Encode(ParseResponse{
+		Message:  fmt.Sprintf("Successfully parsed data for county: %s", countyLink.CountyName),
+		Snapshot: result.Snapshot.ID,
 	})
 }
 
 func (h *CountyHandler) HandleBulkParseByMethod(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	parseMethod := r.PathValue("method")
-	log.Printf("Starting bulk parse for method: %s", parseMethod)
+	logger := requestLogger(r).With("method", parseMethod)
+	logger.Info("Starting bulk parse")
 
 	links, err := h.store.GetAllCountyLinks()
 	if err != nil {
-		log.Printf("Error fetching county links: %v", err)
-		http.Error(w, "Error fetching county links", http.StatusInternalServerError)
+		logger.Error("Error fetching county links", "error", err)
+		api.HTTPError(w, "Error fetching county links", http.StatusInternalServerError)
 		return
 	}
-	log.Printf("Found %d total county links", len(links))
 
 	if len(links) == 0 {
-		log.Printf("No county links found")
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"message": "No county links found to process",
-		})
+		json.NewEncoder(w).Encode(MessageResponse{Message: "No county links found to process"})
 		return
 	}
 
-	var results struct {
-		TotalCounties int      `json:"total_counties"`
-		Processed     int      `json:"processed"`
-		Successful    int      `json:"successful"`
-		Failed        []string `json:"failed,omitempty"`
-	}
+	var results MethodParseResponse
 
 	// Process each matching county
 	for _, link := range links {
-		log.Printf("Examining county: %s (Method: %s)", link.CountyName, link.ParseMethod)
-		
 		if string(link.ParseMethod) != parseMethod {
-			log.Printf("Skipping county %s - different parse method (%s != %s)", 
-				link.CountyName, link.ParseMethod, parseMethod)
 			continue
 		}
-		results.TotalCounties++
-
-		log.Printf("Processing county: %s with URL: %s", link.CountyName, link.Link)
-		
-		p, err := h.manager.GetParser(parseMethod)
-		if err != nil {
-			errMsg := fmt.Sprintf("County %s: Failed to get parser: %v", link.CountyName, err)
-			log.Printf("Error: %s", errMsg)
-			results.Failed = append(results.Failed, errMsg)
+		if !electionAllowed(r, link.ElectionID) {
+			logger.Debug("Skipping county, election not allowed", "county", link.CountyName,
+				"election_id", link.ElectionID, "principal", principalName(r))
 			continue
 		}
+		results.TotalCounties++
 
-		log.Printf("Setting county name for parser: %s", link.CountyName)
-		p.SetCountyName(link.CountyName)
-
-		log.Printf("Starting parse for county %s", link.CountyName)
+		// Each run logs its own outcome
 		ctx := r.Context()
-		if err := p.Parse(ctx, link.Link); err != nil {
+		if _, err := h.manager.Ingest(ctx, countyLinkIngestRequest(&link)); err != nil {
 			errMsg := fmt.Sprintf("County %s: %v", link.CountyName, err)
-			log.Printf("Error: %s", errMsg)
 			results.Failed = append(results.Failed, errMsg)
 			continue
 		}
 
 		results.Successful++
 		results.Processed++
-		log.Printf("Successfully processed county: %s", link.CountyName)
 	}
 
-	log.Printf("Bulk parse completed. Total: %d, Processed: %d, Successful: %d, Failed: %d",
-		results.TotalCounties, results.Processed, results.Successful, len(results.Failed))
+	logger.Info("Bulk parse completed", "total", results.TotalCounties, "processed", results.Processed,
+		"successful", results.Successful, "failed", len(results.Failed))
 
 	// Always return a response, even if no counties were processed
 	w.WriteHeader(http.StatusOK)
@@ -392,54 +472,49 @@ func (h *CountyHandler) HandleBulkParseByMethod(w http.ResponseWriter, r *http.R
 
 func (h *CountyHandler) HandleDirectParse(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req ParseRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		api.HTTPError(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
 	// Validate required fields (ResultType is optional for direct parse)
 	if req.CountyName == "" || req.Link == "" || req.ParseMethod == "" {
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		api.HTTPError(w, "Missing required fields", http.StatusBadRequest)
 		return
 	}
-
-	// Get the parser
-	p, err := h.manager.GetParser(req.ParseMethod)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get parser: %v", err), http.StatusInternalServerError)
+	if !requireElection(w, r, req.ElectionID) {
 		return
 	}
 
-	// Set county name for the parser
-	p.SetCountyName(req.CountyName)
-
-	// Parse the URL
+	// Parse the URL into a new snapshot
 	ctx := r.Context()
-	if err := p.Parse(ctx, req.Link); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to parse data: %v", err), http.StatusInternalServerError)
+	result, err := h.manager.Ingest(ctx, req.ingestRequest())
+	if err != nil {
+		api.HTTPError(w, fmt.Sprintf("Failed to parse data: %v", err), parseErrorStatus(err))
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": fmt.Sprintf("Successfully parsed data for county: %s", req.CountyName),
+	json.NewEncoder(w).Encode(ParseResponse{
+		Message:  fmt.Sprintf("Successfully parsed data for county: %s", req.CountyName),
+		Snapshot: result.Snapshot.ID,
 	})
 }
 
 func (h *CountyHandler) HandleDirectBulkParse(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req BulkParseRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		api.HTTPError(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
@@ -450,22 +525,16 @@ func (h *CountyHandler) HandleDirectBulkParse(w http.ResponseWriter, r *http.Req
 			CountyName: link.CountyName,
 			Success:    true,
 		}
-
-		// Get the parser
-		p, err := h.manager.GetParser(link.ParseMethod)
-		if err != nil {
+		if !electionAllowed(r, link.ElectionID) {
 			result.Success = false
-			result.Error = fmt.Sprintf("Failed to get parser: %v", err)
+			result.Error = fmt.Sprintf("This API key can't access election %q", link.ElectionID)
 			results = append(results, result)
 			continue
 		}
 
-		// Set county name for the parser
-		p.SetCountyName(link.CountyName)
-
-		// Parse the URL
+		// Parse the URL into a new snapshot
 		ctx := r.Context()
-		if err := p.Parse(ctx, link.Link); err != nil {
+		if _, err := h.manager.Ingest(ctx, link.ingestRequest()); err != nil {
 			result.Success = false
 			result.Error = fmt.Sprintf("Failed to parse data: %v", err)
 			results = append(results, result)
@@ -476,313 +545,295 @@ func (h *CountyHandler) HandleDirectBulkParse(w http.ResponseWriter, r *http.Req
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"results": results,
-		"total": len(req.Links),
-		"successful": len(filter(results, func(r Result) bool { return r.Success })),
+	json.NewEncoder(w).Encode(BulkParseResponse{
+		Results:    results,
+		Total:      len(req.Links),
+		Successful: len(filter(results, func(r Result) bool { return r.Success })),
 	})
 }
 
 // Results Handlers
+//
+// HandleGetCountyResults returns one page of a county's current results.
+// Supported query parameters:
+//   - type:        "candidate" or "measure"
+//   - election_id: only this election's results
+//   - contest:     case-insensitive contest name search
+//   - choice:      case-insensitive choice name search
+//   - min_votes:   only choices with at least this many votes
+//   - sort:        contest (default), votes or percentage; prefix "-" to reverse
+//   - page, per_page: page number and size (default 50, max 500)
+//   - cursor:      next_cursor from the previous page, instead of page
 func (h *CountyHandler) HandleGetCountyResults(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Get county ID from path
-	countyID := r.PathValue("id")
+	countyID := models.CountySlug(r.PathValue("id"))
 	if countyID == "" {
-		http.Error(w, "County ID is required", http.StatusBadRequest)
+		api.HTTPError(w, "County ID is required", http.StatusBadRequest)
 		return
 	}
 
-	// Get optional type filter from query params
-	resultType := r.URL.Query().Get("type") // "candidate" or "measure"
-
-	// Get results from PocketBase
-	collectionName := fmt.Sprintf("county_%s_results", countyID)
-	collection, err := h.store.GetPocketBase().Dao().FindCollectionByNameOrId(collectionName)
+	query, err := parseResultQuery(r)
 	if err != nil {
-		http.Error(w, "County results not found", http.StatusNotFound)
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Build query
-	query := h.store.GetPocketBase().Dao().RecordQuery(collection)
-	if resultType != "" {
-		query.AndWhere(dbx.HashExp{"type": resultType})
+	page, err := h.store.QueryResults(countyID, query)
+	if err != nil {
+		requestLogger(r).Error("Error fetching results", "county_link", countyID, "error", err)
+		api.HTTPError(w, "Error fetching results", http.StatusInternalServerError)
+		return
 	}
-
-	// Execute query
-	var records []*pb.Record
-	if err := query.All(&records); err != nil {
-		log.Printf("Error fetching results: %v", err)
-		http.Error(w, "Error fetching results", http.StatusInternalServerError)
+	if page == nil {
+		api.HTTPError(w, "County results not found", http.StatusNotFound)
 		return
 	}
 
-	// Convert records to response format
-	type Result struct {
-		ID          string  `json:"id"`
-		Type        string  `json:"type"`
-		ContestName string  `json:"contest_name"`
-		ChoiceName  string  `json:"choice_name"`
-		Votes       int     `json:"votes"`
-		Percentage  float64 `json:"percentage"`
-		IsBond      bool    `json:"is_bond,omitempty"`
+	var contestIDs []string
+	for _, row := range page.Results {
+		contestIDs = append(contestIDs, row.ContestID)
 	}
+	translations := h.contestTranslations(i18n.Negotiate(w, r).Lang, contestIDs)
 
-	results := make([]Result, len(records))
-	for i, record := range records {
-		results[i] = Result{
-			ID:          record.Id,
-			Type:        record.GetString("type"),
-			ContestName: record.GetString("contest_name"),
-			ChoiceName:  record.GetString("choice_name"),
-			Votes:       int(record.GetInt("votes")),
-			Percentage:  record.GetFloat("percentage"),
+	// Convert rows to response format
+	results := make([]CountyResult, len(page.Results))
+	for i, row := range page.Results {
+		results[i] = CountyResult{
+			ID:          row.ID,
+			Type:        row.Type,
+			ContestName: row.ContestName,
+			ChoiceName:  row.ChoiceName,
+			Votes:       row.Votes,
+			Percentage:  row.Percentage,
+			IsBond:      row.IsBond,
 		}
-		if results[i].Type == "measure" {
-			results[i].IsBond = record.GetBool("is_bond")
+		if t, ok := translations[row.ContestID]; ok {
+			results[i].ContestNameTranslated = t.Title
+			results[i].ChoiceNameTranslated = t.ChoiceName(row.ChoiceName)
 		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"total":   len(results),
-		"results": results,
+	json.NewEncoder(w).Encode(CountyResultsResponse{
+		Total:      page.Total,
+		Page:       page.Page,
+		PerPage:    page.PerPage,
+		TotalPages: page.TotalPages,
+		NextCursor: page.NextCursor,
+		Results:    results,
 	})
 }
 
-func (h *CountyHandler) HandleGetMeasuresHTML(w http.ResponseWriter, r *http.Request) {
-	countyID := r.PathValue("id")
-	log.Printf("Starting measures request for county: %s", countyID)
-
-	// Parse latest data first
-	if err := h.parseCountyData(countyID); err != nil {
-		log.Printf("Warning: Failed to parse latest data: %v", err)
-	} else {
-		log.Printf("Successfully parsed latest data for county: %s", countyID)
-	}
-
-	// Get results from PocketBase - use the same collection name format as the parser
-	collectionName := fmt.Sprintf("county_%s_results", "marin") // Hardcode to "marin" for now
-	collection, err := h.store.GetPocketBase().Dao().FindCollectionByNameOrId(collectionName)
-	if err != nil {
-		log.Printf("Error finding collection %s: %v", collectionName, err)
-		http.Error(w, "County results not found", http.StatusNotFound)
-		return
-	}
-
-	// Query measure results
-	query := h.store.GetPocketBase().Dao().RecordQuery(collection).
-		AndWhere(dbx.HashExp{"type": "measure"})
-
-	var records []*pb.Record
-	if err := query.All(&records); err != nil {
-		log.Printf("Error fetching results: %v", err)
-		http.Error(w, "Error fetching results", http.StatusInternalServerError)
-		return
-	}
-
-	// Group measures by contest
-	groupMap := make(map[string]*MeasureGroup)
-	for _, record := range records {
-		contestName := record.GetString("contest_name")
-		if _, exists := groupMap[contestName]; !exists {
-			groupMap[contestName] = &MeasureGroup{
-				Title: contestName,
-			}
+// parseResultQuery reads result filters, sort order and paging from the
+// query string
+func parseResultQuery(r *http.Request) (models.ResultQuery, error) {
+	values := r.URL.Query()
+	query := models.ResultQuery{
+		Type:       values.Get("type"),
+		ElectionID: values.Get("election_id"),
+		Contest:    values.Get("contest"),
+		Choice:     values.Get("choice"),
+		Sort:       values.Get("sort"),
+		Cursor:     values.Get("cursor"),
+	}
+
+	ints := []struct {
+		name string
+		dest *int
+	}{
+		{"min_votes", &query.MinVotes},
+		{"page", &query.Page},
+		{"per_page", &query.PerPage},
+	}
+	for _, param := range ints {
+		value := values.Get(param.name)
+		if value == "" {
+			continue
 		}
-
-		measure := Measure{
-			Name:        record.GetString("choice_name"),
-			Description: record.GetString("description"),
-			YesVotes:    formatVotes(record.GetInt("yes_votes")),
-			NoVotes:     formatVotes(record.GetInt("no_votes")),
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return query, fmt.Errorf("%s must be a number", param.name)
 		}
-		groupMap[contestName].Measures = append(groupMap[contestName].Measures, measure)
+		*param.dest = n
 	}
 
-	// Convert map to slice
-	var groups []MeasureGroup
-	for _, group := range groupMap {
-		groups = append(groups, *group)
+	if err := query.Validate(); err != nil {
+		return query, err
 	}
+	return query, nil
+}
 
-	// Parse and execute template
-	tmpl, err := template.ParseFiles("internal/templates/measures.html")
-	if err != nil {
-		log.Printf("Error parsing template: %v", err)
-		http.Error(w, "Error parsing template", http.StatusInternalServerError)
-		return
-	}
+func (h *CountyHandler) HandleGetMeasuresHTML(w http.ResponseWriter, r *http.Request) {
+	countyID := r.PathValue("id")
 
-	w.Header().Set("Content-Type", "text/html")
-	if err := tmpl.Execute(w, map[string]interface{}{
-		"Groups": groups,
-	}); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Error executing template", http.StatusInternalServerError)
+	county, records, ok := h.latestCountyRecords(w, r, countyID, "measure")
+	if !ok {
 		return
 	}
+
+	page := newResultsPage(county, records)
+	page.Groups = groupMeasures(records)
+
+	h.renderPage(w, r, templates.Measures, page)
 }
 
 func (h *CountyHandler) HandleGetCandidatesHTML(w http.ResponseWriter, r *http.Request) {
 	countyID := r.PathValue("id")
-	log.Printf("Starting candidates request for county: %s", countyID)
-
-	// Parse latest data first
-	if err := h.parseCountyData(countyID); err != nil {
-		log.Printf("Warning: Failed to parse latest data: %v", err)
-	} else {
-		log.Printf("Successfully parsed latest data for county: %s", countyID)
-	}
 
-	// Get results from PocketBase - use the same collection name format as the parser
-	collectionName := fmt.Sprintf("county_%s_results", "marin") // Hardcode to "marin" for now
-	collection, err := h.store.GetPocketBase().Dao().FindCollectionByNameOrId(collectionName)
-	if err != nil {
-		log.Printf("Error finding collection %s: %v", collectionName, err)
-		http.Error(w, "County results not found", http.StatusNotFound)
-		return
-	}
-
-	// Query candidate results
-	query := h.store.GetPocketBase().Dao().RecordQuery(collection).
-		AndWhere(dbx.HashExp{"type": "candidate"})
-
-	var records []*pb.Record
-	if err := query.All(&records); err != nil {
-		log.Printf("Error fetching results: %v", err)
-		http.Error(w, "Error fetching results", http.StatusInternalServerError)
+	county, records, ok := h.latestCountyRecords(w, r, countyID, "candidate")
+	if !ok {
 		return
 	}
 
-	// Group candidates by race
-	raceMap := make(map[string]*Race)
-	for _, record := range records {
-		contestName := record.GetString("contest_name")
-		if _, exists := raceMap[contestName]; !exists {
-			raceMap[contestName] = &Race{
-				Title: contestName,
-			}
-		}
+	page := newResultsPage(county, records)
+	page.Races = groupRaces(records)
 
-		candidate := Candidate{
-			Name:       record.GetString("choice_name"),
-			Position:   record.GetString("description"),
-			Votes:      formatVotes(record.GetInt("votes")),
-			Percentage: formatPercentage(record.GetFloat("percentage")),
-		}
-		raceMap[contestName].Candidates = append(raceMap[contestName].Candidates, candidate)
-	}
+	h.renderPage(w, r, templates.Candidates, page)
+}
 
-	// Convert map to slice
-	var races []Race
-	for _, race := range raceMap {
-		races = append(races, *race)
-	}
+// HandleGetResultsHTML renders every contest of a county on one page, in
+// ballot order and grouped by category, with a table of contents
+func (h *CountyHandler) HandleGetResultsHTML(w http.ResponseWriter, r *http.Request) {
+	countyID := r.PathValue("id")
 
-	// Parse and execute template
-	tmpl, err := template.ParseFiles("internal/templates/candidates.html")
-	if err != nil {
-		log.Printf("Error parsing template: %v", err)
-		http.Error(w, "Error parsing template", http.StatusInternalServerError)
+	county, candidates, ok := h.latestCountyRecords(w, r, countyID, "candidate")
+	if !ok {
 		return
 	}
-
-	w.Header().Set("Content-Type", "text/html")
-	if err := tmpl.Execute(w, map[string]interface{}{
-		"Races": races,
-	}); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Error executing template", http.StatusInternalServerError)
+	_, measures, ok := h.latestCountyRecords(w, r, countyID, "measure")
+	if !ok {
 		return
 	}
+
+	page := newResultsPage(county, append(candidates, measures...))
+	page.Sections = resultsSections(candidates, measures)
+
+	h.renderPage(w, r, templates.Results, page)
 }
 
 // System Operation Handlers
+
+// HandleCleanupCollections deletes every county's results and snapshots. The
+// v1 route is DELETE /api/v1/results; the legacy /api/cleanup takes POST.
 func (h *CountyHandler) HandleCleanupCollections(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	log.Printf("Starting collection cleanup...")
+	logger := requestLogger(r)
+	logger.Info("Starting collection cleanup", "by", principalName(r))
 	
 	// Get all collections using FindCollectionsByType
 	collections, err := h.store.GetPocketBase().Dao().FindCollectionsByType("base")
 	if err != nil {
-		log.Printf("Error fetching collections: %v", err)
-		http.Error(w, "Failed to fetch collections", http.StatusInternalServerError)
+		logger.Error("Error fetching collections", "error", err)
+		api.HTTPError(w, "Failed to fetch collections", http.StatusInternalServerError)
 		return
 	}
 	
 	var deleted []string
 	var skipped []string
 
-	// Delete all collections except county_links
+	// Delete all results collections, keeping county links, race calls and
+	// other application data
 	for _, collection := range collections {
-		if collection.Name == "county_links" {
+		if !storage.IsResultsCollection(collection.Name) {
 			skipped = append(skipped, collection.Name)
 			continue
 		}
 
-		// Skip system collections (those starting with underscore)
-		if strings.HasPrefix(collection.Name, "_") {
-			skipped = append(skipped, collection.Name)
-			continue
-		}
-
-		log.Printf("Deleting collection: %s", collection.Name)
+		logger.Info("Deleting collection", "collection", collection.Name)
 		if err := h.store.GetPocketBase().Dao().DeleteCollection(collection); err != nil {
-			log.Printf("Error deleting collection %s: %v", collection.Name, err)
-			http.Error(w, fmt.Sprintf("Failed to delete collection %s", collection.Name), http.StatusInternalServerError)
+			logger.Error("Error deleting collection", "collection", collection.Name, "error", err)
+			api.HTTPError(w, fmt.Sprintf("Failed to delete collection %s", collection.Name), http.StatusInternalServerError)
 			return
 		}
 		deleted = append(deleted, collection.Name)
 	}
 
+	// Snapshots only describe the results that were just deleted
+	if err := h.store.ClearSnapshots(); err != nil {
+		logger.Error("Error clearing snapshots", "error", err)
+		api.HTTPError(w, "Failed to clear snapshots", http.StatusInternalServerError)
+		return
+	}
+	if err := h.store.ClearSearchIndex(); err != nil {
+		logger.Error("Error clearing search index", "error", err)
+		api.HTTPError(w, "Failed to clear search index", http.StatusInternalServerError)
+		return
+	}
+
 	// Prepare response
-	response := map[string]interface{}{
-		"deleted": deleted,
-		"skipped": skipped,
-		"message": "Collections cleanup completed successfully",
+	response := CleanupResponse{
+		Deleted: deleted,
+		Skipped: skipped,
+		Message: "Collections cleanup completed successfully",
 	}
 
-	log.Printf("Cleanup completed. Deleted: %d collections, Skipped: %d collections", 
-		len(deleted), len(skipped))
+	logger.Info("Cleanup completed", "deleted", len(deleted), "skipped", len(skipped))
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
-// Helper method for parsing county data
-func (h *CountyHandler) parseCountyData(countyID string) error {
-	// Get the county link info
-	countyLink, err := h.store.GetCountyLink(countyID)
-	if err != nil {
-		return fmt.Errorf("failed to get county link: %w", err)
+// RefreshStale starts a background refresh of the county link in the {id}
+// path value when its latest snapshot is stale, then serves next without
+// waiting. It runs ahead of any response cache so cached pages still
+// trigger refreshes.
+func (h *CountyHandler) RefreshStale(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.refresher.Enabled() {
+			h.refreshIfStale(r)
+		}
+		next(w, r)
 	}
+}
 
-	// Get the parser
-	p, err := h.manager.GetParser(string(countyLink.ParseMethod))
+func (h *CountyHandler) refreshIfStale(r *http.Request) {
+	countyLink, err := h.store.GetCountyLink(r.PathValue("id"))
 	if err != nil {
-		return fmt.Errorf("failed to get parser: %w", err)
+		return
 	}
+	county := models.CountySlug(countyLink.CountyName)
 
-	// Set county name for the parser
-	p.SetCountyName(countyLink.CountyName)
+	latest, err := h.store.LatestElectionSnapshot(county, countyLink.ElectionID)
+	if err != nil {
+		requestLogger(r).Error("Error fetching latest snapshot", "county", county, "error", err)
+		return
+	}
+	if h.refresher.RefreshIfStale(r.Context(), countyLinkIngestRequest(countyLink), latest) {
+		requestLogger(r).Info("Serving stale results while refreshing", "county", county)
+	}
+}
 
-	// Parse the URL
-	ctx := context.Background()
-	if err := p.Parse(ctx, countyLink.Link); err != nil {
-		return fmt.Errorf("failed to parse data: %w", err)
+// latestCountyRecords loads the latest stored results of one type for a
+// county link, along with the county's slug. It writes the error response
+// and returns false when the results can't be served.
+func (h *CountyHandler) latestCountyRecords(w http.ResponseWriter, r *http.Request, countyID, resultType string) (string, []*pb.Record, bool) {
+	countyLink, err := h.store.GetCountyLink(countyID)
+	if err != nil {
+		api.HTTPError(w, "County link not found", http.StatusNotFound)
+		return "", nil, false
 	}
+	county := models.CountySlug(countyLink.CountyName)
 
-	return nil
+	records, err := h.store.LatestResultRecords(county, countyLink.ElectionID, resultType)
+	if err != nil {
+		requestLogger(r).Error("Error fetching results", "county", county, "type", resultType, "error", err)
+		api.HTTPError(w, "Error fetching results", http.StatusInternalServerError)
+		return "", nil, false
+	}
+	if records == nil {
+		api.HTTPError(w, "County results not found", http.StatusNotFound)
+		return "", nil, false
+	}
+	return county, records, true
 }
 
 // Update the ParseRequest structure to include result type
@@ -790,154 +841,66 @@ func (h *CountyHandler) parseCountyData(countyID string) error {
 
 // Add this new handler
 func (h *CountyHandler) HandleParseAndFormat(w http.ResponseWriter, r *http.Request) {
-	// Handle preflight OPTIONS request
-	if r.Method == http.MethodOptions {
-		enableCORS(w, r)  // Pass the request to enableCORS
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	enableCORS(w, r)  // Pass the request to enableCORS for all other requests
-
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req ParseRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		api.HTTPError(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
 	// Validate request
 	if req.CountyName == "" || req.Link == "" || req.ParseMethod == "" || req.ResultType == "" {
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		api.HTTPError(w, "Missing required fields", http.StatusBadRequest)
 		return
 	}
 
 	if req.ResultType != "measures" && req.ResultType != "candidates" {
-		http.Error(w, "ResultType must be either 'measures' or 'candidates'", http.StatusBadRequest)
+		api.HTTPError(w, "ResultType must be either 'measures' or 'candidates'", http.StatusBadRequest)
 		return
 	}
-
-	// Get the parser
-	p, err := h.manager.GetParser(req.ParseMethod)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get parser: %v", err), http.StatusInternalServerError)
+	if !requireElection(w, r, req.ElectionID) {
 		return
 	}
 
-	// Set county name for the parser
-	p.SetCountyName(req.CountyName)
-
-	// Parse the URL
+	// Parse the URL into a new snapshot
 	ctx := r.Context()
-	if err := p.Parse(ctx, req.Link); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to parse data: %v", err), http.StatusInternalServerError)
+	if _, err := h.manager.Ingest(ctx, req.ingestRequest()); err != nil {
+		api.HTTPError(w, fmt.Sprintf("Failed to parse data: %v", err), parseErrorStatus(err))
 		return
 	}
 
-	// Get results from PocketBase
-	collectionName := fmt.Sprintf("county_%s_results", req.CountyName)
-	collection, err := h.store.GetPocketBase().Dao().FindCollectionByNameOrId(collectionName)
+	// Read back the election's latest snapshot, as the results routes do
+	county := models.CountySlug(req.CountyName)
+	resultType := "candidate"
+	if req.ResultType == "measures" {
+		resultType = "measure"
+	}
+	records, err := h.store.LatestResultRecords(county, req.ElectionID, resultType)
 	if err != nil {
-		http.Error(w, "Results not found", http.StatusNotFound)
+		requestLogger(r).Error("Error fetching results", "county", county, "type", resultType, "error", err)
+		api.HTTPError(w, "Error fetching results", http.StatusInternalServerError)
 		return
 	}
-
-	// Query results based on type
-	query := h.store.GetPocketBase().Dao().RecordQuery(collection)
-	if req.ResultType == "measures" {
-		query.AndWhere(dbx.HashExp{"type": "measure"})
-	} else {
-		query.AndWhere(dbx.HashExp{"type": "candidate"})
-	}
-
-	var records []*pb.Record
-	if err := query.All(&records); err != nil {
-		http.Error(w, "Error fetching results", http.StatusInternalServerError)
+	if records == nil {
+		api.HTTPError(w, "Results not found", http.StatusNotFound)
 		return
 	}
 
-	// Set content type to HTML
-	w.Header().Set("Content-Type", "text/html")
-
 	// Format and return results based on type
 	if req.ResultType == "measures" {
-		// Group measures
-		groupMap := make(map[string]*MeasureGroup)
-		for _, record := range records {
-			contestName := record.GetString("contest_name")
-			if _, exists := groupMap[contestName]; !exists {
-				groupMap[contestName] = &MeasureGroup{
-					Title: contestName,
-				}
-			}
-
-			measure := Measure{
-				Name:        record.GetString("choice_name"),
-				Description: record.GetString("description"),
-				YesVotes:    formatVotes(record.GetInt("yes_votes")),
-				NoVotes:     formatVotes(record.GetInt("no_votes")),
-			}
-			groupMap[contestName].Measures = append(groupMap[contestName].Measures, measure)
-		}
+		page := newResultsPage(req.CountyName, records)
+		page.Groups = groupMeasures(records)
 
-		var groups []MeasureGroup
-		for _, group := range groupMap {
-			groups = append(groups, *group)
-		}
-
-		tmpl, err := template.ParseFiles("internal/templates/measures.html")
-		if err != nil {
-			http.Error(w, "Error parsing template", http.StatusInternalServerError)
-			return
-		}
-
-		if err := tmpl.Execute(w, map[string]interface{}{
-			"Groups": groups,
-		}); err != nil {
-			http.Error(w, "Error executing template", http.StatusInternalServerError)
-			return
-		}
+		h.renderPage(w, r, templates.Measures, page)
 	} else {
-		// Group candidates
-		raceMap := make(map[string]*Race)
-		for _, record := range records {
-			contestName := record.GetString("contest_name")
-			if _, exists := raceMap[contestName]; !exists {
-				raceMap[contestName] = &Race{
-					Title: contestName,
-				}
-			}
-
-			candidate := Candidate{
-				Name:       record.GetString("choice_name"),
-				Position:   record.GetString("description"),
-				Votes:      formatVotes(record.GetInt("votes")),
-				Percentage: formatPercentage(record.GetFloat("percentage")),
-			}
-			raceMap[contestName].Candidates = append(raceMap[contestName].Candidates, candidate)
-		}
+		page := newResultsPage(req.CountyName, records)
+		page.Races = groupRaces(records)
 
-		var races []Race
-		for _, race := range raceMap {
-			races = append(races, *race)
-		}
-
-		tmpl, err := template.ParseFiles("internal/templates/candidates.html")
-		if err != nil {
-			http.Error(w, "Error parsing template", http.StatusInternalServerError)
-			return
-		}
-
-		if err := tmpl.Execute(w, map[string]interface{}{
-			"Races": races,
-		}); err != nil {
-			http.Error(w, "Error executing template", http.StatusInternalServerError)
-			return
-		}
+		h.renderPage(w, r, templates.Candidates, page)
 	}
 }
 
@@ -946,4 +909,16 @@ func formatPercentage(percentage float64) string {
 		return "0%"
 	}
 	return fmt.Sprintf("%.1f%%", percentage)
+}
+
+// parseErrorStatus is the status of a failed parse: 400 when the URL policy
+// refused the source, 503 when the server is shutting down, 500 otherwise
+func parseErrorStatus(err error) int {
+	if urlpolicy.IsViolation(err) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, parser.ErrShuttingDown) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
 }
\ No newline at end of file
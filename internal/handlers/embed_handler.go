@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"era/internal/api"
+	"era/internal/i18n"
+	"era/internal/models"
+	"era/internal/templates"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// EmbedTheme holds the colors and layout options partners can set on an
+// embedded widget
+type EmbedTheme struct {
+	Title      string
+	Accent     string
+	Votes      string
+	Background string
+	Compact    bool
+	Refresh    bool
+}
+
+var defaultEmbedTheme = EmbedTheme{
+	Title:      "#1a3668",
+	Accent:     "#738c3f",
+	Votes:      "#9e0000",
+	Background: "#ffffff",
+	Refresh:    true,
+}
+
+// EmbedChoice is a single choice row of an embedded contest
+type EmbedChoice struct {
+	Name       string
+	Votes      string
+	Percentage string
+	Share      float64
+	Leading    bool
+	Winner     bool
+}
+
+// EmbedContest is the view model of the contest widget
+type EmbedContest struct {
+	ID                 string
+	Title              string
+	Description        string
+	Type               string
+	Choices            []EmbedChoice
+	PrecinctsReporting int
+	PrecinctsTotal     int
+	Updated            string
+	Called             bool
+}
+
+// parseEmbedTheme reads theme overrides from the query string. Colors are
+// hex values with or without the leading '#'.
+func parseEmbedTheme(r *http.Request) (EmbedTheme, error) {
+	theme := defaultEmbedTheme
+	query := r.URL.Query()
+
+	colors := []struct {
+		name string
+		dest *string
+	}{
+		{"title_color", &theme.Title},
+		{"accent_color", &theme.Accent},
+		{"votes_color", &theme.Votes},
+		{"background", &theme.Background},
+	}
+	for _, color := range colors {
+		value := query.Get(color.name)
+		if value == "" {
+			continue
+		}
+		hex, ok := parseHexColor(value)
+		if !ok {
+			return theme, fmt.Errorf("%s must be a hex color such as 1a3668", color.name)
+		}
+		*color.dest = hex
+	}
+
+	theme.Compact = query.Get("compact") == "1" || query.Get("compact") == "true"
+	if refresh := query.Get("refresh"); refresh == "0" || refresh == "false" {
+		theme.Refresh = false
+	}
+	return theme, nil
+}
+
+// parseHexColor normalizes a 3 or 6 digit hex color to "#rrggbb" form
+func parseHexColor(value string) (string, bool) {
+	value = strings.TrimPrefix(value, "#")
+	if len(value) != 3 && len(value) != 6 {
+		return "", false
+	}
+	for _, r := range value {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return "", false
+		}
+	}
+	return "#" + strings.ToLower(value), true
+}
+
+// buildEmbedContest totals a contest's rows for display, translated when a
+// translation into the locale's language is given
+func buildEmbedContest(contestID string, rows []models.ResultRow, call *models.RaceCall, locale *i18n.Locale, translation *models.ContestTranslation) EmbedContest {
+	first := rows[0]
+	contest := EmbedContest{
+		ID:          contestID,
+		Title:       first.ContestName,
+		Description: first.Description,
+		Type:        first.Type,
+		Called:      call != nil,
+	}
+
+	latest := time.Time{}
+	for _, row := range rows {
+		if row.Updated.After(latest) {
+			latest = row.Updated
+		}
+		if row.PrecinctsTotal > contest.PrecinctsTotal {
+			contest.PrecinctsTotal = row.PrecinctsTotal
+			contest.PrecinctsReporting = row.PrecinctsReporting
+		}
+	}
+	if !latest.IsZero() {
+		contest.Updated = locale.Time(latest)
+	}
+
+	if translation != nil {
+		contest.Title = translation.Title
+		if translation.Description != "" {
+			contest.Description = translation.Description
+		}
+	}
+
+	for i, choice := range models.ChoiceTotals(rows) {
+		name := choice.Name
+		if translation != nil {
+			name = translation.ChoiceName(name)
+		}
+		contest.Choices = append(contest.Choices, EmbedChoice{
+			Name:       name,
+			Votes:      formatVotes(choice.Votes),
+			Percentage: formatPercentage(choice.Percentage),
+			Share:      choice.Percentage,
+			Leading:    i == 0 && choice.Votes > 0,
+			Winner:     call != nil && call.Winner == choice.Name,
+		})
+	}
+	return contest
+}
+
+// Embed Handlers
+//
+// HandleEmbedContest renders a single contest as a minimal HTML widget meant
+// for an iframe on a partner's page. Supported query parameters:
+//   - title_color, accent_color, votes_color, background: hex colors
+//   - compact: 1 for a denser layout without descriptions
+//   - refresh: 0 to stop reloading when new results arrive
+//   - lang: es, zh or vi, overriding the Accept-Language header
+func (h *CountyHandler) HandleEmbedContest(w http.ResponseWriter, r *http.Request) {
+	contestID := r.PathValue("id")
+	if _, _, err := models.ParseContestID(contestID); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	theme, err := parseEmbedTheme(r)
+	if err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.store.GetResults(models.ResultFilter{ContestID: contestID})
+	if err != nil {
+		requestLogger(r).Error("Error fetching results", "contest_id", contestID, "error", err)
+		api.HTTPError(w, "Error fetching results", http.StatusInternalServerError)
+		return
+	}
+	if len(rows) == 0 {
+		api.HTTPError(w, "Contest not found", http.StatusNotFound)
+		return
+	}
+
+	calls, err := h.store.GetRaceCalls("")
+	if err != nil {
+		requestLogger(r).Error("Error fetching race calls", "error", err)
+		api.HTTPError(w, "Error fetching race calls", http.StatusInternalServerError)
+		return
+	}
+	var call *models.RaceCall
+	if c, ok := calls[contestID]; ok {
+		call = &c
+	}
+
+	locale := i18n.Negotiate(w, r)
+	var translation *models.ContestTranslation
+	if t, ok := h.contestTranslations(locale.Lang, []string{contestID})[contestID]; ok {
+		translation = &t
+	}
+
+	// Widgets are meant to be framed by any site
+	w.Header().Set("Content-Security-Policy", "frame-ancestors *")
+	w.Header().Set("Content-Type", "text/html")
+	if err := templates.EmbedContest.Execute(w, map[string]interface{}{
+		"Contest": buildEmbedContest(contestID, rows, call, locale, translation),
+		"Theme":   theme,
+		"Lang":    locale.Lang,
+		"Locale":  locale,
+	}); err != nil {
+		requestLogger(r).Error("Error executing template", "error", err)
+	}
+}
+
+// HandleEmbedLoader serves the script partners include to turn
+// <div data-era-contest="..."> placeholders into auto-resizing widgets
+func (h *CountyHandler) HandleEmbedLoader(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Write(templates.EmbedLoader)
+}
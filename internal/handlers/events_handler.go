@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"era/internal/api"
+	"era/internal/events"
+	"era/internal/models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// heartbeatInterval keeps idle SSE connections open through proxies
+const heartbeatInterval = 15 * time.Second
+
+// EventsHandler streams result changes to clients
+type EventsHandler struct {
+	broker *events.Broker
+}
+
+// NewEventsHandler creates a new events handler
+func NewEventsHandler(broker *events.Broker) *EventsHandler {
+	return &EventsHandler{broker: broker}
+}
+
+// HandleEvents streams events as Server-Sent Events. Supported query
+// parameters:
+//   - election_id: only events for this election
+//   - county:      only events for this county
+//   - contest_id:  only changes to this contest (and its county's snapshots)
+//
+// Clients resuming a stream send Last-Event-ID (or ?last_event_id=) to
+// replay the events they missed.
+func (h *EventsHandler) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		api.HTTPError(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := events.Filter{
+		ElectionID: query.Get("election_id"),
+		ContestID:  query.Get("contest_id"),
+	}
+	if county := query.Get("county"); county != "" {
+		filter.County = models.CountySlug(county)
+	}
+	if filter.ContestID != "" {
+		if _, _, err := models.ParseContestID(filter.ContestID); err != nil {
+			api.HTTPError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = query.Get("last_event_id")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			api.HTTPError(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastID = id
+	}
+
+	replay, ch, cancel := h.broker.Subscribe(filter, lastID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Tell EventSource clients how long to wait before reconnecting
+	fmt.Fprint(w, "retry: 5000\n\n")
+	for _, e := range replay {
+		writeEvent(w, e)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				requestLogger(r).Info("Event stream closed by broker")
+				return
+			}
+			writeEvent(w, e)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes a single event in SSE wire format
+func writeEvent(w http.ResponseWriter, e events.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"era/internal/api"
+	"era/internal/export"
+	"era/internal/models"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// Export Handlers
+func (h *CountyHandler) HandleExportElection(w http.ResponseWriter, r *http.Request) {
+	electionID := r.PathValue("id")
+	if electionID == "" {
+		api.HTTPError(w, "Election ID is required", http.StatusBadRequest)
+		return
+	}
+
+	h.streamExport(w, r, models.ResultFilter{ElectionID: electionID}, "election-"+electionID)
+}
+
+func (h *CountyHandler) HandleExportCounty(w http.ResponseWriter, r *http.Request) {
+	county := models.CountySlug(r.PathValue("id"))
+	if county == "" {
+		api.HTTPError(w, "County ID is required", http.StatusBadRequest)
+		return
+	}
+
+	h.streamExport(w, r, models.ResultFilter{County: county}, county)
+}
+
+func (h *CountyHandler) HandleExportContest(w http.ResponseWriter, r *http.Request) {
+	contestID := r.PathValue("id")
+	if _, _, err := models.ParseContestID(contestID); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.streamExport(w, r, models.ResultFilter{ContestID: contestID}, contestID)
+}
+
+// streamExport writes every result matching the filter in the requested
+// format. Supported query parameters:
+//   - format:  csv (default), xlsx or ndjson
+//   - columns: comma-separated column list, defaults to all columns
+//   - type:    optional "candidate" or "measure" filter
+//   - election_id: only this election's results, for county and contest
+//     exports
+func (h *CountyHandler) streamExport(w http.ResponseWriter, r *http.Request, filter models.ResultFilter, name string) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	format, err := export.ParseFormat(query.Get("format"))
+	if err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cols, err := export.ParseColumns(query.Get("columns"))
+	if err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if electionID := query.Get("election_id"); electionID != "" && filter.ElectionID == "" {
+		filter.ElectionID = electionID
+	}
+	filter.Type = query.Get("type")
+	if filter.Type != "" && filter.Type != "candidate" && filter.Type != "measure" {
+		api.HTTPError(w, "type must be either 'candidate' or 'measure'", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	// The name comes from the request, so it is quoted and escaped rather
+	// than pasted into the header
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": fmt.Sprintf("%s-results.%s", name, format),
+	}))
+
+	writer, err := export.NewWriter(w, format, cols)
+	if err != nil {
+		requestLogger(r).Error("Error creating export writer", "format", format, "error", err)
+		api.HTTPError(w, "Error creating export", http.StatusInternalServerError)
+		return
+	}
+
+	// Headers are already sent once rows start streaming, so failures past
+	// this point can only be logged
+	flusher, _ := w.(http.Flusher)
+	rowCount := 0
+	err = h.store.EachResult(filter, func(row models.ResultRow) error {
+		if err := writer.WriteRow(row); err != nil {
+			return err
+		}
+		rowCount++
+		if flusher != nil && rowCount%1000 == 0 {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		requestLogger(r).Error("Error streaming export", "export", name, "format", format, "error", err)
+		return
+	}
+
+	if err := writer.Close(); err != nil {
+		requestLogger(r).Error("Error finishing export", "export", name, "format", format, "error", err)
+		return
+	}
+	requestLogger(r).Info("Exported results", "export", name, "format", format, "rows", rowCount)
+}
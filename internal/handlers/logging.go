@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"era/internal/logging"
+	"log/slog"
+	"net/http"
+)
+
+// requestLogger returns the logger of a request, which adds its request ID
+// to every record
+func requestLogger(r *http.Request) *slog.Logger {
+	return logging.FromContext(r.Context())
+}
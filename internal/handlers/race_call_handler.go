@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"era/internal/api"
+	"era/internal/models"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Race Call Handlers
+func (h *CountyHandler) HandleSaveRaceCall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var call models.RaceCall
+	if err := json.NewDecoder(r.Body).Decode(&call); err != nil {
+		api.HTTPError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	call.ContestID = r.PathValue("id")
+
+	// Calls are attributed to the API key making them, never to a name the
+	// client chose
+	if call.CalledBy != "" && call.CalledBy != principalName(r) {
+		api.HTTPError(w, fmt.Sprintf("called_by %q doesn't match the API key %q; leave it unset", call.CalledBy, principalName(r)), http.StatusBadRequest)
+		return
+	}
+	call.CalledBy = principalName(r)
+
+	if err := call.Validate(); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The winner must be one of the contest's choices
+	rows, err := h.store.GetResults(models.ResultFilter{ContestID: call.ContestID})
+	if err != nil {
+		requestLogger(r).Error("Error fetching results", "contest_id", call.ContestID, "error", err)
+		api.HTTPError(w, "Error fetching results", http.StatusInternalServerError)
+		return
+	}
+	if len(rows) == 0 {
+		api.HTTPError(w, "Contest not found", http.StatusNotFound)
+		return
+	}
+
+	// The call belongs to the election of the contest's results. A client
+	// election_id may only pick among them, when the contest has results in
+	// several elections.
+	var elections []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		if !seen[row.ElectionID] {
+			seen[row.ElectionID] = true
+			elections = append(elections, row.ElectionID)
+		}
+	}
+	switch {
+	case call.ElectionID != "" && !seen[call.ElectionID]:
+		api.HTTPError(w, fmt.Sprintf("election_id %q doesn't match the contest's election %q", call.ElectionID, strings.Join(elections, ", ")), http.StatusBadRequest)
+		return
+	case call.ElectionID == "" && len(elections) > 1:
+		api.HTTPError(w, fmt.Sprintf("The contest has results in elections %s; set election_id", strings.Join(elections, ", ")), http.StatusBadRequest)
+		return
+	case call.ElectionID == "":
+		call.ElectionID = elections[0]
+	}
+	if !requireElection(w, r, call.ElectionID) {
+		return
+	}
+
+	found := false
+	for _, row := range rows {
+		if row.ElectionID == call.ElectionID && row.ChoiceName == call.Winner {
+			found = true
+		}
+	}
+	if !found {
+		api.HTTPError(w, fmt.Sprintf("%q is not a choice in this contest", call.Winner), http.StatusBadRequest)
+		return
+	}
+
+	// Replacing a call needs access to the election it was made in
+	existing, err := h.store.GetRaceCall(call.ElectionID, call.ContestID)
+	if err != nil {
+		requestLogger(r).Error("Error fetching race call", "contest_id", call.ContestID, "error", err)
+		api.HTTPError(w, "Error fetching race call", http.StatusInternalServerError)
+		return
+	}
+	if existing != nil && !requireElection(w, r, existing.ElectionID) {
+		return
+	}
+
+	if err := h.store.SaveRaceCall(&call); err != nil {
+		requestLogger(r).Error("Error saving race call", "contest_id", call.ContestID, "error", err)
+		api.HTTPError(w, "Error saving race call", http.StatusInternalServerError)
+		return
+	}
+
+	requestLogger(r).Info("Race called", "contest_id", call.ContestID, "winner", call.Winner, "by", principalName(r))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(call)
+}
+
+func (h *CountyHandler) HandleDeleteRaceCall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Contest IDs repeat in every election, so the call is named by its
+	// election, which may be left out when the contest was called in one
+	contestID := r.PathValue("id")
+	electionID := r.URL.Query().Get("election_id")
+	if electionID == "" {
+		calls, err := h.store.ListRaceCalls("")
+		if err != nil {
+			requestLogger(r).Error("Error fetching race calls", "error", err)
+			api.HTTPError(w, "Error fetching race calls", http.StatusInternalServerError)
+			return
+		}
+		var elections []string
+		for _, call := range calls {
+			if call.ContestID == contestID {
+				elections = append(elections, call.ElectionID)
+			}
+		}
+		switch len(elections) {
+		case 0:
+			api.HTTPError(w, "Race call not found", http.StatusNotFound)
+			return
+		case 1:
+			electionID = elections[0]
+		default:
+			api.HTTPError(w, fmt.Sprintf("The contest was called in elections %s; set election_id", strings.Join(elections, ", ")), http.StatusBadRequest)
+			return
+		}
+	}
+	if !requireElection(w, r, electionID) {
+		return
+	}
+	if err := h.store.DeleteRaceCall(electionID, contestID); err != nil {
+		api.HTTPError(w, "Race call not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Race call retracted successfully"})
+}
+
+func (h *CountyHandler) HandleGetRaceCalls(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	list, err := h.store.ListRaceCalls(r.URL.Query().Get("election_id"))
+	if err != nil {
+		requestLogger(r).Error("Error fetching race calls", "error", err)
+		api.HTTPError(w, "Error fetching race calls", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"era/internal/api"
+	"era/internal/ratelimit"
+	"net/http"
+)
+
+// RateLimitHandler reports how much of their budgets clients are using
+type RateLimitHandler struct {
+	limiter *ratelimit.Limiter
+}
+
+// NewRateLimitHandler creates a new rate limit handler
+func NewRateLimitHandler(limiter *ratelimit.Limiter) *RateLimitHandler {
+	return &RateLimitHandler{limiter: limiter}
+}
+
+// RateLimitUsage is the budget of each route class and the usage of every
+// client seen recently
+type RateLimitUsage struct {
+	Budgets map[string]string `json:"budgets"`
+	Clients []ratelimit.Usage `json:"clients"`
+}
+
+// HandleGetRateLimits lists client usage, busiest first. ?client= narrows it
+// to one client, e.g. key:<id> or ip:<address>.
+func (h *RateLimitHandler) HandleGetRateLimits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := RateLimitUsage{
+		Budgets: make(map[string]string),
+		Clients: []ratelimit.Usage{},
+	}
+	for class, budget := range h.limiter.Budgets() {
+		response.Budgets[class] = budget.String()
+	}
+	client := r.URL.Query().Get("client")
+	for _, usage := range h.limiter.Usage() {
+		if client == "" || usage.Client == client {
+			response.Clients = append(response.Clients, usage)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
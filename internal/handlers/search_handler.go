@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"era/internal/api"
+	"era/internal/i18n"
+	"era/internal/models"
+	"net/http"
+	"strconv"
+)
+
+// SearchResponse lists the contests matching a search
+type SearchResponse struct {
+	Query   string             `json:"query"`
+	Total   int                `json:"total"`
+	Results []models.SearchHit `json:"results"`
+}
+
+// Search Handlers
+//
+// HandleSearch finds contests and choices by name across every election and
+// county, tolerating typos and partial words. Supported query parameters:
+//   - q:           search text (required)
+//   - election_id: only this election
+//   - county:      only this county
+//   - type:        "candidate" or "measure"
+//   - limit:       maximum contests returned (default 20, max 100)
+//   - lang:        adds translated contest names, overriding Accept-Language
+func (h *CountyHandler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	values := r.URL.Query()
+	query := models.SearchQuery{
+		Text:       values.Get("q"),
+		ElectionID: values.Get("election_id"),
+		Type:       values.Get("type"),
+	}
+	if county := values.Get("county"); county != "" {
+		query.County = models.CountySlug(county)
+	}
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			api.HTTPError(w, "limit must be a number", http.StatusBadRequest)
+			return
+		}
+		query.Limit = n
+	}
+
+	if err := query.Validate(); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hits, err := h.store.Search(query)
+	if err != nil {
+		requestLogger(r).Error("Error searching", "query", query.Text, "error", err)
+		api.HTTPError(w, "Error searching results", http.StatusInternalServerError)
+		return
+	}
+
+	contestIDs := make([]string, len(hits))
+	for i, hit := range hits {
+		contestIDs[i] = hit.ContestID
+	}
+	translations := h.contestTranslations(i18n.Negotiate(w, r).Lang, contestIDs)
+	for i := range hits {
+		if t, ok := translations[hits[i].ContestID]; ok {
+			hits[i].ContestNameTranslated = t.Title
+			hits[i].DescriptionTranslated = t.Description
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SearchResponse{
+		Query:   query.Text,
+		Total:   len(hits),
+		Results: hits,
+	})
+}
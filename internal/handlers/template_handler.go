@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"encoding/json"
+	"era/internal/api"
+	"era/internal/i18n"
+	"era/internal/models"
+	"era/internal/templates"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	pb "github.com/pocketbase/pocketbase/models"
+)
+
+// ResultsPage is the data the results templates render. The candidates
+// page sets Races, the measures page Groups and the combined page Sections.
+type ResultsPage struct {
+	Title      string
+	County     string
+	Lang       string
+	Locale     *i18n.Locale
+	Updated    time.Time
+	Stylesheet template.CSS
+	Races      []Race
+	Groups     []MeasureGroup
+	Sections   []ResultsSection
+}
+
+// ResultsSection is one category of contests on the combined results page.
+// Title is an interface string, translated by the template.
+type ResultsSection struct {
+	Category string
+	Title    string
+	Races    []Race
+	Groups   []MeasureGroup
+}
+
+// pageTitles are the interface strings titling each page
+var pageTitles = map[string]string{
+	templates.Candidates: "%s County Candidates",
+	templates.Measures:   "%s County Measures",
+	templates.Results:    "%s County Results",
+}
+
+// sectionTitles are the interface strings heading each contest category
+var sectionTitles = map[string]string{
+	models.CategoryFederal:  "Federal",
+	models.CategoryState:    "State",
+	models.CategoryCounty:   "County",
+	models.CategoryCity:     "City",
+	models.CategorySchool:   "Schools",
+	models.CategoryOther:    "Other Contests",
+	models.CategoryMeasures: "Measures",
+}
+
+// newResultsPage describes the page of a county's records, last updated
+// when the newest record was stored
+func newResultsPage(county string, records []*pb.Record) *ResultsPage {
+	page := &ResultsPage{County: county}
+	for _, record := range records {
+		if updated := record.GetDateTime("updated").Time(); updated.After(page.Updated) {
+			page.Updated = updated
+		}
+	}
+	return page
+}
+
+// sortBallotOrder orders records as their contests and choices appear on
+// the ballot. Records stored before ballot order was recorded keep their
+// stored order, after the others.
+func sortBallotOrder(records []*pb.Record) {
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i].GetInt("ballot_order"), records[j].GetInt("ballot_order")
+		if a == 0 || b == 0 {
+			return b == 0 && a != 0
+		}
+		return a < b
+	})
+}
+
+// groupRaces groups candidate records into races in ballot order
+func groupRaces(records []*pb.Record) []Race {
+	sortBallotOrder(records)
+	contestCharts := recordCharts(records)
+
+	var races []Race
+	index := make(map[string]int)
+	for _, record := range records {
+		contestName := record.GetString("contest_name")
+		i, exists := index[contestName]
+		if !exists {
+			i = len(races)
+			index[contestName] = i
+			races = append(races, Race{
+				Title:  contestName,
+				Anchor: models.ContestSlug(contestName),
+				Chart:  contestCharts[contestName],
+			})
+		}
+
+		races[i].Candidates = append(races[i].Candidates, Candidate{
+			Name:       record.GetString("choice_name"),
+			Position:   record.GetString("description"),
+			Votes:      formatVotes(record.GetInt("votes")),
+			Percentage: formatPercentage(record.GetFloat("percentage")),
+		})
+	}
+	return races
+}
+
+// groupMeasures groups measure records by contest in ballot order
+func groupMeasures(records []*pb.Record) []MeasureGroup {
+	sortBallotOrder(records)
+	contestCharts := recordCharts(records)
+
+	var groups []MeasureGroup
+	index := make(map[string]int)
+	for _, record := range records {
+		contestName := record.GetString("contest_name")
+		i, exists := index[contestName]
+		if !exists {
+			i = len(groups)
+			index[contestName] = i
+			groups = append(groups, MeasureGroup{
+				Title:  contestName,
+				Anchor: models.ContestSlug(contestName),
+				Chart:  contestCharts[contestName],
+			})
+		}
+
+		groups[i].Measures = append(groups[i].Measures, Measure{
+			Name:        record.GetString("choice_name"),
+			Description: record.GetString("description"),
+			YesVotes:    formatVotes(record.GetInt("yes_votes")),
+			NoVotes:     formatVotes(record.GetInt("no_votes")),
+		})
+	}
+	return groups
+}
+
+// resultsSections sorts a county's contests into the categories of the
+// combined results page, leaving out empty categories
+func resultsSections(candidates, measures []*pb.Record) []ResultsSection {
+	byCategory := make(map[string]*ResultsSection)
+	section := func(category string) *ResultsSection {
+		if byCategory[category] == nil {
+			byCategory[category] = &ResultsSection{Category: category, Title: sectionTitles[category]}
+		}
+		return byCategory[category]
+	}
+
+	for _, race := range groupRaces(candidates) {
+		s := section(models.ContestCategory(race.Title, "candidate"))
+		s.Races = append(s.Races, race)
+	}
+	if groups := groupMeasures(measures); len(groups) > 0 {
+		section(models.CategoryMeasures).Groups = groups
+	}
+
+	var sections []ResultsSection
+	for _, category := range models.ContestCategories {
+		if s, ok := byCategory[category]; ok {
+			sections = append(sections, *s)
+		}
+	}
+	return sections
+}
+
+// translate replaces the page's contest titles, descriptions and choice
+// names with their stored translations into the page's language. Anchors
+// keep the original names so links work in every language.
+func (h *CountyHandler) translate(page *ResultsPage) {
+	races, groups := page.Races, page.Groups
+	for _, section := range page.Sections {
+		races = append(races, section.Races...)
+		groups = append(groups, section.Groups...)
+	}
+
+	var ids []string
+	for _, race := range races {
+		ids = append(ids, models.ContestID(page.County, race.Title))
+	}
+	for _, group := range groups {
+		ids = append(ids, models.ContestID(page.County, group.Title))
+	}
+	translations := h.contestTranslations(page.Lang, ids)
+	if len(translations) == 0 {
+		return
+	}
+
+	translateRaces(page.County, page.Races, translations)
+	translateGroups(page.County, page.Groups, translations)
+	for _, section := range page.Sections {
+		translateRaces(page.County, section.Races, translations)
+		translateGroups(page.County, section.Groups, translations)
+	}
+}
+
+func translateRaces(county string, races []Race, translations map[string]models.ContestTranslation) {
+	for i := range races {
+		race := &races[i]
+		t, ok := translations[models.ContestID(county, race.Title)]
+		if !ok {
+			continue
+		}
+		race.Title = t.Title
+		for j := range race.Candidates {
+			race.Candidates[j].Name = t.ChoiceName(race.Candidates[j].Name)
+		}
+	}
+}
+
+func translateGroups(county string, groups []MeasureGroup, translations map[string]models.ContestTranslation) {
+	for i := range groups {
+		group := &groups[i]
+		t, ok := translations[models.ContestID(county, group.Title)]
+		if !ok {
+			continue
+		}
+		group.Title = t.Title
+		for j := range group.Measures {
+			measure := &group.Measures[j]
+			measure.Name = t.ChoiceName(measure.Name)
+			if t.Description != "" {
+				measure.Description = t.Description
+			}
+		}
+	}
+}
+
+// renderPage translates a results page into the request's language and
+// renders it with the template set the request selects: ?template= names a
+// set, otherwise ?partner= uses the set assigned to that partner, otherwise
+// the embedded defaults are used
+func (h *CountyHandler) renderPage(w http.ResponseWriter, r *http.Request, name string, page *ResultsPage) {
+	set := templates.Default()
+
+	var stored *models.TemplateSet
+	if setName := r.URL.Query().Get("template"); setName != "" {
+		found, err := h.store.GetTemplateSet(setName)
+		if err != nil {
+			api.HTTPError(w, "Template set not found", http.StatusNotFound)
+			return
+		}
+		stored = found
+	} else if partner := r.URL.Query().Get("partner"); partner != "" {
+		found, err := h.store.PartnerTemplateSet(partner)
+		if err != nil {
+			requestLogger(r).Error("Error fetching template set", "partner", partner, "error", err)
+			api.HTTPError(w, "Error fetching template set", http.StatusInternalServerError)
+			return
+		}
+		stored = found
+	}
+
+	if stored != nil {
+		compiled, err := h.templates.Get(stored)
+		if err != nil {
+			requestLogger(r).Error("Error compiling template set", "template_set", stored.Name, "error", err)
+			api.HTTPError(w, "Error parsing template", http.StatusInternalServerError)
+			return
+		}
+		set = compiled
+		// Stylesheets are written by administrators, like the templates
+		page.Stylesheet = template.CSS(stored.Stylesheet)
+	}
+
+	page.Locale = i18n.Negotiate(w, r)
+	page.Lang = page.Locale.Lang
+	page.Title = page.Locale.T(pageTitles[name], countyDisplayName(page.County))
+	h.translate(page)
+
+	w.Header().Set("Content-Type", "text/html")
+	if err := set.Execute(w, name, page); err != nil {
+		requestLogger(r).Error("Error executing template", "template", name, "error", err)
+		api.HTTPError(w, "Error executing template", http.StatusInternalServerError)
+	}
+}
+
+// Template Set Handlers
+//
+// HandleCreateTemplateSet stores a new template set. Every override must
+// parse, and a partner can only be assigned to one set.
+func (h *CountyHandler) HandleCreateTemplateSet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var set models.TemplateSet
+	if err := json.NewDecoder(r.Body).Decode(&set); err != nil {
+		api.HTTPError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !h.validateTemplateSet(w, r, &set) {
+		return
+	}
+	if _, err := h.store.GetTemplateSet(set.Name); err == nil {
+		api.HTTPError(w, fmt.Sprintf("Template set %q already exists", set.Name), http.StatusConflict)
+		return
+	}
+
+	if err := h.store.SaveTemplateSet(&set); err != nil {
+		requestLogger(r).Error("Error saving template set", "template_set", set.Name, "error", err)
+		api.HTTPError(w, "Error saving template set", http.StatusInternalServerError)
+		return
+	}
+
+	requestLogger(r).Info("Template set created", "template_set", set.Name, "by", principalName(r))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(set)
+}
+
+func (h *CountyHandler) HandleGetTemplateSets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sets, err := h.store.GetTemplateSets()
+	if err != nil {
+		requestLogger(r).Error("Error fetching template sets", "error", err)
+		api.HTTPError(w, "Error fetching template sets", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sets)
+}
+
+func (h *CountyHandler) HandleGetTemplateSet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	set, err := h.store.GetTemplateSet(r.PathValue("name"))
+	if err != nil {
+		api.HTTPError(w, "Template set not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(set)
+}
+
+// HandleUpdateTemplateSet replaces a template set. The set keeps the name in
+// the path.
+func (h *CountyHandler) HandleUpdateTemplateSet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	existing, err := h.store.GetTemplateSet(r.PathValue("name"))
+	if err != nil {
+		api.HTTPError(w, "Template set not found", http.StatusNotFound)
+		return
+	}
+
+	var set models.TemplateSet
+	if err := json.NewDecoder(r.Body).Decode(&set); err != nil {
+		api.HTTPError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	set.ID = existing.ID
+	set.Name = existing.Name
+	if !h.validateTemplateSet(w, r, &set) {
+		return
+	}
+
+	if err := h.store.UpdateTemplateSet(&set); err != nil {
+		requestLogger(r).Error("Error updating template set", "template_set", set.Name, "error", err)
+		api.HTTPError(w, "Error updating template set", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(set)
+}
+
+func (h *CountyHandler) HandleDeleteTemplateSet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.store.DeleteTemplateSet(r.PathValue("name")); err != nil {
+		api.HTTPError(w, "Template set not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Template set deleted successfully"})
+}
+
+// validateTemplateSet checks a set's fields, that its overrides parse and
+// that none of its partners already use another set. It writes the error
+// response and returns false when the set is invalid.
+func (h *CountyHandler) validateTemplateSet(w http.ResponseWriter, r *http.Request, set *models.TemplateSet) bool {
+	if err := set.Validate(); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if _, err := templates.Compile(set.Templates); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	for _, partner := range set.Partners {
+		assigned, err := h.store.PartnerTemplateSet(partner)
+		if err != nil {
+			requestLogger(r).Error("Error fetching template set", "partner", partner, "error", err)
+			api.HTTPError(w, "Error fetching template sets", http.StatusInternalServerError)
+			return false
+		}
+		if assigned != nil && assigned.ID != set.ID {
+			api.HTTPError(w, fmt.Sprintf("Partner %q already uses template set %q", partner, assigned.Name), http.StatusConflict)
+			return false
+		}
+	}
+	return true
+}
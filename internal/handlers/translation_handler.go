@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"era/internal/api"
+	"era/internal/i18n"
+	"era/internal/models"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// contestTranslations looks up translations of contests into a language.
+// Pages fall back to the original names, so lookup errors are only logged.
+func (h *CountyHandler) contestTranslations(lang string, contestIDs []string) map[string]models.ContestTranslation {
+	if lang == i18n.Default {
+		return nil
+	}
+	translations, err := h.store.ContestTranslations(lang, contestIDs)
+	if err != nil {
+		slog.Error("Error fetching translations", "lang", lang, "error", err)
+		return nil
+	}
+	return translations
+}
+
+// validateTranslationLang checks that a translation is into a supported
+// language other than the one results are published in
+func validateTranslationLang(lang string) error {
+	if !i18n.Supported(lang) || lang == i18n.Default {
+		return fmt.Errorf("lang must be one of %v, other than %s", i18n.Languages, i18n.Default)
+	}
+	return nil
+}
+
+// Translation Handlers
+//
+// HandleSaveContestTranslation creates or replaces a contest's translation
+// into the {lang} language
+func (h *CountyHandler) HandleSaveContestTranslation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var translation models.ContestTranslation
+	if err := json.NewDecoder(r.Body).Decode(&translation); err != nil {
+		api.HTTPError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	translation.ContestID = r.PathValue("id")
+	translation.Lang = r.PathValue("lang")
+
+	if err := translation.Validate(); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateTranslationLang(translation.Lang); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.SaveContestTranslation(&translation); err != nil {
+		requestLogger(r).Error("Error saving translation", "lang", translation.Lang, "contest_id", translation.ContestID, "error", err)
+		api.HTTPError(w, "Error saving translation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(translation)
+}
+
+// HandleBulkSaveTranslations imports many contest translations at once, such
+// as a county's published list of translated contest names. Invalid entries
+// are reported and skipped.
+func (h *CountyHandler) HandleBulkSaveTranslations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var translations []models.ContestTranslation
+	if err := json.NewDecoder(r.Body).Decode(&translations); err != nil {
+		api.HTTPError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	response := BulkSaveResponse{TotalSubmitted: len(translations)}
+	for i := range translations {
+		translation := &translations[i]
+		if err := translation.Validate(); err != nil {
+			response.Errors = append(response.Errors, fmt.Sprintf("entry %d: %v", i, err))
+			continue
+		}
+		if err := validateTranslationLang(translation.Lang); err != nil {
+			response.Errors = append(response.Errors, fmt.Sprintf("entry %d: %v", i, err))
+			continue
+		}
+		if err := h.store.SaveContestTranslation(translation); err != nil {
+			requestLogger(r).Error("Error saving translation", "lang", translation.Lang, "contest_id", translation.ContestID, "error", err)
+			response.Errors = append(response.Errors, fmt.Sprintf("entry %d: failed to save", i))
+			continue
+		}
+		response.SavedCount++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *CountyHandler) HandleGetContestTranslations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contestID := r.PathValue("id")
+	if _, _, err := models.ParseContestID(contestID); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	translations, err := h.store.GetContestTranslations(contestID)
+	if err != nil {
+		requestLogger(r).Error("Error fetching translations", "contest_id", contestID, "error", err)
+		api.HTTPError(w, "Error fetching translations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(translations)
+}
+
+func (h *CountyHandler) HandleDeleteContestTranslation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.store.DeleteContestTranslation(r.PathValue("id"), r.PathValue("lang")); err != nil {
+		api.HTTPError(w, "Translation not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Translation deleted successfully"})
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"encoding/json"
+	"era/internal/api"
+	"era/internal/models"
+	"era/internal/storage"
+	"era/internal/webhooks"
+	"net/http"
+	"strconv"
+)
+
+// defaultDeliveryLimit is how many delivery log entries are returned by default
+const defaultDeliveryLimit = 50
+
+// PingResponse identifies a queued test delivery
+type PingResponse struct {
+	Message    string `json:"message"`
+	DeliveryID string `json:"delivery_id"`
+}
+
+// WebhookHandler manages webhook subscriptions
+type WebhookHandler struct {
+	store      *storage.PocketBaseStore
+	dispatcher *webhooks.Dispatcher
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(store *storage.PocketBaseStore, dispatcher *webhooks.Dispatcher) *WebhookHandler {
+	return &WebhookHandler{
+		store:      store,
+		dispatcher: dispatcher,
+	}
+}
+
+// HandleCreateWebhook registers a new subscription. A signing secret is
+// generated when none is supplied and is only returned in this response.
+func (h *WebhookHandler) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	webhook := models.Webhook{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		api.HTTPError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if webhook.Secret == "" {
+		secret, err := webhooks.NewSecret()
+		if err != nil {
+			requestLogger(r).Error("Error generating webhook secret", "error", err)
+			api.HTTPError(w, "Error creating webhook", http.StatusInternalServerError)
+			return
+		}
+		webhook.Secret = secret
+	}
+	if webhook.County != "" {
+		webhook.County = models.CountySlug(webhook.County)
+	}
+
+	if err := webhook.Validate(); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.SaveWebhook(&webhook); err != nil {
+		requestLogger(r).Error("Error saving webhook", "error", err)
+		api.HTTPError(w, "Error saving webhook", http.StatusInternalServerError)
+		return
+	}
+
+	requestLogger(r).Info("Webhook registered", "webhook_id", webhook.ID, "url", webhook.URL, "by", principalName(r))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+func (h *WebhookHandler) HandleGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	list, err := h.store.GetWebhooks()
+	if err != nil {
+		requestLogger(r).Error("Error fetching webhooks", "error", err)
+		api.HTTPError(w, "Error fetching webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	// Secrets are never returned after creation
+	for i := range list {
+		list[i].Secret = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (h *WebhookHandler) HandleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	webhook, err := h.store.GetWebhook(r.PathValue("id"))
+	if err != nil {
+		api.HTTPError(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	webhook.Secret = ""
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// HandleUpdateWebhook replaces a subscription. The existing secret is kept
+// unless a new one is supplied.
+func (h *WebhookHandler) HandleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	existing, err := h.store.GetWebhook(r.PathValue("id"))
+	if err != nil {
+		api.HTTPError(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	webhook := models.Webhook{Active: existing.Active}
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		api.HTTPError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	webhook.ID = existing.ID
+	if webhook.Secret == "" {
+		webhook.Secret = existing.Secret
+	}
+	if webhook.County != "" {
+		webhook.County = models.CountySlug(webhook.County)
+	}
+
+	if err := webhook.Validate(); err != nil {
+		api.HTTPError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.UpdateWebhook(&webhook); err != nil {
+		requestLogger(r).Error("Error updating webhook", "webhook_id", webhook.ID, "error", err)
+		api.HTTPError(w, "Error updating webhook", http.StatusInternalServerError)
+		return
+	}
+	webhook.Secret = ""
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhook)
+}
+
+func (h *WebhookHandler) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.store.DeleteWebhook(r.PathValue("id")); err != nil {
+		api.HTTPError(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Webhook deleted successfully"})
+}
+
+// HandleGetWebhookDeliveries returns a webhook's delivery log, newest first.
+// ?limit= caps the number of entries.
+func (h *WebhookHandler) HandleGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	if _, err := h.store.GetWebhook(id); err != nil {
+		api.HTTPError(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			api.HTTPError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.store.GetWebhookDeliveries(id, limit)
+	if err != nil {
+		requestLogger(r).Error("Error fetching webhook deliveries", "webhook_id", id, "error", err)
+		api.HTTPError(w, "Error fetching deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// HandleTestWebhook queues a ping delivery so partners can check their
+// endpoint and signature verification
+func (h *WebhookHandler) HandleTestWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		api.HTTPError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	webhook, err := h.store.GetWebhook(r.PathValue("id"))
+	if err != nil {
+		api.HTTPError(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	deliveryID, err := h.dispatcher.Send(*webhook, webhooks.EventPing, map[string]string{
+		"webhook_id": webhook.ID,
+	})
+	if err != nil {
+		requestLogger(r).Error("Error queueing webhook ping", "webhook_id", webhook.ID, "error", err)
+		api.HTTPError(w, "Error queueing delivery", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(PingResponse{
+		Message:    "Ping queued",
+		DeliveryID: deliveryID,
+	})
+}
@@ -0,0 +1,150 @@
+package health
+
+import (
+	"context"
+	"era/internal/models"
+	"era/internal/parser"
+	"era/internal/storage"
+	"era/internal/urlpolicy"
+	"era/internal/webhooks"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Database fails when the PocketBase database doesn't answer a query
+func Database(store *storage.PocketBaseStore) Check {
+	return Check{
+		Name:     "database",
+		Critical: true,
+		Run: func(ctx context.Context) (interface{}, error) {
+			return nil, store.Ping(ctx)
+		},
+	}
+}
+
+// TempDir fails when downloads can't be written to dir, or the system
+// default when dir is empty
+func TempDir(dir string) Check {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	details := map[string]string{"path": dir}
+	return Check{
+		Name:     "temp_dir",
+		Critical: true,
+		Run: func(ctx context.Context) (interface{}, error) {
+			f, err := os.CreateTemp(dir, "era-health-*")
+			if err != nil {
+				return details, err
+			}
+			defer os.Remove(f.Name())
+			if _, err := f.WriteString("ok"); err != nil {
+				f.Close()
+				return details, err
+			}
+			return details, f.Close()
+		},
+	}
+}
+
+// DiskDetails describes the free space on the filesystem holding a path
+type DiskDetails struct {
+	Path         string `json:"path"`
+	FreeBytes    int64  `json:"free_bytes"`
+	MinFreeBytes int64  `json:"min_free_bytes"`
+}
+
+// DiskSpace fails when the filesystem holding path has less than minFree
+// bytes available
+func DiskSpace(path string, minFree int64) Check {
+	return Check{
+		Name:     "disk",
+		Critical: true,
+		Run: func(ctx context.Context) (interface{}, error) {
+			free, err := freeSpace(path)
+			if err != nil {
+				return nil, err
+			}
+			details := DiskDetails{Path: path, FreeBytes: free, MinFreeBytes: minFree}
+			if free < minFree {
+				return details, fmt.Errorf("%dMB free, below the %s required", free>>20, urlpolicy.FormatSize(minFree))
+			}
+			return details, nil
+		},
+	}
+}
+
+// Scheduler fails when background work has stopped: queued webhook
+// deliveries aren't being taken, or a refresh of stale results is stuck
+func Scheduler(dispatcher *webhooks.Dispatcher, refresher *parser.Refresher) Check {
+	return Check{
+		Name:     "scheduler",
+		Critical: true,
+		Run: func(ctx context.Context) (interface{}, error) {
+			details := map[string]int{
+				"webhook_queue_depth":    dispatcher.Queued(),
+				"refresh_jobs_in_flight": refresher.InFlight(),
+			}
+			return details, errors.Join(dispatcher.Check(), refresher.Check())
+		},
+	}
+}
+
+// CountyFreshness describes a county's latest snapshot
+type CountyFreshness struct {
+	// LatestSnapshot is when the latest snapshot was stored, or null when
+	// the county has none
+	LatestSnapshot *time.Time `json:"latest_snapshot"`
+	AgeSeconds     int64      `json:"age_seconds,omitempty"`
+	Stale          bool       `json:"stale"`
+}
+
+// Freshness warns when any county with a county link has no snapshot newer
+// than staleAfter. It never fails: stale results are worth showing on the
+// status page, not a reason to stop serving the ones there are.
+func Freshness(store *storage.PocketBaseStore, staleAfter time.Duration) Check {
+	return Check{
+		Name: "freshness",
+		Run: func(ctx context.Context) (interface{}, error) {
+			links, err := store.GetAllCountyLinks()
+			if err != nil {
+				return nil, err
+			}
+
+			counties := make(map[string]CountyFreshness)
+			stale := 0
+			for _, link := range links {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				county := models.CountySlug(link.CountyName)
+				if _, ok := counties[county]; ok {
+					continue
+				}
+				latest, err := store.LatestSnapshot(county)
+				if err != nil {
+					return nil, err
+				}
+
+				var freshness CountyFreshness
+				if latest != nil {
+					createdAt := latest.CreatedAt
+					freshness.LatestSnapshot = &createdAt
+					freshness.AgeSeconds = int64(time.Since(createdAt).Seconds())
+				}
+				freshness.Stale = latest == nil || time.Since(latest.CreatedAt) > staleAfter
+				if freshness.Stale {
+					stale++
+				}
+				counties[county] = freshness
+			}
+
+			if stale > 0 {
+				return counties, fmt.Errorf("%d of %d counties have no snapshot newer than %s", stale, len(counties), staleAfter)
+			}
+			return counties, nil
+		},
+	}
+}
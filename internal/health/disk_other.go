@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package health
+
+import (
+	"errors"
+	"runtime"
+)
+
+// freeSpace isn't implemented on this platform, so the disk check fails
+func freeSpace(path string) (int64, error) {
+	return 0, errors.New("free disk space can't be checked on " + runtime.GOOS)
+}
@@ -0,0 +1,145 @@
+// Package health reports whether the server is alive and ready to serve.
+// Each endpoint runs a set of checks and answers with a JSON breakdown of
+// them, for fly.io health checks and the status page.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Statuses of checks and reports
+const (
+	StatusOK   = "ok"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// Check is a single named check. Run returns an error when the check fails,
+// along with any details worth showing either way.
+type Check struct {
+	Name string
+	// Critical checks fail the report when they fail; other checks only
+	// make it warn
+	Critical bool
+	Run      func(ctx context.Context) (details interface{}, err error)
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Status     string      `json:"status"`
+	Error      string      `json:"error,omitempty"`
+	Details    interface{} `json:"details,omitempty"`
+	DurationMs int64       `json:"duration_ms"`
+}
+
+// Report is the outcome of every check: fail when a critical check failed,
+// warn when any other did, ok otherwise
+type Report struct {
+	Status string            `json:"status"`
+	Time   time.Time         `json:"time"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker runs a set of checks, each bounded by a timeout
+type Checker struct {
+	checks  []Check
+	timeout time.Duration
+}
+
+// NewChecker creates a checker whose checks may each take up to timeout
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers checks
+func (c *Checker) Add(checks ...Check) {
+	c.checks = append(c.checks, checks...)
+}
+
+// Run runs every check concurrently. A check still running at the timeout
+// fails without waiting for it.
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{
+		Status: StatusOK,
+		Time:   time.Now().UTC(),
+		Checks: make(map[string]Result, len(c.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := c.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			switch {
+			case result.Status == StatusFail && check.Critical:
+				report.Status = StatusFail
+			case result.Status != StatusOK && report.Status == StatusOK:
+				report.Status = StatusWarn
+			}
+		}(check)
+	}
+	wg.Wait()
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	type outcome struct {
+		details interface{}
+		err     error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		details, err := check.Run(ctx)
+		done <- outcome{details, err}
+	}()
+
+	failed := StatusWarn
+	if check.Critical {
+		failed = StatusFail
+	}
+
+	var result Result
+	select {
+	case o := <-done:
+		result = Result{Status: StatusOK, Details: o.details}
+		if o.err != nil {
+			result.Status = failed
+			result.Error = o.err.Error()
+		}
+	case <-ctx.Done():
+		result = Result{Status: failed, Error: "timed out after " + c.timeout.String()}
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result
+}
+
+// Handler serves the report as JSON, with status 503 when it fails so load
+// balancers and health checks take the machine out of rotation
+func (c *Checker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+
+		status := http.StatusOK
+		if report.Status == StatusFail {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	})
+}
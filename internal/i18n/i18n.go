@@ -0,0 +1,140 @@
+// Package i18n picks the language a reader asked for and translates the
+// interface strings of the HTML pages. Contest names and descriptions are
+// translated separately from stored contest translations.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// Default is the language results are published in
+const Default = "en"
+
+// Languages lists the supported languages, by the codes used in ?lang= and
+// stored translations
+var Languages = []string{Default, "es", "zh", "vi"}
+
+// tags are the Languages in matching order; the first is the fallback
+var tags = []language.Tag{
+	language.English,
+	language.Spanish,
+	language.TraditionalChinese,
+	language.Vietnamese,
+}
+
+var matcher = language.NewMatcher(tags)
+
+// timeLayouts format timestamps for each language
+var timeLayouts = map[string]string{
+	"en": "Jan 2, 2006 3:04 PM MST",
+	"es": "2/1/2006 15:04 MST",
+	"zh": "2006年1月2日 15:04 MST",
+	"vi": "15:04 MST, 2/1/2006",
+}
+
+var locales = loadLocales()
+
+// Locale translates interface strings into one language
+type Locale struct {
+	Lang     string
+	messages map[string]string
+}
+
+// For returns the locale of a supported language, or of the default
+// language otherwise
+func For(lang string) *Locale {
+	if locale, ok := locales[lang]; ok {
+		return locale
+	}
+	return locales[Default]
+}
+
+// T translates an interface string, falling back to the English text. With
+// args, the translation is used as a fmt format.
+func (l *Locale) T(msg string, args ...interface{}) string {
+	if translated, ok := l.messages[msg]; ok {
+		msg = translated
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Time formats a timestamp in UTC the way the language writes dates
+func (l *Locale) Time(t time.Time) string {
+	return t.UTC().Format(timeLayouts[l.Lang])
+}
+
+// Supported reports whether lang is one of the Languages
+func Supported(lang string) bool {
+	_, ok := locales[lang]
+	return ok
+}
+
+// Lang returns the language a request asks for: the closest supported match
+// of ?lang=, then of the Accept-Language header, then the default
+func Lang(r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		if tag, err := language.Parse(lang); err == nil {
+			if match, ok := match(tag); ok {
+				return match
+			}
+		}
+	}
+
+	requested, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if err == nil {
+		if match, ok := match(requested...); ok {
+			return match
+		}
+	}
+	return Default
+}
+
+func match(requested ...language.Tag) (string, bool) {
+	if len(requested) == 0 {
+		return "", false
+	}
+	_, index, confidence := matcher.Match(requested...)
+	if confidence == language.No {
+		return "", false
+	}
+	return Languages[index], true
+}
+
+// Negotiate returns the locale of a request and marks the response as
+// varying with the Accept-Language header
+func Negotiate(w http.ResponseWriter, r *http.Request) *Locale {
+	locale := For(Lang(r))
+	w.Header().Set("Content-Language", locale.Lang)
+	w.Header().Add("Vary", "Accept-Language")
+	return locale
+}
+
+func loadLocales() map[string]*Locale {
+	result := map[string]*Locale{
+		Default: {Lang: Default, messages: map[string]string{}},
+	}
+	for _, lang := range Languages[1:] {
+		data, err := localeFiles.ReadFile("locales/" + lang + ".json")
+		if err != nil {
+			panic(err)
+		}
+		messages := make(map[string]string)
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("invalid %s locale: %v", lang, err))
+		}
+		result[lang] = &Locale{Lang: lang, messages: messages}
+	}
+	return result
+}
@@ -0,0 +1,179 @@
+// Package logging sets up the server's structured logs. Every request gets
+// an ID that is returned in X-Request-ID and attached to everything logged
+// while serving it, including the parse runs it starts, so one request's
+// lines can be found among election night's traffic.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// HeaderRequestID carries the request ID. Clients and proxies may send one
+// to correlate their own logs; otherwise one is generated.
+const HeaderRequestID = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs sent by clients
+const maxRequestIDLength = 64
+
+// Formats logs can be written in
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// ParseLevel parses a level name: debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// Options configures the default logger
+type Options struct {
+	Level  slog.Level
+	Format string
+	// RowSample logs only every nth per-row record, see PerRow
+	RowSample int
+}
+
+// rowSample is the sampling rate of loggers returned by PerRow
+var rowSample = 1
+
+// Setup makes the default logger write records at the configured level or
+// above to w. Output of the standard log package goes through it too.
+func Setup(w io.Writer, options Options) error {
+	handlerOptions := &slog.HandlerOptions{Level: options.Level}
+	var handler slog.Handler
+	switch options.Format {
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, handlerOptions)
+	case FormatText:
+		handler = slog.NewTextHandler(w, handlerOptions)
+	default:
+		return fmt.Errorf("unknown log format %q, expected json or text", options.Format)
+	}
+	slog.SetDefault(slog.New(handler))
+	rowSample = options.RowSample
+	return nil
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying logger
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With returns a copy of ctx whose logger adds args to every record
+func With(ctx context.Context, args ...interface{}) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(args...))
+}
+
+// Middleware gives every request an ID, returned in X-Request-ID, and a
+// logger adding it to every record logged while serving the request
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderRequestID)
+		if !validRequestID(id) {
+			id = NewID()
+		}
+		w.Header().Set(HeaderRequestID, id)
+		next.ServeHTTP(w, r.WithContext(With(r.Context(), "request_id", id)))
+	})
+}
+
+// NewID returns a random ID for a request or parse run
+func NewID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	return strings.Trim(id, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.:") == ""
+}
+
+// Sampled returns a logger that writes the first few debug and info records
+// with each message, then only every nth, so per-row logs can't flood the
+// output. Warnings and errors are always written. An n of 1 or less writes
+// every record.
+func Sampled(logger *slog.Logger, n int) *slog.Logger {
+	if n <= 1 {
+		return logger
+	}
+	return slog.New(&samplingHandler{
+		Handler: logger.Handler(),
+		sampler: &sampler{every: n, counts: make(map[string]int)},
+	})
+}
+
+// PerRow returns a copy of ctx whose logger samples records at the
+// configured row sampling rate. Call it once per file of rows, so the rows
+// share a sampler.
+func PerRow(ctx context.Context) context.Context {
+	return NewContext(ctx, Sampled(FromContext(ctx), rowSample))
+}
+
+// sampledFirst is how many records with each message are written before
+// sampling starts
+const sampledFirst = 3
+
+type sampler struct {
+	every int
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (s *sampler) allow(message string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[message]++
+	n := s.counts[message]
+	return n <= sampledFirst || (n-sampledFirst)%s.every == 0
+}
+
+// samplingHandler drops the records its sampler doesn't allow. Handlers
+// derived with attributes or groups share the sampler.
+type samplingHandler struct {
+	slog.Handler
+	sampler *sampler
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level < slog.LevelWarn && !h.sampler.allow(record.Message) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithAttrs(attrs), sampler: h.sampler}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithGroup(name), sampler: h.sampler}
+}
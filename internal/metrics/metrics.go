@@ -0,0 +1,282 @@
+// Package metrics exposes the server's Prometheus metrics: HTTP requests by
+// route, parse runs, the freshness of each county's results and the depth of
+// the background job queues.
+package metrics
+
+import (
+	"era/internal/models"
+	"era/internal/parser"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric name
+const namespace = "era"
+
+// unmatchedRoute labels requests no route matched, so unknown paths can't
+// grow the number of series
+const unmatchedRoute = "unmatched"
+
+// otherCounty labels parse runs of counties without a county link, so the
+// county names sent with parse requests can't grow the number of series
+const otherCounty = "other"
+
+// CountyLoader returns the slugs of the counties with a county link
+type CountyLoader func() ([]string, error)
+
+// Metrics holds the collectors and the registry they are served from
+type Metrics struct {
+	registry *prometheus.Registry
+	counties CountyLoader
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+
+	parses          *prometheus.CounterVec
+	parseDuration   *prometheus.HistogramVec
+	parseDownloaded *prometheus.CounterVec
+	parseRows       *prometheus.CounterVec
+
+	freshness *freshnessCollector
+}
+
+// New creates the collectors, along with the Go runtime and process metrics.
+// Parse runs and snapshots are labeled by county only for the counties
+// counties returns.
+func New(counties CountyLoader) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		counties: counties,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests served, by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests, by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		parses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "parse_runs_total",
+			Help:      "Parse runs, by county, method and the stage they ended at: complete, or the stage that failed.",
+		}, []string{"county", "method", "stage"}),
+		parseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "parse_duration_seconds",
+			Help:      "Time taken by parse runs, by county, method and the stage they ended at.",
+			Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+		}, []string{"county", "method", "stage"}),
+		parseDownloaded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "parse_downloaded_bytes_total",
+			Help:      "Bytes downloaded by parse runs, by county and method.",
+		}, []string{"county", "method"}),
+		parseRows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "parse_rows_stored_total",
+			Help:      "Result rows stored by successful parse runs, by county and method.",
+		}, []string{"county", "method"}),
+		freshness: &freshnessCollector{latest: make(map[string]time.Time)},
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.requestDuration,
+		m.parses, m.parseDuration, m.parseDownloaded, m.parseRows,
+		m.freshness,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Gauge reports the value of depth, read at every scrape, as a gauge named
+// era_<name>
+func (m *Metrics) Gauge(name, help string, depth func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, func() float64 {
+		return float64(depth())
+	}))
+}
+
+// ObserveParse records a finished parse run. It is registered as a parser
+// manager parse listener.
+func (m *Metrics) ObserveParse(run parser.ParseRun) {
+	county := m.countyLabel(run.County)
+	m.parses.WithLabelValues(county, run.Method, run.Stage).Inc()
+	m.parseDuration.WithLabelValues(county, run.Method, run.Stage).Observe(run.Duration.Seconds())
+	m.parseDownloaded.WithLabelValues(county, run.Method).Add(float64(run.Downloaded))
+	m.parseRows.WithLabelValues(county, run.Method).Add(float64(run.Rows))
+}
+
+// HandleIngest records when a county's latest snapshot was stored. It is
+// registered as a parser manager ingest listener. Counties without a county
+// link aren't recorded, since their snapshot ages can't share a series.
+func (m *Metrics) HandleIngest(result *models.IngestResult) {
+	if m.countyLabel(result.Snapshot.County) == otherCounty {
+		return
+	}
+	m.SetSnapshot(result.Snapshot.County, result.Snapshot.CreatedAt)
+}
+
+// countyLabel returns the county if it has a county link, or otherCounty
+func (m *Metrics) countyLabel(county string) string {
+	if m.counties == nil {
+		return otherCounty
+	}
+	counties, err := m.counties()
+	if err != nil {
+		slog.Error("Error loading counties for metrics", "error", err)
+		return otherCounty
+	}
+	for _, linked := range counties {
+		if linked == county {
+			return county
+		}
+	}
+	return otherCounty
+}
+
+// SetSnapshot records when a county's latest snapshot was stored, for
+// counties whose results were stored before the server started
+func (m *Metrics) SetSnapshot(county string, createdAt time.Time) {
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	m.freshness.set(county, createdAt)
+}
+
+// Instrument counts and times every request. route names the route a
+// request matched, or "" for none, so requests are labeled by route rather
+// than by path.
+func (m *Metrics) Instrument(route func(r *http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		name := route(r)
+		if name == "" {
+			name = unmatchedRoute
+		}
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		m.requests.WithLabelValues(name, r.Method, strconv.Itoa(status)).Inc()
+		m.requestDuration.WithLabelValues(name, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Routes names requests by the pattern they match on mux. Requests routed
+// to a nested mux, like the unversioned API, are named by the nested mux's
+// pattern; nested maps a pattern on mux to its nested mux.
+func Routes(mux *http.ServeMux, nested map[string]*http.ServeMux) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		if inner, ok := nested[pattern]; ok {
+			_, pattern = inner.Handler(r)
+		}
+		// Patterns may start with a method, which is already a label
+		if _, path, ok := strings.Cut(pattern, " "); ok {
+			pattern = path
+		}
+		return pattern
+	}
+}
+
+// statusWriter records the status of a response as it is written
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Flush lets streamed responses, like live events and exports, reach the
+// client as they are written
+func (sw *statusWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// freshnessCollector reports when each county's latest snapshot was stored
+// and how old it is at scrape time
+type freshnessCollector struct {
+	mu     sync.Mutex
+	latest map[string]time.Time
+}
+
+var (
+	snapshotTimestampDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "snapshot", "timestamp_seconds"),
+		"Unix time the county's latest snapshot was stored.",
+		[]string{"county"}, nil,
+	)
+	snapshotAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "snapshot", "age_seconds"),
+		"Age of the county's latest snapshot.",
+		[]string{"county"}, nil,
+	)
+)
+
+func (c *freshnessCollector) set(county string, createdAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if createdAt.After(c.latest[county]) {
+		c.latest[county] = createdAt
+	}
+}
+
+func (c *freshnessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- snapshotTimestampDesc
+	ch <- snapshotAgeDesc
+}
+
+func (c *freshnessCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for county, createdAt := range c.latest {
+		ch <- prometheus.MustNewConstMetric(snapshotTimestampDesc, prometheus.GaugeValue,
+			float64(createdAt.UnixNano())/1e9, county)
+		ch <- prometheus.MustNewConstMetric(snapshotAgeDesc, prometheus.GaugeValue,
+			now.Sub(createdAt).Seconds(), county)
+	}
+}
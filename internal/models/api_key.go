@@ -0,0 +1,145 @@
+package models
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Permissions a route can require
+const (
+    PermissionRead      = "read"      // read county links and template sets
+    PermissionLinks     = "links"     // manage county links
+    PermissionParse     = "parse"     // parse results into snapshots
+    PermissionCalls     = "calls"     // call and retract races
+    PermissionTranslate = "translate" // manage contest translations
+    PermissionExport    = "export"    // export results and the AP feed
+    PermissionAdmin     = "admin"     // everything else, including keys
+)
+
+// Roles group the permissions of the people using the API
+const (
+    RoleAdmin    = "admin"
+    RoleEditor   = "editor"
+    RoleDataDesk = "data_desk"
+    RolePartner  = "partner"
+)
+
+// Roles lists every role
+var Roles = []string{RoleAdmin, RoleEditor, RoleDataDesk, RolePartner}
+
+// rolePermissions are the permissions each role grants, besides admin
+// which grants all of them
+var rolePermissions = map[string][]string{
+    RoleEditor:   {PermissionRead, PermissionCalls, PermissionTranslate, PermissionExport},
+    RoleDataDesk: {PermissionRead, PermissionLinks, PermissionParse, PermissionExport},
+    RolePartner:  {PermissionRead, PermissionExport},
+}
+
+// API key scopes, the coarser alternative to a role. Each scope includes
+// the ones before it, so an admin key can also ingest and read.
+const (
+    ScopeRead   = "read"
+    ScopeIngest = "ingest"
+    ScopeAdmin  = "admin"
+)
+
+// APIKeyScopes lists every scope from least to most privileged
+var APIKeyScopes = []string{ScopeRead, ScopeIngest, ScopeAdmin}
+
+// scopePermissions are the permissions each scope grants on its own
+var scopePermissions = map[string][]string{
+    ScopeRead:   {PermissionRead, PermissionExport},
+    ScopeIngest: {PermissionParse, PermissionCalls, PermissionTranslate},
+}
+
+// APIKey grants a client a role, or the scopes it lists. Keys other than
+// admin keys may be limited to some elections. Only a hash of the key is
+// stored; the key itself is returned once, when it is created.
+type APIKey struct {
+    ID         string     `json:"id,omitempty"`
+    Name       string     `json:"name"`
+    Role       string     `json:"role,omitempty"`
+    Scopes     []string   `json:"scopes,omitempty"`
+    Elections  []string   `json:"elections,omitempty"`
+    Prefix     string     `json:"prefix,omitempty"`
+    Key        string     `json:"key,omitempty"`
+    LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+    CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Validate ensures all required fields are present and valid
+func (k *APIKey) Validate() error {
+    if strings.TrimSpace(k.Name) == "" {
+        return fmt.Errorf("name is required")
+    }
+    if (k.Role == "") == (len(k.Scopes) == 0) {
+        return fmt.Errorf("exactly one of role and scopes is required")
+    }
+    if k.Role != "" && !isRole(k.Role) {
+        return fmt.Errorf("invalid role %q, must be one of %v", k.Role, Roles)
+    }
+    for _, scope := range k.Scopes {
+        if scopeRank(scope) < 0 {
+            return fmt.Errorf("invalid scope %q, must be one of %v", scope, APIKeyScopes)
+        }
+    }
+    for _, election := range k.Elections {
+        if strings.TrimSpace(election) == "" {
+            return fmt.Errorf("elections must not be empty")
+        }
+    }
+    if len(k.Elections) > 0 && GrantsPermission(k.Role, k.Scopes, PermissionAdmin) {
+        return fmt.Errorf("admin keys can't be limited to elections")
+    }
+    return nil
+}
+
+// GrantsPermission reports whether a role or any of the scopes grants a
+// permission
+func GrantsPermission(role string, scopes []string, permission string) bool {
+    if role == RoleAdmin {
+        return true
+    }
+    if contains(rolePermissions[role], permission) {
+        return true
+    }
+    for _, scope := range scopes {
+        rank := scopeRank(scope)
+        if rank < 0 {
+            continue
+        }
+        if scope == ScopeAdmin {
+            return true
+        }
+        // A scope includes the ones before it
+        for _, included := range APIKeyScopes[:rank+1] {
+            if contains(scopePermissions[included], permission) {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+func isRole(role string) bool {
+    return contains(Roles, role)
+}
+
+func scopeRank(scope string) int {
+    for i, s := range APIKeyScopes {
+        if s == scope {
+            return i
+        }
+    }
+    return -1
+}
+
+func contains(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
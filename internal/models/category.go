@@ -0,0 +1,80 @@
+package models
+
+import "strings"
+
+// Contest categories, in the order they appear on the ballot
+const (
+    CategoryFederal  = "federal"
+    CategoryState    = "state"
+    CategoryCounty   = "county"
+    CategoryCity     = "city"
+    CategorySchool   = "school"
+    CategoryOther    = "other"
+    CategoryMeasures = "measures"
+)
+
+// ContestCategories lists the categories in ballot order
+var ContestCategories = []string{
+    CategoryFederal,
+    CategoryState,
+    CategoryCounty,
+    CategoryCity,
+    CategorySchool,
+    CategoryOther,
+    CategoryMeasures,
+}
+
+// categoryKeywords are matched against contest names in order, so more
+// specific offices are checked before the words they contain (a "County
+// Board of Education" is a county office, a "City Controller" isn't a
+// state one)
+var categoryKeywords = []struct {
+    category string
+    words    []string
+}{
+    {CategoryFederal, []string{"president", "united states", "u.s.", "us senator", "us representative", "congress"}},
+    {CategoryCounty, []string{"county"}},
+    {CategoryCity, []string{"city", "town", "mayor", "council"}},
+    {CategoryState, []string{"governor", "state", "assembly", "attorney general", "controller", "treasurer",
+        "insurance commissioner", "public instruction", "board of equalization", "supreme court", "court of appeal"}},
+    {CategorySchool, []string{"school", "unified", "college", "education", "university"}},
+    {CategoryCounty, []string{"supervisor", "supervisors", "sheriff", "assessor", "district attorney", "superior court", "judge"}},
+}
+
+// ContestCategory classifies a contest by its name and result type. Every
+// measure is in CategoryMeasures; offices matching no keyword, such as
+// special districts, are in CategoryOther.
+func ContestCategory(contestName, resultType string) string {
+    if resultType == "measure" {
+        return CategoryMeasures
+    }
+    name := " " + strings.ToLower(contestName) + " "
+    for _, group := range categoryKeywords {
+        for _, word := range group.words {
+            if containsWord(name, word) {
+                return group.category
+            }
+        }
+    }
+    return CategoryOther
+}
+
+// containsWord reports whether word appears in s between non-letters, so
+// "state" doesn't match "statewide" and "city" doesn't match "electricity"
+func containsWord(s, word string) bool {
+    for i := 0; ; {
+        j := strings.Index(s[i:], word)
+        if j < 0 {
+            return false
+        }
+        start, end := i+j, i+j+len(word)
+        if !isLetter(s[start-1]) && (end == len(s) || !isLetter(s[end])) {
+            return true
+        }
+        i = start + 1
+    }
+}
+
+func isLetter(b byte) bool {
+    return b >= 'a' && b <= 'z'
+}
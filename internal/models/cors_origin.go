@@ -0,0 +1,43 @@
+package models
+
+import (
+    "fmt"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// CORSOrigin is an origin allowed to call the API from a browser, such as a
+// partner site embedding results. Origin is "*", an exact origin like
+// "https://news.example.com", or "https://*.example.com" for any of its
+// subdomains.
+type CORSOrigin struct {
+    ID          string    `json:"id,omitempty"`
+    Origin      string    `json:"origin"`
+    Description string    `json:"description,omitempty"`
+    CreatedAt   time.Time `json:"created_at"`
+}
+
+// Validate ensures all required fields are present and valid
+func (o *CORSOrigin) Validate() error {
+    return ValidateOriginPattern(o.Origin)
+}
+
+// ValidateOriginPattern checks an allowed origin is "*" or a scheme and
+// host, whose host may start with "*."
+func ValidateOriginPattern(pattern string) error {
+    if pattern == "*" {
+        return nil
+    }
+    u, err := url.Parse(strings.Replace(pattern, "://*.", "://wildcard.", 1))
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return fmt.Errorf("origin %q must look like https://example.com or https://*.example.com", pattern)
+    }
+    if u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+        return fmt.Errorf("origin %q must not have a path, query or credentials", pattern)
+    }
+    if strings.Contains(strings.TrimPrefix(u.Host, "wildcard."), "*") {
+        return fmt.Errorf("origin %q may only use * for a leading subdomain", pattern)
+    }
+    return nil
+}
@@ -1,6 +1,9 @@
 package models
 
-import "fmt"
+import (
+    "fmt"
+    "strings"
+)
 
 // ParseMethod represents the method used to parse county data
 type ParseMethod string
@@ -26,6 +29,7 @@ type CountyLink struct {
     CountyName  string      `json:"county_name"`
     Link        string      `json:"link"`
     ParseMethod ParseMethod `json:"parse_method"`
+    ElectionID  string      `json:"election_id,omitempty"`
 }
 
 // Validate ensures all required fields are present and valid
@@ -37,4 +41,10 @@ func (c *CountyLink) Validate() error {
         return fmt.Errorf("link is required")
     }
     return ValidateParseMethod(c.ParseMethod)
+}
+
+// CountySlug normalizes a county name into the form used for collection
+// names and URLs (e.g. "San Mateo" -> "san_mateo")
+func CountySlug(name string) string {
+    return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"))
 } 
\ No newline at end of file
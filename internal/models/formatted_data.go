@@ -4,10 +4,24 @@ package models
 type ElectionEntry struct {
     ID          string
     CountyID    string
+    ElectionID  string
+    SnapshotID  string
     Title       string
     ChoiceName  string
     Votes       int
     Percentage  float64
+    Party       string
+    // Description is the contest's description or measure text, when the
+    // source has one
+    Description string
+    // Precinct is set when the row reports a single precinct rather than
+    // the whole county
+    Precinct           string
+    PrecinctsTotal     int
+    PrecinctsReporting int
+    // BallotOrder is the row's position in the source data, which lists
+    // contests in the order they appear on the ballot
+    BallotOrder        int
     RawData     map[string]interface{}
 }
 
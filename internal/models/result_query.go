@@ -0,0 +1,121 @@
+package models
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "strings"
+)
+
+// Result sort fields. Prefix with "-" for descending order.
+const (
+    SortContest    = "contest"
+    SortVotes      = "votes"
+    SortPercentage = "percentage"
+)
+
+// Page size limits for result queries
+const (
+    DefaultPerPage = 50
+    MaxPerPage     = 500
+)
+
+// ResultQuery is a filtered, sorted and paginated results query for a
+// single county
+type ResultQuery struct {
+    Type       string
+    ElectionID string // empty matches every election
+    Contest    string // case-insensitive substring of the contest name
+    Choice     string // case-insensitive substring of the choice name
+    MinVotes   int
+    Sort       string
+    Page       int
+    PerPage    int
+    Cursor     string // continues from a previous page, overriding Page
+}
+
+// SortField returns the field and direction of the query's sort order,
+// defaulting to contest order
+func (q ResultQuery) SortField() (field string, desc bool) {
+    field = strings.TrimPrefix(q.Sort, "-")
+    if field == "" {
+        return SortContest, false
+    }
+    return field, strings.HasPrefix(q.Sort, "-")
+}
+
+// Validate checks the query and fills in paging defaults
+func (q *ResultQuery) Validate() error {
+    if q.Type != "" && q.Type != "candidate" && q.Type != "measure" {
+        return fmt.Errorf("type must be either 'candidate' or 'measure'")
+    }
+    if q.MinVotes < 0 {
+        return fmt.Errorf("min_votes must not be negative")
+    }
+    switch field, _ := q.SortField(); field {
+    case SortContest, SortVotes, SortPercentage:
+    default:
+        return fmt.Errorf("invalid sort: %s", q.Sort)
+    }
+
+    if q.Page == 0 {
+        q.Page = 1
+    }
+    if q.Page < 0 {
+        return fmt.Errorf("page must be positive")
+    }
+    if q.PerPage == 0 {
+        q.PerPage = DefaultPerPage
+    }
+    if q.PerPage < 0 || q.PerPage > MaxPerPage {
+        return fmt.Errorf("per_page must be between 1 and %d", MaxPerPage)
+    }
+
+    if q.Cursor != "" {
+        cursor, err := DecodeCursor(q.Cursor)
+        if err != nil {
+            return err
+        }
+        if cursor.Sort != q.Sort {
+            return fmt.Errorf("cursor was issued for a different sort order")
+        }
+    }
+    return nil
+}
+
+// ResultPage is one page of a results query
+type ResultPage struct {
+    Total      int         `json:"total"`
+    Page       int         `json:"page,omitempty"`
+    PerPage    int         `json:"per_page"`
+    TotalPages int         `json:"total_pages"`
+    NextCursor string      `json:"next_cursor,omitempty"`
+    Results    []ResultRow `json:"results"`
+}
+
+// Cursor marks the position of the last row on a page: its sort value and
+// its insertion order, which breaks ties
+type Cursor struct {
+    Sort  string      `json:"s"`
+    Value interface{} `json:"v"`
+    Seq   int64       `json:"r"`
+}
+
+// Encode returns the opaque form of the cursor handed to clients
+func (c Cursor) Encode() string {
+    data, _ := json.Marshal(c)
+    return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor produced by Encode
+func DecodeCursor(s string) (Cursor, error) {
+    var c Cursor
+    data, err := base64.RawURLEncoding.DecodeString(s)
+    if err != nil {
+        return c, fmt.Errorf("invalid cursor")
+    }
+    if err := json.Unmarshal(data, &c); err != nil {
+        return c, fmt.Errorf("invalid cursor")
+    }
+    return c, nil
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+)
+
+// ResultRow represents a single stored choice result for a contest
+type ResultRow struct {
+    ID          string  `json:"id"`
+    ElectionID  string  `json:"election_id,omitempty"`
+    County      string  `json:"county"`
+    ContestID   string  `json:"contest_id"`
+    Type        string  `json:"type"`
+    ContestName string  `json:"contest_name"`
+    ChoiceName  string  `json:"choice_name"`
+    Votes       int     `json:"votes"`
+    Percentage  float64 `json:"percentage"`
+    IsBond      bool    `json:"is_bond,omitempty"`
+    Party       string  `json:"party,omitempty"`
+    Precinct    string  `json:"precinct,omitempty"`
+    Description string  `json:"description,omitempty"`
+
+    PrecinctsTotal     int       `json:"precincts_total,omitempty"`
+    PrecinctsReporting int       `json:"precincts_reporting,omitempty"`
+    Updated            time.Time `json:"updated"`
+}
+
+// ResultFilter narrows a results query. Empty fields match everything.
+type ResultFilter struct {
+    ElectionID string
+    County     string
+    ContestID  string
+    Type       string
+}
+
+// Matches reports whether a row satisfies the filter
+func (f ResultFilter) Matches(row ResultRow) bool {
+    if f.ElectionID != "" && row.ElectionID != f.ElectionID {
+        return false
+    }
+    if f.County != "" && row.County != f.County {
+        return false
+    }
+    if f.ContestID != "" && row.ContestID != f.ContestID {
+        return false
+    }
+    if f.Type != "" && row.Type != f.Type {
+        return false
+    }
+    return true
+}
+
+// ChoiceTotals totals a single contest's rows per choice, most votes first,
+// with each choice's share of the total as its percentage. County-wide rows
+// are used when present; otherwise precinct rows are summed.
+func ChoiceTotals(rows []ResultRow) []ChoiceTotal {
+    countyWide := false
+    for _, row := range rows {
+        if row.Precinct == "" {
+            countyWide = true
+            break
+        }
+    }
+
+    var totals []ChoiceTotal
+    index := make(map[string]int)
+    total := 0
+    for _, row := range rows {
+        if countyWide && row.Precinct != "" {
+            continue
+        }
+        i, ok := index[row.ChoiceName]
+        if !ok {
+            i = len(totals)
+            index[row.ChoiceName] = i
+            totals = append(totals, ChoiceTotal{Name: row.ChoiceName})
+        }
+        totals[i].Votes += row.Votes
+        total += row.Votes
+    }
+
+    for i := range totals {
+        if total > 0 {
+            totals[i].Percentage = float64(totals[i].Votes) * 100 / float64(total)
+        }
+    }
+    sort.SliceStable(totals, func(i, j int) bool { return totals[i].Votes > totals[j].Votes })
+    return totals
+}
+
+// ContestSlug normalizes a contest name for use in identifiers
+// (e.g. "Measure A - Parcel Tax" -> "measure-a-parcel-tax")
+func ContestSlug(contestName string) string {
+    var b strings.Builder
+    pendingDash := false
+    for _, r := range strings.ToLower(contestName) {
+        if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+            if pendingDash && b.Len() > 0 {
+                b.WriteByte('-')
+            }
+            pendingDash = false
+            b.WriteRune(r)
+            continue
+        }
+        pendingDash = true
+    }
+    return b.String()
+}
+
+// ContestID builds the identifier used to address a single contest across
+// the API. It combines the county slug and the contest slug, e.g.
+// "marin.measure-a".
+func ContestID(county, contestName string) string {
+    return CountySlug(county) + "." + ContestSlug(contestName)
+}
+
+// ParseContestID splits a contest identifier into its county and contest slugs
+func ParseContestID(id string) (county, contest string, err error) {
+    county, contest, ok := strings.Cut(id, ".")
+    if !ok || county == "" || contest == "" {
+        return "", "", fmt.Errorf("invalid contest id: %s", id)
+    }
+    return county, contest, nil
+}
+
+// RaceCall records the declared winner of a contest
+type RaceCall struct {
+    ContestID  string    `json:"contest_id"`
+    ElectionID string    `json:"election_id,omitempty"`
+    Winner     string    `json:"winner"`
+    CalledBy   string    `json:"called_by,omitempty"`
+    CalledAt   time.Time `json:"called_at"`
+}
+
+// Validate ensures all required fields are present and valid
+func (c *RaceCall) Validate() error {
+    if _, _, err := ParseContestID(c.ContestID); err != nil {
+        return err
+    }
+    if strings.TrimSpace(c.Winner) == "" {
+        return fmt.Errorf("winner is required")
+    }
+    return nil
+}
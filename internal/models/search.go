@@ -0,0 +1,55 @@
+package models
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Search result limits
+const (
+    DefaultSearchLimit = 20
+    MaxSearchLimit     = 100
+)
+
+// SearchQuery is a full-text search across contests and choices
+type SearchQuery struct {
+    Text       string
+    ElectionID string
+    County     string
+    Type       string
+    Limit      int
+}
+
+// Validate checks the query and fills in the default limit
+func (q *SearchQuery) Validate() error {
+    q.Text = strings.TrimSpace(q.Text)
+    if q.Text == "" {
+        return fmt.Errorf("q is required")
+    }
+    if q.Type != "" && q.Type != "candidate" && q.Type != "measure" {
+        return fmt.Errorf("type must be either 'candidate' or 'measure'")
+    }
+    if q.Limit == 0 {
+        q.Limit = DefaultSearchLimit
+    }
+    if q.Limit < 0 || q.Limit > MaxSearchLimit {
+        return fmt.Errorf("limit must be between 1 and %d", MaxSearchLimit)
+    }
+    return nil
+}
+
+// SearchHit is a contest matching a search, with the choices that matched.
+// The translated fields are set when the request asks for a language the
+// contest has a translation into.
+type SearchHit struct {
+    ContestID             string   `json:"contest_id"`
+    ContestName           string   `json:"contest_name"`
+    County                string   `json:"county"`
+    ElectionID            string   `json:"election_id,omitempty"`
+    Type                  string   `json:"type"`
+    Description           string   `json:"description,omitempty"`
+    MatchedChoices        []string `json:"matched_choices,omitempty"`
+    Score                 float64  `json:"score"`
+    ContestNameTranslated string   `json:"contest_name_translated,omitempty"`
+    DescriptionTranslated string   `json:"description_translated,omitempty"`
+}
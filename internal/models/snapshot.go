@@ -0,0 +1,165 @@
+package models
+
+import (
+    "sort"
+    "time"
+)
+
+// Snapshot statuses
+const (
+    SnapshotPending  = "pending"
+    SnapshotComplete = "complete"
+)
+
+// Snapshot records a single parse run of a county's results
+type Snapshot struct {
+    ID         string    `json:"id"`
+    County     string    `json:"county"`
+    ElectionID string    `json:"election_id,omitempty"`
+    Source     string    `json:"source"`
+    Method     string    `json:"method"`
+    Status     string    `json:"status"`
+    RowCount   int       `json:"row_count"`
+    CreatedAt  time.Time `json:"created_at"`
+}
+
+// ChoiceTotal is a choice's standing within a contest
+type ChoiceTotal struct {
+    Name       string  `json:"name"`
+    Votes      int     `json:"votes"`
+    Percentage float64 `json:"percentage"`
+}
+
+// ContestChange describes how a contest differs between two snapshots
+type ContestChange struct {
+    ContestID          string        `json:"contest_id"`
+    ContestName        string        `json:"contest_name"`
+    County             string        `json:"county"`
+    ElectionID         string        `json:"election_id,omitempty"`
+    SnapshotID         string        `json:"snapshot_id"`
+    Choices            []ChoiceTotal `json:"choices"`
+    TotalVotes         int           `json:"total_votes"`
+    PreviousTotalVotes int           `json:"previous_total_votes"`
+    Leader             string        `json:"leader"`
+    PreviousLeader     string        `json:"previous_leader,omitempty"`
+    LeaderChanged      bool          `json:"leader_changed"`
+}
+
+// IngestResult is produced each time a new snapshot is stored
+type IngestResult struct {
+    Snapshot Snapshot        `json:"snapshot"`
+    Changes  []ContestChange `json:"changes"`
+}
+
+// contestTotals summarizes the rows of a single contest
+type contestTotals struct {
+    name    string
+    choices []ChoiceTotal
+    votes   map[string]int
+    total   int
+}
+
+// summarizeContests totals each contest's votes per choice. As in
+// ChoiceTotals, county-wide rows are used when a contest has them and its
+// precinct rows are summed otherwise. Choices are listed once each, in the
+// order first seen, with their share of the contest's total.
+func summarizeContests(rows []ResultRow) (map[string]*contestTotals, []string) {
+    countyWide := make(map[string]bool)
+    for _, row := range rows {
+        if row.Precinct == "" {
+            countyWide[row.ContestID] = true
+        }
+    }
+
+    contests := make(map[string]*contestTotals)
+    choiceOrder := make(map[string][]string)
+    var order []string
+    for _, row := range rows {
+        if countyWide[row.ContestID] && row.Precinct != "" {
+            continue
+        }
+        c, ok := contests[row.ContestID]
+        if !ok {
+            c = &contestTotals{name: row.ContestName, votes: make(map[string]int)}
+            contests[row.ContestID] = c
+            order = append(order, row.ContestID)
+        }
+        if _, ok := c.votes[row.ChoiceName]; !ok {
+            choiceOrder[row.ContestID] = append(choiceOrder[row.ContestID], row.ChoiceName)
+        }
+        c.votes[row.ChoiceName] += row.Votes
+        c.total += row.Votes
+    }
+
+    for contestID, c := range contests {
+        for _, name := range choiceOrder[contestID] {
+            choice := ChoiceTotal{Name: name, Votes: c.votes[name]}
+            if c.total > 0 {
+                choice.Percentage = float64(choice.Votes) * 100 / float64(c.total)
+            }
+            c.choices = append(c.choices, choice)
+        }
+    }
+    return contests, order
+}
+
+// leader returns the choice with the most votes in total, or "" when nobody
+// has votes. Ties go to the choice listed first.
+func (c *contestTotals) leader() string {
+    if c == nil {
+        return ""
+    }
+    sorted := append([]ChoiceTotal(nil), c.choices...)
+    sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Votes > sorted[j].Votes })
+    if len(sorted) == 0 || sorted[0].Votes == 0 {
+        return ""
+    }
+    return sorted[0].Name
+}
+
+// sameVotes reports whether two contests have identical choice totals
+func (c *contestTotals) sameVotes(other *contestTotals) bool {
+    if other == nil || len(c.votes) != len(other.votes) {
+        return false
+    }
+    for name, votes := range c.votes {
+        if prev, ok := other.votes[name]; !ok || prev != votes {
+            return false
+        }
+    }
+    return true
+}
+
+// DiffContests compares the rows of two snapshots and returns a change for
+// every contest in the current snapshot whose totals differ from the previous
+func DiffContests(previous, current []ResultRow, snapshot Snapshot) []ContestChange {
+    prev, _ := summarizeContests(previous)
+    curr, order := summarizeContests(current)
+
+    var changes []ContestChange
+    for _, contestID := range order {
+        c := curr[contestID]
+        p := prev[contestID]
+        if c.sameVotes(p) {
+            continue
+        }
+
+        change := ContestChange{
+            ContestID:   contestID,
+            ContestName: c.name,
+            County:      snapshot.County,
+            ElectionID:  snapshot.ElectionID,
+            SnapshotID:  snapshot.ID,
+            Choices:     c.choices,
+            TotalVotes:  c.total,
+            Leader:      c.leader(),
+        }
+        if p != nil {
+            change.PreviousTotalVotes = p.total
+            change.PreviousLeader = p.leader()
+        }
+        change.LeaderChanged = change.Leader != change.PreviousLeader
+        changes = append(changes, change)
+    }
+    return changes
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+    "fmt"
+    "regexp"
+    "time"
+)
+
+var templateSetNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// TemplateSet is a named theme for the HTML results pages. Templates holds
+// overrides of the default templates keyed by name, and Stylesheet is added
+// after the default styles. Requests from the listed partners use the set
+// unless they ask for another one.
+type TemplateSet struct {
+    ID          string            `json:"id,omitempty"`
+    Name        string            `json:"name"`
+    Description string            `json:"description,omitempty"`
+    Templates   map[string]string `json:"templates,omitempty"`
+    Stylesheet  string            `json:"stylesheet,omitempty"`
+    Partners    []string          `json:"partners,omitempty"`
+    CreatedAt   time.Time         `json:"created_at"`
+    UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// Validate ensures all required fields are present and valid
+func (t *TemplateSet) Validate() error {
+    if !templateSetNamePattern.MatchString(t.Name) {
+        return fmt.Errorf("name must be lowercase letters, digits, '-' or '_'")
+    }
+    for _, partner := range t.Partners {
+        if !templateSetNamePattern.MatchString(partner) {
+            return fmt.Errorf("invalid partner: %q", partner)
+        }
+    }
+    return nil
+}
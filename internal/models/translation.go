@@ -0,0 +1,40 @@
+package models
+
+import (
+    "fmt"
+    "time"
+)
+
+// ContestTranslation is a contest's title, description and choice names in
+// another language, as published by the county. Choices maps the original
+// choice names to their translations; names without one are shown as is.
+type ContestTranslation struct {
+    ContestID   string            `json:"contest_id"`
+    Lang        string            `json:"lang"`
+    Title       string            `json:"title"`
+    Description string            `json:"description,omitempty"`
+    Choices     map[string]string `json:"choices,omitempty"`
+    UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// Validate ensures all required fields are present and valid
+func (t *ContestTranslation) Validate() error {
+    if _, _, err := ParseContestID(t.ContestID); err != nil {
+        return err
+    }
+    if t.Lang == "" {
+        return fmt.Errorf("lang is required")
+    }
+    if t.Title == "" {
+        return fmt.Errorf("title is required")
+    }
+    return nil
+}
+
+// ChoiceName returns the translation of a choice name, or the name itself
+func (t *ContestTranslation) ChoiceName(name string) string {
+    if translated, ok := t.Choices[name]; ok && translated != "" {
+        return translated
+    }
+    return name
+}
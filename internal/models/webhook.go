@@ -0,0 +1,122 @@
+package models
+
+import (
+    "era/internal/urlpolicy"
+    "fmt"
+    "net/url"
+    "time"
+)
+
+// Webhook event types
+const (
+    WebhookEventSnapshot      = "snapshot"
+    WebhookEventContest       = "contest"
+    WebhookEventLeaderChanged = "leader_changed"
+)
+
+// WebhookEventTypes lists every event a webhook can subscribe to
+var WebhookEventTypes = []string{
+    WebhookEventSnapshot,
+    WebhookEventContest,
+    WebhookEventLeaderChanged,
+}
+
+// Webhook is a partner subscription to result changes. Empty filters match
+// everything.
+type Webhook struct {
+    ID         string    `json:"id,omitempty"`
+    URL        string    `json:"url"`
+    Secret     string    `json:"secret,omitempty"`
+    Events     []string  `json:"events"`
+    ElectionID string    `json:"election_id,omitempty"`
+    County     string    `json:"county,omitempty"`
+    ContestID  string    `json:"contest_id,omitempty"`
+    Active     bool      `json:"active"`
+    CreatedAt  time.Time `json:"created_at"`
+}
+
+// Validate ensures all required fields are present and valid
+func (w *Webhook) Validate() error {
+    u, err := url.Parse(w.URL)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return fmt.Errorf("url must be an absolute http or https URL")
+    }
+    // Deliveries are made from inside our network, so they must not reach
+    // internal services
+    if urlpolicy.IsPrivateHost(u.Hostname()) {
+        return fmt.Errorf("url must not point to a private or loopback address")
+    }
+    if w.Secret == "" {
+        return fmt.Errorf("secret is required")
+    }
+    if len(w.Events) == 0 {
+        return fmt.Errorf("at least one event type is required")
+    }
+    for _, event := range w.Events {
+        if !isWebhookEventType(event) {
+            return fmt.Errorf("invalid event type: %s", event)
+        }
+    }
+    if w.ContestID != "" {
+        if _, _, err := ParseContestID(w.ContestID); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Wants reports whether the webhook subscribes to an event type for the
+// given election, county and contest. Snapshot events have no contest.
+func (w *Webhook) Wants(event, electionID, county, contestID string) bool {
+    if !w.Active {
+        return false
+    }
+    subscribed := false
+    for _, e := range w.Events {
+        if e == event {
+            subscribed = true
+            break
+        }
+    }
+    if !subscribed {
+        return false
+    }
+    if w.ElectionID != "" && w.ElectionID != electionID {
+        return false
+    }
+    if w.County != "" && w.County != county {
+        return false
+    }
+    if w.ContestID != "" {
+        if contestID != "" && contestID != w.ContestID {
+            return false
+        }
+        if contestCounty, _, err := ParseContestID(w.ContestID); err == nil && contestCounty != county {
+            return false
+        }
+    }
+    return true
+}
+
+func isWebhookEventType(event string) bool {
+    for _, e := range WebhookEventTypes {
+        if e == event {
+            return true
+        }
+    }
+    return false
+}
+
+// WebhookDelivery logs a single attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+    ID         string    `json:"id,omitempty"`
+    WebhookID  string    `json:"webhook_id"`
+    DeliveryID string    `json:"delivery_id"`
+    Event      string    `json:"event"`
+    Attempt    int       `json:"attempt"`
+    StatusCode int       `json:"status_code,omitempty"`
+    Success    bool      `json:"success"`
+    Error      string    `json:"error,omitempty"`
+    Duration   int64     `json:"duration_ms"`
+    CreatedAt  time.Time `json:"created_at"`
+}
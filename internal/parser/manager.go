@@ -2,63 +2,342 @@ package parser
 
 import (
 	"context"
+	"era/internal/logging"
+	"era/internal/models"
+	"era/internal/storage"
+	"era/internal/urlpolicy"
+	"errors"
 	"fmt"
 	"github.com/pocketbase/pocketbase"
-	"log"
+	"sync"
+	"time"
 )
 
+// IngestRequest describes a single county parse run
+type IngestRequest struct {
+	CountyName string
+	ElectionID string
+	Method     string
+	URL        string
+}
+
+// DownloadOptions configures how parsers download sources
+type DownloadOptions struct {
+	UserAgent string
+	Timeout   time.Duration
+	// TempDir holds downloads while they are parsed; empty uses the system
+	// default
+	TempDir string
+}
+
+// IngestListener is notified every time a new snapshot is stored
+type IngestListener func(result *models.IngestResult)
+
+// StageComplete is the Stage of a parse run that stored its snapshot
+const StageComplete = "complete"
+
+// ParseRun describes a finished parse run, successful or not
+type ParseRun struct {
+	County string
+	Method string
+	// Stage is StageComplete, or the stage the run failed at
+	Stage      string
+	Duration   time.Duration
+	Downloaded int64
+	Rows       int
+}
+
+// ParseListener is notified after every parse run
+type ParseListener func(run ParseRun)
+
+// ParserFactory creates a parser for a single run. Each run gets its own
+// parser, since parsers hold the run's county, snapshot and downloads.
+type ParserFactory func() (Parser, error)
+
+// ErrShuttingDown is returned by Ingest once Shutdown has been called
+var ErrShuttingDown = errors.New("parser manager is shutting down")
+
 // ParserManager manages different types of parsers
 type ParserManager struct {
-	parsers map[string]Parser
+	parsers map[string]ParserFactory
 	pb      *pocketbase.PocketBase
+	store   *storage.PocketBaseStore
+	policy  *urlpolicy.Policy
+
+	// countyLocks serializes parse runs of the same county, so each run
+	// diffs against the snapshot of the run before it. Different counties
+	// parse concurrently.
+	countyMu       sync.Mutex
+	countyLocks    map[string]*sync.Mutex
+	listeners      []IngestListener
+	parseListeners []ParseListener
+
+	// runs tracks parse runs so Shutdown can cancel them through shutdown
+	// and wait for them to discard their snapshots
+	runsMu   sync.Mutex
+	closing  bool
+	runs     sync.WaitGroup
+	shutdown context.Context
+	cancel   context.CancelFunc
 }
 
-// NewParserManager creates a new parser manager
-func NewParserManager(pb *pocketbase.PocketBase) (*ParserManager, error) {
+// NewParserManager creates a new parser manager whose parsers only fetch
+// URLs policy allows
+func NewParserManager(store *storage.PocketBaseStore, policy *urlpolicy.Policy, download DownloadOptions) (*ParserManager, error) {
 	m := &ParserManager{
-		parsers: make(map[string]Parser),
-		pb:      pb,
+		parsers:     make(map[string]ParserFactory),
+		pb:          store.GetPocketBase(),
+		store:       store,
+		policy:      policy,
+		countyLocks: make(map[string]*sync.Mutex),
 	}
+	m.shutdown, m.cancel = context.WithCancel(context.Background())
 
-	// Initialize ZIP parser
-	zipParser, err := NewZIPParser(pb)
+	// Register the ZIP parser, creating one up front so an unusable temp
+	// directory fails at startup rather than on the first parse
+	m.RegisterParser("zip", func() (Parser, error) {
+		return NewZIPParser(m.pb, policy, download)
+	})
+	zipParser, err := m.NewParser("zip")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ZIP parser: %w", err)
 	}
-	m.RegisterParser(zipParser)
+	if err := zipParser.Cleanup(); err != nil {
+		return nil, fmt.Errorf("failed to clean up ZIP parser: %w", err)
+	}
 
 	return m, nil
 }
 
-// RegisterParser adds a new parser to the manager
-func (m *ParserManager) RegisterParser(parser Parser) {
-	m.parsers[parser.Method()] = parser
+// RegisterParser adds a parser method to the manager
+func (m *ParserManager) RegisterParser(method string, factory ParserFactory) {
+	m.parsers[method] = factory
 }
 
-// GetParser retrieves a parser by method
-func (m *ParserManager) GetParser(method string) (Parser, error) {
-	parser, ok := m.parsers[method]
+// NewParser creates a parser for one run by method. Callers must Cleanup
+// the parser once done with it.
+func (m *ParserManager) NewParser(method string) (Parser, error) {
+	factory, ok := m.parsers[method]
 	if !ok {
 		return nil, fmt.Errorf("no parser found for method: %s", method)
 	}
-	return parser, nil
+	return factory()
 }
 
 // ParseURL parses data from a URL using the appropriate parser
 func (m *ParserManager) ParseURL(ctx context.Context, method, url string) error {
-	parser, err := m.GetParser(method)
+	parser, err := m.NewParser(method)
 	if err != nil {
 		return err
 	}
+	defer parser.Cleanup()
 
 	return parser.Parse(ctx, url)
 }
 
-// Cleanup performs any necessary cleanup
-func (m *ParserManager) Cleanup() {
-	for _, p := range m.parsers {
+// CheckURL reports whether the URL policy allows fetching a source, so
+// sources can be refused when they are stored rather than when parsed
+func (m *ParserManager) CheckURL(url string) error {
+	return m.policy.Check(url)
+}
+
+// OnIngest registers a listener called after every stored snapshot
+func (m *ParserManager) OnIngest(listener IngestListener) {
+	m.listeners = append(m.listeners, listener)
+}
+
+// OnParse registers a listener called after every parse run of a known
+// method, whether or not it stored a snapshot
+func (m *ParserManager) OnParse(listener ParseListener) {
+	m.parseListeners = append(m.parseListeners, listener)
+}
+
+// Ingest parses a county's results into a new snapshot. The snapshot only
+// becomes visible to readers once parsing succeeds; on failure its rows are
+// discarded and the previous snapshot stays current.
+func (m *ParserManager) Ingest(ctx context.Context, req IngestRequest) (result *models.IngestResult, err error) {
+	if _, ok := m.parsers[req.Method]; !ok {
+		return nil, fmt.Errorf("no parser found for method: %s", req.Method)
+	}
+
+	if !m.startRun() {
+		return nil, ErrShuttingDown
+	}
+	defer m.runs.Done()
+	// Shutdown cancels the run as well as the caller
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(m.shutdown, cancel)()
+
+	run := ParseRun{County: models.CountySlug(req.CountyName), Method: req.Method}
+	// Everything logged during the run, down to the formatter, carries its
+	// ID and county along with the ID of the request that started it
+	ctx = logging.With(ctx, "parse_id", logging.NewID(), "county", run.County, "method", run.Method)
+	logger := logging.FromContext(ctx)
+
+	start := time.Now()
+	defer func() {
+		run.Duration = time.Since(start)
+		if err != nil {
+			logger.Warn("Parse failed", "stage", run.Stage, "duration_ms", run.Duration.Milliseconds(), "error", err)
+		} else {
+			logger.Info("Stored snapshot", "snapshot_id", result.Snapshot.ID, "rows", run.Rows,
+				"downloaded_bytes", run.Downloaded, "contests_changed", len(result.Changes), "duration_ms", run.Duration.Milliseconds())
+		}
+		for _, listener := range m.parseListeners {
+			listener(run)
+		}
+	}()
+
+	if err := m.policy.Check(req.URL); err != nil {
+		run.Stage = "policy"
+		return nil, NewParseError("policy", err)
+	}
+
+	lock := m.countyLock(run.County)
+	lock.Lock()
+	defer lock.Unlock()
+	// Runs are timed from here, not counting the wait for earlier runs of
+	// the county
+	start = time.Now()
+	logger.Info("Parse started", "url", req.URL)
+
+	result, err = m.ingest(ctx, req, &run)
+	if err != nil {
+		run.Stage = "parse"
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			run.Stage = parseErr.Stage
+		}
+		if m.shutdown.Err() != nil {
+			err = fmt.Errorf("%w: %w", ErrShuttingDown, err)
+		}
+		return nil, err
+	}
+	run.Stage = StageComplete
+	return result, nil
+}
+
+// ingest runs a parse with the county's lock held, recording its download
+// size and rows in run
+func (m *ParserManager) ingest(ctx context.Context, req IngestRequest, run *ParseRun) (*models.IngestResult, error) {
+	county := run.County
+
+	// Runs cancelled while waiting for the county's lock stop here, before
+	// creating a snapshot
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p, err := m.NewParser(req.Method)
+	if err != nil {
+		return nil, NewParseError("setup", err)
+	}
+	defer func() {
 		if err := p.Cleanup(); err != nil {
-			log.Printf("Error cleaning up parser: %v", err)
+			logging.FromContext(ctx).Error("Error cleaning up parser", "error", err)
+		}
+	}()
+
+	// Capture the election's current results so changes can be reported
+	previous, err := m.electionResults(county, req.ElectionID)
+	if err != nil {
+		return nil, NewParseError("snapshot", err)
+	}
+
+	snapshot := models.Snapshot{
+		County:     county,
+		ElectionID: req.ElectionID,
+		Source:     req.URL,
+		Method:     req.Method,
+	}
+	if err := m.store.CreateSnapshot(&snapshot); err != nil {
+		return nil, NewParseError("snapshot", err)
+	}
+
+	p.SetCountyName(req.CountyName)
+	p.SetElectionID(req.ElectionID)
+	p.SetSnapshotID(snapshot.ID)
+
+	err = p.Parse(ctx, req.URL)
+	if downloader, ok := p.(Downloader); ok {
+		run.Downloaded = downloader.Downloaded()
+	}
+	if err != nil {
+		if discardErr := m.store.DiscardSnapshot(&snapshot); discardErr != nil {
+			logging.FromContext(ctx).Error("Error discarding snapshot", "snapshot_id", snapshot.ID, "error", discardErr)
 		}
+		return nil, err
+	}
+
+	if err := m.store.CompleteSnapshot(&snapshot); err != nil {
+		return nil, NewParseError("snapshot", err)
+	}
+	run.Rows = snapshot.RowCount
+
+	current, err := m.electionResults(county, req.ElectionID)
+	if err != nil {
+		return nil, NewParseError("snapshot", err)
+	}
+
+	result := &models.IngestResult{
+		Snapshot: snapshot,
+		Changes:  models.DiffContests(previous, current, snapshot),
 	}
-} 
\ No newline at end of file
+
+	for _, listener := range m.listeners {
+		listener(result)
+	}
+	return result, nil
+}
+
+// electionResults returns a county's visible results for one election. An
+// empty election ID only matches rows stored without one.
+func (m *ParserManager) electionResults(county, electionID string) ([]models.ResultRow, error) {
+	rows, err := m.store.GetResults(models.ResultFilter{County: county, ElectionID: electionID})
+	if err != nil {
+		return nil, err
+	}
+	// The filter ignores an empty election ID, matching every election
+	matching := rows[:0]
+	for _, row := range rows {
+		if row.ElectionID == electionID {
+			matching = append(matching, row)
+		}
+	}
+	return matching, nil
+}
+
+// countyLock returns the lock serializing a county's parse runs
+func (m *ParserManager) countyLock(county string) *sync.Mutex {
+	m.countyMu.Lock()
+	defer m.countyMu.Unlock()
+	lock, ok := m.countyLocks[county]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.countyLocks[county] = lock
+	}
+	return lock
+}
+
+// startRun registers a parse run unless the manager is shutting down
+func (m *ParserManager) startRun() bool {
+	m.runsMu.Lock()
+	defer m.runsMu.Unlock()
+	if m.closing {
+		return false
+	}
+	m.runs.Add(1)
+	return true
+}
+
+// Shutdown refuses new parse runs, cancels running ones and waits for them
+// to discard their snapshots, so the store can be closed after it returns
+func (m *ParserManager) Shutdown() {
+	m.runsMu.Lock()
+	m.closing = true
+	m.runsMu.Unlock()
+
+	m.cancel()
+	m.runs.Wait()
+}
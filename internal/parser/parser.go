@@ -19,6 +19,19 @@ type Parser interface {
     
     // SetCountyName sets the county name for the parser
     SetCountyName(name string)
+
+    // SetElectionID sets the election the parsed results belong to
+    SetElectionID(id string)
+
+    // SetSnapshotID sets the snapshot the parsed results are stored under
+    SetSnapshotID(id string)
+}
+
+// Downloader is implemented by parsers that download their source, so the
+// size of each run's download can be reported
+type Downloader interface {
+    // Downloaded returns the bytes downloaded by the last Parse
+    Downloaded() int64
 }
 
 // ParseError represents a parsing error with a specific stage
@@ -31,6 +44,11 @@ func (e *ParseError) Error() string {
     return fmt.Sprintf("parse error at %s stage: %v", e.Stage, e.Err)
 }
 
+// Unwrap returns the underlying error
+func (e *ParseError) Unwrap() error {
+    return e.Err
+}
+
 // NewParseError creates a new ParseError
 func NewParseError(stage string, err error) *ParseError {
     return &ParseError{
@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"context"
+	"era/internal/logging"
+	"era/internal/models"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// refreshTimeout bounds a single background refresh
+const refreshTimeout = 5 * time.Minute
+
+// Refresher re-parses counties in the background when their stored results
+// are older than a staleness threshold, so readers never wait on a download
+type Refresher struct {
+	manager *ParserManager
+	maxAge  time.Duration
+
+	mu sync.Mutex
+	// inFlight maps the counties being refreshed to when they started
+	inFlight map[string]time.Time
+	wg       sync.WaitGroup
+}
+
+// NewRefresher creates a refresher. A maxAge of zero disables refreshing.
+func NewRefresher(manager *ParserManager, maxAge time.Duration) *Refresher {
+	return &Refresher{
+		manager:  manager,
+		maxAge:   maxAge,
+		inFlight: make(map[string]time.Time),
+	}
+}
+
+// Enabled reports whether a staleness threshold is set
+func (r *Refresher) Enabled() bool {
+	return r != nil && r.maxAge > 0
+}
+
+// IsStale reports whether a county's latest snapshot is past the threshold.
+// A county without any snapshot is always stale.
+func (r *Refresher) IsStale(latest *models.Snapshot) bool {
+	if !r.Enabled() {
+		return false
+	}
+	return latest == nil || time.Since(latest.CreatedAt) > r.maxAge
+}
+
+// RefreshIfStale starts a background parse of the county when its latest
+// snapshot is stale and no refresh for it is already running. It reports
+// whether a refresh was started. The refresh outlives ctx, which only lends
+// it its logger, so its logs carry the ID of the request that started it.
+func (r *Refresher) RefreshIfStale(ctx context.Context, req IngestRequest, latest *models.Snapshot) bool {
+	if !r.IsStale(latest) {
+		return false
+	}
+
+	county := models.CountySlug(req.CountyName)
+	r.mu.Lock()
+	if _, ok := r.inFlight[county]; ok {
+		r.mu.Unlock()
+		return false
+	}
+	r.inFlight[county] = time.Now()
+	r.wg.Add(1)
+	r.mu.Unlock()
+
+	logger := logging.FromContext(ctx)
+
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.inFlight, county)
+			r.mu.Unlock()
+			r.wg.Done()
+		}()
+
+		ctx, cancel := context.WithTimeout(logging.NewContext(context.Background(), logger), refreshTimeout)
+		defer cancel()
+
+		// The run logs its own outcome
+		logger.Info("Refreshing stale results", "county", county)
+		r.manager.Ingest(ctx, req)
+	}()
+	return true
+}
+
+// InFlight returns the number of refreshes running
+func (r *Refresher) InFlight() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.inFlight)
+}
+
+// Check returns an error when a refresh has run well past refreshTimeout,
+// meaning it is stuck rather than slow
+func (r *Refresher) Check() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for county, started := range r.inFlight {
+		if running := time.Since(started); running > refreshTimeout+time.Minute {
+			return fmt.Errorf("refresh of %s has been running for %s", county, running.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// Wait blocks until every running refresh has finished
+func (r *Refresher) Wait() {
+	r.wg.Wait()
+}
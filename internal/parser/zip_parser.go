@@ -6,19 +6,19 @@ import (
     "encoding/csv"
     "fmt"
     "io"
-    "log"
     "net/http"
     "os"
     "path/filepath"
     "strconv"
     "strings"
-    "time"
     
     "github.com/pocketbase/pocketbase"
     "github.com/pocketbase/pocketbase/models/schema"
     pbModels "github.com/pocketbase/pocketbase/models"
     "era/internal/formatter"
+    "era/internal/logging"
     "era/internal/models"
+    "era/internal/urlpolicy"
 )
 
 // ZIPParser implements Parser interface for ZIP files containing CSV data
@@ -26,11 +26,17 @@ type ZIPParser struct {
     tempDir    string
     pb         *pocketbase.PocketBase
     countyName string
+    electionID string
+    snapshotID string
+    policy     *urlpolicy.Policy
+    download   DownloadOptions
+    downloaded int64
 }
 
-// NewZIPParser creates a new ZIP parser instance
-func NewZIPParser(pb *pocketbase.PocketBase) (*ZIPParser, error) {
-    tempDir, err := os.MkdirTemp("", "election_data_*")
+// NewZIPParser creates a new ZIP parser instance that downloads within the
+// limits of policy
+func NewZIPParser(pb *pocketbase.PocketBase, policy *urlpolicy.Policy, download DownloadOptions) (*ZIPParser, error) {
+    tempDir, err := os.MkdirTemp(download.TempDir, "election_data_*")
     if err != nil {
         return nil, fmt.Errorf("failed to create temp directory: %w", err)
     }
@@ -38,6 +44,8 @@ func NewZIPParser(pb *pocketbase.PocketBase) (*ZIPParser, error) {
     return &ZIPParser{
         tempDir: tempDir,
         pb:      pb,
+        policy:   policy,
+        download: download,
     }, nil
 }
 
@@ -48,63 +56,64 @@ func (p *ZIPParser) Method() string {
 
 // Parse implements the Parser interface
 func (p *ZIPParser) Parse(ctx context.Context, url string) error {
-	log.Printf("Starting to parse URL: %s", url)
+	logger := logging.FromContext(ctx)
+	p.downloaded = 0
 	
 	// Download ZIP file
-	log.Printf("Downloading ZIP file...")
 	zipPath, err := p.downloadZIP(ctx, url)
 	if err != nil {
-		log.Printf("Error downloading ZIP: %v", err)
 		return NewParseError("download", err)
 	}
 	defer os.Remove(zipPath)
-	log.Printf("Successfully downloaded ZIP to: %s", zipPath)
+	logger.Debug("Downloaded ZIP", "path", zipPath, "bytes", p.downloaded)
 	
 	// Extract and process CSV files
-	log.Printf("Processing ZIP file...")
 	if err := p.processZIPFile(ctx, zipPath); err != nil {
-		log.Printf("Error processing ZIP: %v", err)
 		return NewParseError("process", err)
 	}
 	
-	log.Printf("Successfully completed parsing")
 	return nil
 }
 
 // downloadZIP downloads a ZIP file from the given URL
 func (p *ZIPParser) downloadZIP(ctx context.Context, url string) (string, error) {
-	log.Printf("Creating HTTP request for URL: %s", url)
+	if err := p.policy.Check(url); err != nil {
+		return "", err
+	}
+
+	logger := logging.FromContext(ctx)
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
  
 	// Add headers
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	req.Header.Set("User-Agent", p.download.UserAgent)
 	req.Header.Set("Accept", "*/*")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
 	req.Header.Set("Connection", "keep-alive")
-	log.Printf("Added browser-like headers to request")
 	
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
+	// The policy's client refuses internal addresses and too many redirects
+	client := p.policy.Client(p.download.Timeout)
 	
-	log.Printf("Sending HTTP request...")
+	logger.Debug("Downloading ZIP", "url", url)
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to download file: %w", err)
 	}
 	defer resp.Body.Close()
 	
-	log.Printf("Received response with status code: %d", resp.StatusCode)
+	logger.Debug("Received download response", "status", resp.StatusCode, "content_length", resp.ContentLength)
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
+	if p.policy.MaxDownloadSize > 0 && resp.ContentLength > p.policy.MaxDownloadSize {
+		return "", fmt.Errorf("%w: download of %d bytes is larger than %s",
+			urlpolicy.ErrTooLarge, resp.ContentLength, urlpolicy.FormatSize(p.policy.MaxDownloadSize))
+	}
 	
 	// Create temporary file
 	zipPath := filepath.Join(p.tempDir, "download.zip")
-	log.Printf("Creating temporary file at: %s", zipPath)
 	f, err := os.Create(zipPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to create temp file: %w", err)
@@ -112,56 +121,69 @@ func (p *ZIPParser) downloadZIP(ctx context.Context, url string) (string, error)
 	defer f.Close()
 	
 	// Copy data
-	log.Printf("Copying response data to file...")
-	written, err := io.Copy(f, resp.Body)
+	written, err := io.Copy(f, urlpolicy.LimitReader(resp.Body, p.policy.MaxDownloadSize, "download"))
+	p.downloaded = written
 	if err != nil {
 		os.Remove(zipPath)
 		return "", fmt.Errorf("failed to save file: %w", err)
 	}
-	log.Printf("Successfully wrote %d bytes to file", written)
 	
 	return zipPath, nil
 }
 
+// Downloaded returns the bytes downloaded by the last Parse
+func (p *ZIPParser) Downloaded() int64 {
+	return p.downloaded
+}
+
 // processZIPFile extracts and processes CSV files from the ZIP
 func (p *ZIPParser) processZIPFile(ctx context.Context, zipPath string) error {
-	log.Printf("Opening ZIP file: %s", zipPath)
+	logger := logging.FromContext(ctx)
 	r, err := zip.OpenReader(zipPath)
 	if err != nil {
 		return fmt.Errorf("failed to open ZIP: %w", err)
 	}
 	defer r.Close()
 	
-	log.Printf("Found %d files in ZIP archive", len(r.File))
+	logger.Debug("Opened ZIP", "files", len(r.File))
+
+	// Refuse zip bombs up front. archive/zip fails reading any file past its
+	// declared size, so the declared sizes bound what is decompressed.
+	var total uint64
+	for _, f := range r.File {
+		total += f.UncompressedSize64
+	}
+	if limit := p.policy.MaxDecompressedSize; limit > 0 && total > uint64(limit) {
+		return fmt.Errorf("%w: archive decompresses to %d bytes, more than %s",
+			urlpolicy.ErrTooLarge, total, urlpolicy.FormatSize(limit))
+	}
 	
 	// Process each file
 	for _, f := range r.File {
 		select {
 		case <-ctx.Done():
-			log.Printf("Context cancelled, stopping processing")
 			return ctx.Err()
 		default:
-			log.Printf("Processing file: %s", f.Name)
 			if err := p.processZIPEntry(ctx, f); err != nil {
-				log.Printf("Error processing file %s: %v", f.Name, err)
 				return fmt.Errorf("failed to process %s: %w", f.Name, err)
 			}
 		}
 	}
 	
-	log.Printf("Finished processing all files in ZIP")
 	return nil
 }
 
 // processZIPEntry handles a single file from the ZIP archive
 func (p *ZIPParser) processZIPEntry(ctx context.Context, f *zip.File) error {
+	logger := logging.FromContext(ctx).With("file", f.Name)
+
 	// Skip if not CSV
 	if !strings.HasSuffix(strings.ToLower(f.Name), ".csv") {
-		log.Printf("Skipping non-CSV file: %s", f.Name)
+		logger.Debug("Skipping non-CSV file")
 		return nil
 	}
 	
-	log.Printf("Processing CSV file: %s for county: %s", f.Name, p.countyName)
+	logger.Info("Processing CSV file")
 	
 	// Create formatter
 	resultsFormatter := formatter.New(p.pb)
@@ -177,12 +199,11 @@ func (p *ZIPParser) processZIPEntry(ctx context.Context, f *zip.File) error {
 	reader := csv.NewReader(rc)
 	
 	// Read headers
-	log.Printf("Reading CSV headers...")
 	headers, err := reader.Read()
 	if err != nil {
 		return fmt.Errorf("failed to read CSV headers: %w", err)
 	}
-	log.Printf("Found %d columns: %v", len(headers), headers)
+	logger.Debug("Read CSV headers", "columns", headers)
 	
 	// Create a map for easier column access
 	rowData := make(map[string]interface{})
@@ -193,6 +214,10 @@ func (p *ZIPParser) processZIPEntry(ctx context.Context, f *zip.File) error {
 		headerMap[strings.ToLower(header)] = i
 	}
  
+	// Per-row lines are sampled, along with the formatter's for each row
+	rowCtx := logging.PerRow(logging.NewContext(ctx, logger))
+	rowLogger := logging.FromContext(rowCtx)
+
 	// Process rows
 	rowCount := 0
 	for {
@@ -202,7 +227,7 @@ func (p *ZIPParser) processZIPEntry(ctx context.Context, f *zip.File) error {
 		default:
 			row, err := reader.Read()
 			if err == io.EOF {
-				log.Printf("Finished reading CSV, processed %d rows", rowCount)
+				logger.Info("Processed CSV file", "rows", rowCount)
 				return nil
 			}
 			if err != nil {
@@ -225,6 +250,22 @@ func (p *ZIPParser) processZIPEntry(ctx context.Context, f *zip.File) error {
 				percent = row[idx]
 			}
 
+			// Optional reporting columns
+			party := columnValue(headerMap, row, "party name", "party")
+			precinct := columnValue(headerMap, row, "precinct name", "precinct")
+			precinctsTotal := columnValue(headerMap, row, "num precinct total")
+			precinctsReporting := columnValue(headerMap, row, "num precinct rptg")
+			// Measures may carry their ballot question, searched along
+			// with contest and choice names
+			description := columnValue(headerMap, row, "contest description", "description", "measure text", "ballot question")
+
+			// Rows are listed in ballot order; prefer the export's own line
+			// numbers when present
+			ballotOrder := parseVotes(columnValue(headerMap, row, "line number"))
+			if ballotOrder == 0 {
+				ballotOrder = rowCount + 1
+			}
+
 			// Store all row data for raw access
 			for i, header := range headers {
 				if i < len(row) {
@@ -235,26 +276,31 @@ func (p *ZIPParser) processZIPEntry(ctx context.Context, f *zip.File) error {
 			// Create election entry with safe values
 			entry := &models.ElectionEntry{
 				CountyID:    p.countyName,
+				ElectionID:  p.electionID,
+				SnapshotID:  p.snapshotID,
 				Title:       contestName,
 				ChoiceName:  choiceName,
 				Votes:       parseVotes(totalVotes),
 				Percentage:  parsePercentage(percent),
+				Party:       party,
+				Description: description,
+				Precinct:    precinct,
+				PrecinctsTotal:     parseVotes(precinctsTotal),
+				PrecinctsReporting: parseVotes(precinctsReporting),
+				BallotOrder: ballotOrder,
 				RawData:     rowData,
 			}
 
-			log.Printf("Processing entry - Title: %s, Choice: %s, Votes: %d, Percentage: %.2f",
-				entry.Title, entry.ChoiceName, entry.Votes, entry.Percentage)
+			rowLogger.Debug("Processing entry", "contest", entry.Title, "choice", entry.ChoiceName,
+				"votes", entry.Votes, "percentage", entry.Percentage)
 
 			// Process entry through formatter
-			if err := resultsFormatter.ProcessEntry(ctx, entry); err != nil {
-				log.Printf("Warning: failed to process row %d: %v", rowCount, err)
+			if err := resultsFormatter.ProcessEntry(rowCtx, entry); err != nil {
+				logger.Warn("Failed to process row", "row", rowCount, "error", err)
 				continue
 			}
 
 			rowCount++
-			if rowCount%1000 == 0 {
-				log.Printf("Processed %d rows...", rowCount)
-			}
 		}
 	}
 }
@@ -278,6 +324,16 @@ func parsePercentage(s string) float64 {
 	return percentage
 }
 
+// columnValue returns the value of the first matching header present in the row
+func columnValue(headerMap map[string]int, row []string, names ...string) string {
+	for _, name := range names {
+		if idx, ok := headerMap[name]; ok && idx < len(row) {
+			return strings.TrimSpace(row[idx])
+		}
+	}
+	return ""
+}
+
 func makeRawData(headers, row []string) map[string]interface{} {
 	rawData := make(map[string]interface{})
 	for i, header := range headers {
@@ -295,12 +351,10 @@ func (p *ZIPParser) createCollectionFromHeaders(ctx context.Context, filename st
 	}
 	
 	collectionName := fmt.Sprintf("county_%s_results", p.countyName)
-	log.Printf("Creating collection: %s", collectionName)
 	
 	// Check if collection already exists
 	collection, err := p.pb.Dao().FindCollectionByNameOrId(collectionName)
 	if err == nil {
-		log.Printf("Collection %s already exists", collectionName)
 		return nil
 	}
 	
@@ -327,7 +381,7 @@ func (p *ZIPParser) createCollectionFromHeaders(ctx context.Context, filename st
 		return fmt.Errorf("failed to create collection: %w", err)
 	}
 	
-	log.Printf("Successfully created collection %s with %d fields", collectionName, len(headers))
+	logging.FromContext(ctx).Info("Created collection", "collection", collectionName, "fields", len(headers))
 	return nil
 }
 
@@ -370,5 +424,15 @@ func (p *ZIPParser) Cleanup() error {
 
 // SetCountyName sets the county name for the ZIPParser
 func (p *ZIPParser) SetCountyName(name string) {
-	p.countyName = strings.ToLower(strings.ReplaceAll(name, " ", "_"))
+	p.countyName = models.CountySlug(name)
+}
+
+// SetElectionID sets the election ID stored with each parsed result
+func (p *ZIPParser) SetElectionID(id string) {
+	p.electionID = id
+}
+
+// SetSnapshotID sets the snapshot ID stored with each parsed result
+func (p *ZIPParser) SetSnapshotID(id string) {
+	p.snapshotID = id
 } 
\ No newline at end of file
@@ -0,0 +1,347 @@
+// Package ratelimit limits how often each client may call the API. Every
+// API key, or IP address for requests without a key, gets a token bucket
+// per class of route, so heavy result reads can't starve parsing or exports
+// and parse requests can't be used to make the server fetch URLs at will.
+package ratelimit
+
+import (
+	"era/internal/api"
+	"era/internal/auth"
+	"era/internal/models"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Route classes, each with its own budget
+const (
+	ClassRead   = "read"   // results, pages and every other route
+	ClassParse  = "parse"  // routes that fetch and parse result sources
+	ClassExport = "export" // exports and the AP feed
+	// ClassAuth counts failed authentications per IP address, checked
+	// before credentials are looked up
+	ClassAuth = "auth"
+)
+
+// Classes lists every route class
+var Classes = []string{ClassRead, ClassParse, ClassExport, ClassAuth}
+
+const (
+	// sweepInterval is how often idle clients are looked for
+	sweepInterval = time.Minute
+
+	// idleTimeout drops the bucket and counters of a client idle this long
+	idleTimeout = 10 * time.Minute
+)
+
+// ClassOf returns the class of a route requiring permission, "" for public
+// routes
+func ClassOf(permission string) string {
+	switch permission {
+	case models.PermissionParse:
+		return ClassParse
+	case models.PermissionExport:
+		return ClassExport
+	default:
+		return ClassRead
+	}
+}
+
+// Budget allows Requests per Per, in bursts of up to Requests. A zero
+// budget doesn't limit the class.
+type Budget struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseBudget parses a budget such as "600/1m". Only "0" and "off" disable
+// limiting; a zero count such as "0/1m" is refused rather than read as off.
+func ParseBudget(value string) (Budget, error) {
+	value = strings.TrimSpace(value)
+	if value == "0" || value == "off" {
+		return Budget{}, nil
+	}
+	requests, per, ok := strings.Cut(value, "/")
+	if !ok {
+		return Budget{}, fmt.Errorf("budget %q must look like 600/1m", value)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return Budget{}, fmt.Errorf("budget %q must allow at least one request; use off to disable limiting", value)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Budget{}, fmt.Errorf("budget %q has an invalid period", value)
+	}
+	return Budget{Requests: n, Per: d}, nil
+}
+
+func (b Budget) String() string {
+	if b.Requests == 0 {
+		return "off"
+	}
+	per := b.Per.String()
+	// Print 1m0s as 1m and 1h0m0s as 1h, as budgets are written
+	if strings.HasSuffix(per, "m0s") {
+		per = strings.TrimSuffix(per, "0s")
+	}
+	if strings.HasSuffix(per, "h0m") {
+		per = strings.TrimSuffix(per, "0m")
+	}
+	return fmt.Sprintf("%d/%s", b.Requests, per)
+}
+
+// MarshalText writes the budget as ParseBudget reads it
+func (b Budget) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText reads a budget written as ParseBudget reads it
+func (b *Budget) UnmarshalText(text []byte) error {
+	budget, err := ParseBudget(string(text))
+	if err != nil {
+		return err
+	}
+	*b = budget
+	return nil
+}
+
+// rate is the tokens the budget refills per second
+func (b Budget) rate() float64 {
+	return float64(b.Requests) / b.Per.Seconds()
+}
+
+// DefaultBudgets are the budgets used for classes without one configured
+var DefaultBudgets = map[string]Budget{
+	ClassRead:   {Requests: 600, Per: time.Minute},
+	ClassParse:  {Requests: 10, Per: time.Minute},
+	ClassExport: {Requests: 60, Per: time.Minute},
+	ClassAuth:   {Requests: 20, Per: time.Minute},
+}
+
+// Usage counts one client's requests in one class
+type Usage struct {
+	Client    string    `json:"client"`
+	Class     string    `json:"class"`
+	Budget    string    `json:"budget"`
+	Remaining int       `json:"remaining"`
+	Allowed   uint64    `json:"allowed"`
+	Limited   uint64    `json:"limited"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+type bucket struct {
+	tokens   float64
+	updated  time.Time
+	allowed  uint64
+	limited  uint64
+	lastSeen time.Time
+}
+
+type bucketKey struct {
+	client string
+	class  string
+}
+
+// Limiter enforces the budgets of each client
+type Limiter struct {
+	budgets    map[string]Budget
+	trustProxy bool
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+// New creates a limiter. Classes missing from budgets use DefaultBudgets.
+// trustProxy identifies anonymous clients by the address a reverse proxy
+// appended to X-Forwarded-For instead of the connection's address.
+func New(budgets map[string]Budget, trustProxy bool) *Limiter {
+	merged := make(map[string]Budget, len(Classes))
+	for _, class := range Classes {
+		merged[class] = DefaultBudgets[class]
+		if budget, ok := budgets[class]; ok {
+			merged[class] = budget
+		}
+	}
+	return &Limiter{
+		budgets:    merged,
+		trustProxy: trustProxy,
+		buckets:    make(map[bucketKey]*bucket),
+		lastSweep:  time.Now(),
+	}
+}
+
+// Budgets returns the budget of each class
+func (l *Limiter) Budgets() map[string]Budget {
+	return l.budgets
+}
+
+// Limit serves next within the budget of the class of routes requiring
+// permission. Requests over budget get a 429 with a Retry-After header.
+// Authenticated requests are counted against their API key, others
+// against their IP address.
+func (l *Limiter) Limit(permission string, next http.HandlerFunc) http.HandlerFunc {
+	class := ClassOf(permission)
+	return func(w http.ResponseWriter, r *http.Request) {
+		budget := l.budgets[class]
+		if budget.Requests == 0 || r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+
+		remaining, wait := l.take(l.client(r), class, budget, time.Now())
+		w.Header().Set("X-RateLimit-Limit", budget.String())
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			api.HTTPError(w, fmt.Sprintf("Rate limit of %s %s requests exceeded", budget, class), http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Blocked reports how long until the IP address of r may try to
+// authenticate again, or 0 while it is within the auth budget. Checking
+// spends nothing; only Failed does.
+func (l *Limiter) Blocked(r *http.Request) time.Duration {
+	budget := l.budgets[ClassAuth]
+	if budget.Requests == 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[bucketKey{client: "ip:" + l.clientIP(r), class: ClassAuth}]
+	if !ok {
+		return 0
+	}
+	tokens := math.Min(float64(budget.Requests), b.tokens+time.Since(b.updated).Seconds()*budget.rate())
+	if tokens >= 1 {
+		return 0
+	}
+	b.limited++
+	return time.Duration((1 - tokens) / budget.rate() * float64(time.Second))
+}
+
+// Failed charges a failed authentication to the IP address of r
+func (l *Limiter) Failed(r *http.Request) {
+	budget := l.budgets[ClassAuth]
+	if budget.Requests == 0 {
+		return
+	}
+	l.take("ip:"+l.clientIP(r), ClassAuth, budget, time.Now())
+}
+
+// take spends a token from a client's bucket. It returns the whole tokens
+// left, or how long until a token is available when there are none.
+func (l *Limiter) take(client, class string, budget Budget, now time.Time) (int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	key := bucketKey{client: client, class: class}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(budget.Requests), updated: now}
+		l.buckets[key] = b
+	}
+
+	// Refill for the time since the bucket was last used
+	rate := budget.rate()
+	b.tokens = math.Min(float64(budget.Requests), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		b.limited++
+		return 0, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	b.tokens--
+	b.allowed++
+	return int(b.tokens), 0
+}
+
+// sweep drops idle clients so a flood of addresses can't grow the limiter
+// without bound. It must be called with mu held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Usage returns the counters of every client seen recently, busiest first
+func (l *Limiter) Usage() []Usage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	usage := make([]Usage, 0, len(l.buckets))
+	for key, b := range l.buckets {
+		budget := l.budgets[key.class]
+		tokens := math.Min(float64(budget.Requests), b.tokens+now.Sub(b.updated).Seconds()*budget.rate())
+		usage = append(usage, Usage{
+			Client:    key.client,
+			Class:     key.class,
+			Budget:    budget.String(),
+			Remaining: int(tokens),
+			Allowed:   b.allowed,
+			Limited:   b.limited,
+			LastSeen:  b.lastSeen,
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Allowed+usage[i].Limited != usage[j].Allowed+usage[j].Limited {
+			return usage[i].Allowed+usage[i].Limited > usage[j].Allowed+usage[j].Limited
+		}
+		if usage[i].Client != usage[j].Client {
+			return usage[i].Client < usage[j].Client
+		}
+		return usage[i].Class < usage[j].Class
+	})
+	return usage
+}
+
+// client names who a request is counted against
+func (l *Limiter) client(r *http.Request) string {
+	if p := auth.FromContext(r.Context()); p != nil {
+		if p.KeyID != "" {
+			return "key:" + p.KeyID
+		}
+		return "admin:" + p.Name
+	}
+	return "ip:" + l.clientIP(r)
+}
+
+func (l *Limiter) clientIP(r *http.Request) string {
+	if l.trustProxy {
+		// The proxy appends the address it saw; earlier entries come from
+		// the client and can't be trusted
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			addresses := strings.Split(forwarded, ",")
+			if ip := strings.TrimSpace(addresses[len(addresses)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
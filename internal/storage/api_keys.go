@@ -0,0 +1,209 @@
+package storage
+
+import (
+    "era/internal/models"
+    "fmt"
+    "time"
+
+    "github.com/pocketbase/dbx"
+    "github.com/pocketbase/pocketbase"
+    pbModels "github.com/pocketbase/pocketbase/models"
+    "github.com/pocketbase/pocketbase/models/schema"
+    "github.com/pocketbase/pocketbase/tools/types"
+)
+
+const apiKeysCollection = "api_keys"
+
+func ensureAPIKeysCollection(app *pocketbase.PocketBase) error {
+    if collection, err := app.Dao().FindCollectionByNameOrId(apiKeysCollection); err == nil {
+        return migrateAPIKeysCollection(app, collection)
+    }
+
+    collection := &pbModels.Collection{
+        Name: apiKeysCollection,
+        Type: pbModels.CollectionTypeBase,
+        Schema: schema.NewSchema(
+            &schema.SchemaField{Name: "name", Type: schema.FieldTypeText, Required: true},
+            &schema.SchemaField{Name: "key_hash", Type: schema.FieldTypeText, Required: true},
+            &schema.SchemaField{Name: "prefix", Type: schema.FieldTypeText},
+            &schema.SchemaField{
+                Name: "scopes",
+                Type: schema.FieldTypeSelect,
+                Options: &schema.SelectOptions{
+                    MaxSelect: len(models.APIKeyScopes),
+                    Values:    models.APIKeyScopes,
+                },
+            },
+            &schema.SchemaField{Name: "last_used", Type: schema.FieldTypeDate},
+        ),
+        Indexes: types.JsonArray[string]{
+            "CREATE UNIQUE INDEX idx_api_keys_key_hash ON api_keys (key_hash)",
+        },
+    }
+    for _, field := range apiKeyRoleFields() {
+        collection.Schema.AddField(field)
+    }
+    if err := app.Dao().SaveCollection(collection); err != nil {
+        return fmt.Errorf("failed to save collection: %w", err)
+    }
+    return nil
+}
+
+// apiKeyRoleFields are the fields added when keys gained roles
+func apiKeyRoleFields() []*schema.SchemaField {
+    return []*schema.SchemaField{
+        {
+            Name: "role",
+            Type: schema.FieldTypeSelect,
+            Options: &schema.SelectOptions{
+                MaxSelect: 1,
+                Values:    models.Roles,
+            },
+        },
+        {Name: "elections", Type: schema.FieldTypeJson, Options: &schema.JsonOptions{MaxSize: 65536}},
+    }
+}
+
+// migrateAPIKeysCollection adds the role fields to a collection created
+// before keys had roles. Scopes are no longer required, since a key has
+// either a role or scopes.
+func migrateAPIKeysCollection(app *pocketbase.PocketBase, collection *pbModels.Collection) error {
+    changed := false
+    if scopes := collection.Schema.GetFieldByName("scopes"); scopes != nil && scopes.Required {
+        scopes.Required = false
+        changed = true
+    }
+    for _, field := range apiKeyRoleFields() {
+        if collection.Schema.GetFieldByName(field.Name) == nil {
+            collection.Schema.AddField(field)
+            changed = true
+        }
+    }
+    if !changed {
+        return nil
+    }
+
+    if err := app.Dao().SaveCollection(collection); err != nil {
+        return fmt.Errorf("failed to update collection: %w", err)
+    }
+    return nil
+}
+
+// SaveAPIKey stores a new API key by the hash of its secret
+func (s *PocketBaseStore) SaveAPIKey(key *models.APIKey, keyHash string) error {
+    collection, err := s.app.Dao().FindCollectionByNameOrId(apiKeysCollection)
+    if err != nil {
+        return fmt.Errorf("failed to find collection: %w", err)
+    }
+
+    record := pbModels.NewRecord(collection)
+    record.Set("name", key.Name)
+    record.Set("key_hash", keyHash)
+    record.Set("prefix", key.Prefix)
+    record.Set("role", key.Role)
+    record.Set("scopes", key.Scopes)
+    record.Set("elections", key.Elections)
+
+    if err := s.app.Dao().SaveRecord(record); err != nil {
+        return fmt.Errorf("failed to save API key: %w", err)
+    }
+    key.ID = record.Id
+    key.CreatedAt = record.GetDateTime("created").Time()
+    return nil
+}
+
+// GetAPIKey retrieves an API key by ID
+func (s *PocketBaseStore) GetAPIKey(id string) (*models.APIKey, error) {
+    record, err := s.app.Dao().FindRecordById(apiKeysCollection, id)
+    if err != nil {
+        return nil, fmt.Errorf("failed to find API key: %w", err)
+    }
+
+    key := recordToAPIKey(record)
+    return &key, nil
+}
+
+// GetAPIKeys retrieves every API key, oldest first
+func (s *PocketBaseStore) GetAPIKeys() ([]models.APIKey, error) {
+    records, err := s.app.Dao().FindRecordsByFilter(apiKeysCollection, "id != ''", "created", 0, 0)
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch API keys: %w", err)
+    }
+
+    keys := make([]models.APIKey, 0, len(records))
+    for _, record := range records {
+        keys = append(keys, recordToAPIKey(record))
+    }
+    return keys, nil
+}
+
+// FindAPIKeyByHash retrieves the API key whose secret has the given hash
+func (s *PocketBaseStore) FindAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+    record, err := s.app.Dao().FindFirstRecordByFilter(
+        apiKeysCollection,
+        "key_hash = {:hash}",
+        dbx.Params{"hash": keyHash},
+    )
+    if err != nil {
+        return nil, fmt.Errorf("failed to find API key: %w", err)
+    }
+
+    key := recordToAPIKey(record)
+    return &key, nil
+}
+
+// TouchAPIKey records when an API key was last used
+func (s *PocketBaseStore) TouchAPIKey(id string, usedAt time.Time) error {
+    record, err := s.app.Dao().FindRecordById(apiKeysCollection, id)
+    if err != nil {
+        return fmt.Errorf("failed to find API key: %w", err)
+    }
+
+    record.Set("last_used", usedAt)
+    if err := s.app.Dao().SaveRecord(record); err != nil {
+        return fmt.Errorf("failed to update API key: %w", err)
+    }
+    return nil
+}
+
+// DeleteAPIKey revokes an API key
+func (s *PocketBaseStore) DeleteAPIKey(id string) error {
+    record, err := s.app.Dao().FindRecordById(apiKeysCollection, id)
+    if err != nil {
+        return fmt.Errorf("failed to find API key: %w", err)
+    }
+
+    if err := s.app.Dao().DeleteRecord(record); err != nil {
+        return fmt.Errorf("failed to delete API key: %w", err)
+    }
+    return nil
+}
+
+// FindAdminByToken returns the email of the PocketBase admin an admin auth
+// token was issued to
+func (s *PocketBaseStore) FindAdminByToken(token string) (string, error) {
+    admin, err := s.app.Dao().FindAdminByToken(token, s.app.Settings().AdminAuthToken.Secret)
+    if err != nil {
+        return "", fmt.Errorf("failed to find admin: %w", err)
+    }
+    return admin.Email, nil
+}
+
+func recordToAPIKey(record *pbModels.Record) models.APIKey {
+    key := models.APIKey{
+        ID:        record.Id,
+        Name:      record.GetString("name"),
+        Role:      record.GetString("role"),
+        Scopes:    record.GetStringSlice("scopes"),
+        Prefix:    record.GetString("prefix"),
+        CreatedAt: record.GetDateTime("created").Time(),
+    }
+    if err := record.UnmarshalJSONField("elections", &key.Elections); err != nil {
+        key.Elections = nil
+    }
+    if lastUsed := record.GetDateTime("last_used"); !lastUsed.IsZero() {
+        t := lastUsed.Time()
+        key.LastUsedAt = &t
+    }
+    return key
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+    "era/internal/models"
+    "fmt"
+
+    "github.com/pocketbase/pocketbase"
+    pbModels "github.com/pocketbase/pocketbase/models"
+    "github.com/pocketbase/pocketbase/models/schema"
+    "github.com/pocketbase/pocketbase/tools/types"
+)
+
+const corsOriginsCollection = "cors_origins"
+
+func ensureCORSOriginsCollection(app *pocketbase.PocketBase) error {
+    if _, err := app.Dao().FindCollectionByNameOrId(corsOriginsCollection); err == nil {
+        return nil
+    }
+
+    collection := &pbModels.Collection{
+        Name: corsOriginsCollection,
+        Type: pbModels.CollectionTypeBase,
+        Schema: schema.NewSchema(
+            &schema.SchemaField{Name: "origin", Type: schema.FieldTypeText, Required: true},
+            &schema.SchemaField{Name: "description", Type: schema.FieldTypeText},
+        ),
+        Indexes: types.JsonArray[string]{
+            "CREATE UNIQUE INDEX idx_cors_origins_origin ON cors_origins (origin)",
+        },
+    }
+    if err := app.Dao().SaveCollection(collection); err != nil {
+        return fmt.Errorf("failed to save collection: %w", err)
+    }
+    return nil
+}
+
+// SaveCORSOrigin stores an allowed origin
+func (s *PocketBaseStore) SaveCORSOrigin(origin *models.CORSOrigin) error {
+    collection, err := s.app.Dao().FindCollectionByNameOrId(corsOriginsCollection)
+    if err != nil {
+        return fmt.Errorf("failed to find collection: %w", err)
+    }
+
+    record := pbModels.NewRecord(collection)
+    record.Set("origin", origin.Origin)
+    record.Set("description", origin.Description)
+
+    if err := s.app.Dao().SaveRecord(record); err != nil {
+        return fmt.Errorf("failed to save CORS origin: %w", err)
+    }
+    *origin = recordToCORSOrigin(record)
+    return nil
+}
+
+// GetCORSOrigins retrieves every stored allowed origin, oldest first
+func (s *PocketBaseStore) GetCORSOrigins() ([]models.CORSOrigin, error) {
+    records, err := s.app.Dao().FindRecordsByFilter(corsOriginsCollection, "id != ''", "created", 0, 0)
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch CORS origins: %w", err)
+    }
+
+    origins := make([]models.CORSOrigin, 0, len(records))
+    for _, record := range records {
+        origins = append(origins, recordToCORSOrigin(record))
+    }
+    return origins, nil
+}
+
+// DeleteCORSOrigin removes a stored allowed origin
+func (s *PocketBaseStore) DeleteCORSOrigin(id string) error {
+    record, err := s.app.Dao().FindRecordById(corsOriginsCollection, id)
+    if err != nil {
+        return fmt.Errorf("failed to find CORS origin: %w", err)
+    }
+
+    if err := s.app.Dao().DeleteRecord(record); err != nil {
+        return fmt.Errorf("failed to delete CORS origin: %w", err)
+    }
+    return nil
+}
+
+func recordToCORSOrigin(record *pbModels.Record) models.CORSOrigin {
+    return models.CORSOrigin{
+        ID:          record.Id,
+        Origin:      record.GetString("origin"),
+        Description: record.GetString("description"),
+        CreatedAt:   record.GetDateTime("created").Time(),
+    }
+}
@@ -1,48 +1,93 @@
 package storage
 
 import (
+    "context"
     "era/internal/models"
+    "errors"
     "fmt"
     "github.com/pocketbase/pocketbase"
+    "github.com/pocketbase/pocketbase/apis"
+    "github.com/pocketbase/pocketbase/core"
+    "github.com/pocketbase/pocketbase/migrations"
+    "github.com/pocketbase/pocketbase/migrations/logs"
     "github.com/pocketbase/pocketbase/models/schema"
     pbModels "github.com/pocketbase/pocketbase/models"
-    "log"
-    
+    "github.com/pocketbase/pocketbase/tools/migrate"
+    "log/slog"
+    "net"
+    "net/http"
     "time"
 )
 
 type PocketBaseStore struct {
-    app *pocketbase.PocketBase
+    app       *pocketbase.PocketBase
+    searchFTS bool
+
+    // admin serves the PocketBase admin UI once ServeAdmin is called;
+    // cancelAdmin ends its long-lived requests, like realtime streams
+    admin       *http.Server
+    cancelAdmin context.CancelFunc
 }
 
+// NewPocketBaseStore opens the PocketBase data in dataDir. Everything the
+// store needs is ready when it returns: the databases are open, migrated
+// and hold the collections. Call ServeAdmin to serve the admin UI and Close
+// to release the databases.
 func NewPocketBaseStore(dataDir string) (*PocketBaseStore, error) {
-    // Create a new PocketBase instance with a data directory
-    app := pocketbase.New()
-    
-    // Configure the root command - change from 127.0.0.1 to 0.0.0.0 to allow external access
-    app.RootCmd.SetArgs([]string{"serve", "--dir", dataDir, "--http", "0.0.0.0:8090"})
-    
-    // Start PocketBase in a goroutine
-    go func() {
-        if err := app.Start(); err != nil {
-            log.Printf("Failed to start PocketBase: %v", err)
-        }
-    }()
+    app := pocketbase.NewWithConfig(pocketbase.Config{DefaultDataDir: dataDir})
 
-    // Give PocketBase a moment to initialize
-    time.Sleep(time.Second)
-    
-    // Initialize the app
+    // Open the databases
     if err := app.Bootstrap(); err != nil {
         return nil, fmt.Errorf("failed to bootstrap PocketBase: %w", err)
     }
+
+    // Apply PocketBase's own migrations before creating collections on top
+    // of them, then reload the settings they may have changed
+    if err := runMigrations(app); err != nil {
+        return nil, fmt.Errorf("failed to migrate PocketBase: %w", err)
+    }
+    if err := app.RefreshSettings(); err != nil {
+        slog.Warn("Error loading PocketBase settings, using defaults", "error", err)
+    }
     
     // Ensure collection exists
     if err := ensureCollection(app); err != nil {
         return nil, fmt.Errorf("failed to ensure collection exists: %w", err)
     }
+    if err := ensureRaceCallsCollection(app); err != nil {
+        return nil, fmt.Errorf("failed to ensure race calls collection exists: %w", err)
+    }
+    if err := ensureSnapshotsCollection(app); err != nil {
+        return nil, fmt.Errorf("failed to ensure snapshots collection exists: %w", err)
+    }
+    if err := ensureWebhooksCollection(app); err != nil {
+        return nil, fmt.Errorf("failed to ensure webhooks collection exists: %w", err)
+    }
+    if err := ensureTemplateSetsCollection(app); err != nil {
+        return nil, fmt.Errorf("failed to ensure template sets collection exists: %w", err)
+    }
+    if err := ensureTranslationsCollection(app); err != nil {
+        return nil, fmt.Errorf("failed to ensure translations collection exists: %w", err)
+    }
+    if err := ensureAPIKeysCollection(app); err != nil {
+        return nil, fmt.Errorf("failed to ensure API keys collection exists: %w", err)
+    }
+    if err := ensureCORSOriginsCollection(app); err != nil {
+        return nil, fmt.Errorf("failed to ensure CORS origins collection exists: %w", err)
+    }
+    searchFTS, newIndex, err := ensureSearchIndex(app)
+    if err != nil {
+        return nil, fmt.Errorf("failed to ensure search index exists: %w", err)
+    }
+
+    store := &PocketBaseStore{app: app, searchFTS: searchFTS}
+    if newIndex {
+        if err := store.RebuildSearchIndex(); err != nil {
+            return nil, fmt.Errorf("failed to build search index: %w", err)
+        }
+    }
     
-    return &PocketBaseStore{app: app}, nil
+    return store, nil
 }
 
 func ensureCollection(app *pocketbase.PocketBase) error {
@@ -72,12 +117,30 @@ func ensureCollection(app *pocketbase.PocketBase) error {
                         Values: []string{"zip", "html"},
                     },
                 },
+                &schema.SchemaField{
+                    Name:     "election_id",
+                    Type:     schema.FieldTypeText,
+                    Required: false,
+                },
             ),
         }
         
         if err := app.Dao().SaveCollection(collection); err != nil {
             return fmt.Errorf("failed to save collection: %w", err)
         }
+        return nil
+    }
+
+    // Add fields introduced after the collection was first created
+    if collection.Schema.GetFieldByName("election_id") == nil {
+        collection.Schema.AddField(&schema.SchemaField{
+            Name:     "election_id",
+            Type:     schema.FieldTypeText,
+            Required: false,
+        })
+        if err := app.Dao().SaveCollection(collection); err != nil {
+            return fmt.Errorf("failed to update collection: %w", err)
+        }
     }
     return nil
 }
@@ -92,6 +155,7 @@ func (s *PocketBaseStore) SaveCountyLink(countyLink *models.CountyLink) error {
     record.Set("county_name", countyLink.CountyName)
     record.Set("link", countyLink.Link)
     record.Set("parse_method", string(countyLink.ParseMethod))
+    record.Set("election_id", countyLink.ElectionID)
     
     if err := s.app.Dao().SaveRecord(record); err != nil {
         return fmt.Errorf("failed to save record: %w", err)
@@ -111,6 +175,7 @@ func (s *PocketBaseStore) GetCountyLink(id string) (*models.CountyLink, error) {
         CountyName:  record.GetString("county_name"),
         Link:        record.GetString("link"),
         ParseMethod: models.ParseMethod(record.GetString("parse_method")),
+        ElectionID:  record.GetString("election_id"),
     }, nil
 }
 
@@ -127,6 +192,7 @@ func (s *PocketBaseStore) GetAllCountyLinks() ([]models.CountyLink, error) {
             CountyName:  record.GetString("county_name"),
             Link:        record.GetString("link"),
             ParseMethod: models.ParseMethod(record.GetString("parse_method")),
+            ElectionID:  record.GetString("election_id"),
         }
     }
     return links, nil
@@ -141,6 +207,7 @@ func (s *PocketBaseStore) UpdateCountyLink(id string, countyLink *models.CountyL
     record.Set("county_name", countyLink.CountyName)
     record.Set("link", countyLink.Link)
     record.Set("parse_method", string(countyLink.ParseMethod))
+    record.Set("election_id", countyLink.ElectionID)
     
     if err := s.app.Dao().SaveRecord(record); err != nil {
         return fmt.Errorf("failed to update record: %w", err)
@@ -164,4 +231,84 @@ func (s *PocketBaseStore) DeleteCountyLink(id string) error {
 
 func (s *PocketBaseStore) GetPocketBase() *pocketbase.PocketBase {
     return s.app
+}
+
+// runMigrations applies the migrations of the data and logs databases, which
+// PocketBase's serve command would otherwise apply as it starts
+func runMigrations(app *pocketbase.PocketBase) error {
+    appRunner, err := migrate.NewRunner(app.DB(), migrations.AppMigrations)
+    if err != nil {
+        return err
+    }
+    if _, err := appRunner.Up(); err != nil {
+        return err
+    }
+
+    logsRunner, err := migrate.NewRunner(app.LogsDB(), logs.LogsMigrations)
+    if err != nil {
+        return err
+    }
+    _, err = logsRunner.Up()
+    return err
+}
+
+// ServeAdmin serves the PocketBase admin UI and API on addr in the
+// background, through middleware such as the CORS policy. The address is
+// bound before it returns, so a port in use is reported here rather than
+// lost in a goroutine.
+func (s *PocketBaseStore) ServeAdmin(addr string, middleware func(http.Handler) http.Handler) error {
+    router, err := apis.InitApi(s.app)
+    if err != nil {
+        return fmt.Errorf("failed to initialize PocketBase API: %w", err)
+    }
+
+    listener, err := net.Listen("tcp", addr)
+    if err != nil {
+        return fmt.Errorf("failed to listen on %s: %w", addr, err)
+    }
+
+    baseCtx, cancel := context.WithCancel(context.Background())
+    s.cancelAdmin = cancel
+    s.admin = &http.Server{
+        Handler:           middleware(router),
+        ReadHeaderTimeout: 30 * time.Second,
+        BaseContext: func(net.Listener) context.Context {
+            return baseCtx
+        },
+    }
+    go func() {
+        if err := s.admin.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+            slog.Error("PocketBase admin server failed", "error", err)
+        }
+    }()
+    slog.Info("PocketBase admin UI available", "addr", listener.Addr().String())
+    return nil
+}
+
+// Close stops the admin server, waiting until ctx is done for its requests
+// to finish, then closes the databases. The store can't be used afterwards.
+func (s *PocketBaseStore) Close(ctx context.Context) error {
+    if s.admin != nil {
+        s.cancelAdmin()
+        if err := s.admin.Shutdown(ctx); err != nil {
+            slog.Warn("PocketBase admin server did not shut down cleanly", "error", err)
+        }
+    }
+    // Terminating runs PocketBase's own shutdown hooks, which write out its
+    // buffered logs, before the databases are closed
+    return s.app.OnTerminate().Trigger(&core.TerminateEvent{App: s.app}, func(e *core.TerminateEvent) error {
+        return e.App.ResetBootstrapState()
+    })
+}
+
+// Ping checks that the database answers a query
+func (s *PocketBaseStore) Ping(ctx context.Context) error {
+    if s.app.Dao() == nil {
+        return errors.New("database is closed")
+    }
+    var one int
+    if err := s.app.Dao().DB().NewQuery("SELECT 1").WithContext(ctx).Row(&one); err != nil {
+        return fmt.Errorf("failed to query database: %w", err)
+    }
+    return nil
 }
\ No newline at end of file
@@ -0,0 +1,168 @@
+package storage
+
+import (
+    "database/sql"
+    "era/internal/models"
+    "errors"
+    "fmt"
+    "strings"
+
+    "github.com/pocketbase/dbx"
+    "github.com/pocketbase/pocketbase"
+    pbModels "github.com/pocketbase/pocketbase/models"
+    "github.com/pocketbase/pocketbase/models/schema"
+    "github.com/pocketbase/pocketbase/tools/types"
+)
+
+const raceCallsCollection = "race_calls"
+
+// raceCallsIndex keeps one call per contest of each election. Contest IDs
+// repeat in every election, so they aren't unique alone.
+const raceCallsIndex = "CREATE UNIQUE INDEX idx_race_calls_election_contest ON race_calls (election_id, contest_id)"
+
+func ensureRaceCallsCollection(app *pocketbase.PocketBase) error {
+    if existing, err := app.Dao().FindCollectionByNameOrId(raceCallsCollection); err == nil {
+        // Collections created before calls were scoped by election are
+        // unique on the contest alone
+        indexes := types.JsonArray[string]{}
+        for _, index := range existing.Indexes {
+            if index == raceCallsIndex {
+                return nil
+            }
+            if !strings.Contains(index, "idx_race_calls_contest ") {
+                indexes = append(indexes, index)
+            }
+        }
+        existing.Indexes = append(indexes, raceCallsIndex)
+        if err := app.Dao().SaveCollection(existing); err != nil {
+            return fmt.Errorf("failed to add election index: %w", err)
+        }
+        return nil
+    }
+
+    collection := &pbModels.Collection{
+        Name: raceCallsCollection,
+        Type: pbModels.CollectionTypeBase,
+        Schema: schema.NewSchema(
+            &schema.SchemaField{Name: "contest_id", Type: schema.FieldTypeText, Required: true},
+            &schema.SchemaField{Name: "election_id", Type: schema.FieldTypeText},
+            &schema.SchemaField{Name: "winner", Type: schema.FieldTypeText, Required: true},
+            &schema.SchemaField{Name: "called_by", Type: schema.FieldTypeText},
+        ),
+        Indexes: types.JsonArray[string]{
+            raceCallsIndex,
+        },
+    }
+    if err := app.Dao().SaveCollection(collection); err != nil {
+        return fmt.Errorf("failed to save collection: %w", err)
+    }
+    return nil
+}
+
+// findRaceCall finds the record of the call for a contest of an election
+func (s *PocketBaseStore) findRaceCall(electionID, contestID string) (*pbModels.Record, error) {
+    records, err := s.app.Dao().FindRecordsByExpr(raceCallsCollection,
+        dbx.HashExp{"election_id": electionID, "contest_id": contestID})
+    if err != nil {
+        return nil, err
+    }
+    if len(records) == 0 {
+        return nil, sql.ErrNoRows
+    }
+    return records[0], nil
+}
+
+// GetRaceCall returns the call for a contest of an election, or nil when
+// the contest hasn't been called
+func (s *PocketBaseStore) GetRaceCall(electionID, contestID string) (*models.RaceCall, error) {
+    record, err := s.findRaceCall(electionID, contestID)
+    if errors.Is(err, sql.ErrNoRows) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch race call: %w", err)
+    }
+    call := raceCallFromRecord(record)
+    return &call, nil
+}
+
+// SaveRaceCall creates or replaces the call for a contest of an election
+func (s *PocketBaseStore) SaveRaceCall(call *models.RaceCall) error {
+    record, err := s.findRaceCall(call.ElectionID, call.ContestID)
+    if err != nil {
+        collection, err := s.app.Dao().FindCollectionByNameOrId(raceCallsCollection)
+        if err != nil {
+            return fmt.Errorf("failed to find collection: %w", err)
+        }
+        record = pbModels.NewRecord(collection)
+    }
+
+    record.Set("contest_id", call.ContestID)
+    record.Set("election_id", call.ElectionID)
+    record.Set("winner", call.Winner)
+    record.Set("called_by", call.CalledBy)
+
+    if err := s.app.Dao().SaveRecord(record); err != nil {
+        return fmt.Errorf("failed to save race call: %w", err)
+    }
+    call.CalledAt = record.GetDateTime("updated").Time()
+    return nil
+}
+
+// DeleteRaceCall retracts the call for a contest of an election
+func (s *PocketBaseStore) DeleteRaceCall(electionID, contestID string) error {
+    record, err := s.findRaceCall(electionID, contestID)
+    if err != nil {
+        return fmt.Errorf("failed to find race call: %w", err)
+    }
+
+    if err := s.app.Dao().DeleteRecord(record); err != nil {
+        return fmt.Errorf("failed to delete race call: %w", err)
+    }
+    return nil
+}
+
+// GetRaceCalls returns the race calls keyed by contest ID, optionally limited
+// to a single election. Without an election, a contest called in several
+// elections keeps one of its calls; use ListRaceCalls to get them all.
+func (s *PocketBaseStore) GetRaceCalls(electionID string) (map[string]models.RaceCall, error) {
+    list, err := s.ListRaceCalls(electionID)
+    if err != nil {
+        return nil, err
+    }
+    calls := make(map[string]models.RaceCall, len(list))
+    for _, call := range list {
+        calls[call.ContestID] = call
+    }
+    return calls, nil
+}
+
+// ListRaceCalls returns every race call, optionally limited to a single
+// election
+func (s *PocketBaseStore) ListRaceCalls(electionID string) ([]models.RaceCall, error) {
+    var exprs []dbx.Expression
+    if electionID != "" {
+        exprs = append(exprs, dbx.HashExp{"election_id": electionID})
+    }
+
+    records, err := s.app.Dao().FindRecordsByExpr(raceCallsCollection, exprs...)
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch race calls: %w", err)
+    }
+
+    calls := make([]models.RaceCall, 0, len(records))
+    for _, record := range records {
+        calls = append(calls, raceCallFromRecord(record))
+    }
+    return calls, nil
+}
+
+func raceCallFromRecord(record *pbModels.Record) models.RaceCall {
+    return models.RaceCall{
+        ContestID:  record.GetString("contest_id"),
+        ElectionID: record.GetString("election_id"),
+        Winner:     record.GetString("winner"),
+        CalledBy:   record.GetString("called_by"),
+        CalledAt:   record.GetDateTime("updated").Time(),
+    }
+}
@@ -0,0 +1,320 @@
+package storage
+
+import (
+    "era/internal/models"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/pocketbase/dbx"
+    pbModels "github.com/pocketbase/pocketbase/models"
+)
+
+const (
+    resultsCollectionPrefix = "county_"
+    resultsCollectionSuffix = "_results"
+)
+
+// ResultsCollectionName returns the collection holding results for a county slug
+func ResultsCollectionName(county string) string {
+    return resultsCollectionPrefix + county + resultsCollectionSuffix
+}
+
+// IsResultsCollection reports whether a collection holds county results
+func IsResultsCollection(name string) bool {
+    return strings.HasPrefix(name, resultsCollectionPrefix) &&
+        strings.HasSuffix(name, resultsCollectionSuffix) &&
+        len(name) > len(resultsCollectionPrefix)+len(resultsCollectionSuffix)
+}
+
+// ResultCounties returns the slugs of every county that has stored results
+func (s *PocketBaseStore) ResultCounties() ([]string, error) {
+    collections, err := s.app.Dao().FindCollectionsByType(pbModels.CollectionTypeBase)
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch collections: %w", err)
+    }
+
+    var counties []string
+    for _, collection := range collections {
+        if !IsResultsCollection(collection.Name) {
+            continue
+        }
+        county := strings.TrimSuffix(strings.TrimPrefix(collection.Name, resultsCollectionPrefix), resultsCollectionSuffix)
+        counties = append(counties, county)
+    }
+    sort.Strings(counties)
+    return counties, nil
+}
+
+// EachResult calls fn for every stored result matching the filter, one county
+// at a time, stopping at the first error returned by fn
+func (s *PocketBaseStore) EachResult(filter models.ResultFilter, fn func(models.ResultRow) error) error {
+    counties := []string{filter.County}
+    if filter.County == "" {
+        // Contest IDs carry their county, so only one collection needs scanning
+        if contestCounty, _, err := models.ParseContestID(filter.ContestID); err == nil {
+            counties = []string{contestCounty}
+        } else {
+            all, err := s.ResultCounties()
+            if err != nil {
+                return err
+            }
+            counties = all
+        }
+    }
+
+    for _, county := range counties {
+        rows, err := s.countyResults(county, filter)
+        if err != nil {
+            return err
+        }
+        for _, row := range rows {
+            if err := fn(row); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// GetResults returns every stored result matching the filter
+func (s *PocketBaseStore) GetResults(filter models.ResultFilter) ([]models.ResultRow, error) {
+    var rows []models.ResultRow
+    err := s.EachResult(filter, func(row models.ResultRow) error {
+        rows = append(rows, row)
+        return nil
+    })
+    return rows, err
+}
+
+// countyResults loads the filtered results for a single county
+func (s *PocketBaseStore) countyResults(county string, filter models.ResultFilter) ([]models.ResultRow, error) {
+    collection, err := s.app.Dao().FindCollectionByNameOrId(ResultsCollectionName(county))
+    if err != nil {
+        // A county without a results collection simply has no results yet
+        return nil, nil
+    }
+
+    query := s.app.Dao().RecordQuery(collection)
+
+    visible, err := s.visibleRows(county, collection)
+    if err != nil {
+        return nil, err
+    }
+    if visible != nil {
+        query.AndWhere(visible)
+    }
+
+    if filter.Type != "" {
+        query.AndWhere(dbx.HashExp{"type": filter.Type})
+    }
+    if filter.ElectionID != "" {
+        // Collections created before elections were tracked can't match
+        if collection.Schema.GetFieldByName("election_id") == nil {
+            return nil, nil
+        }
+        query.AndWhere(dbx.HashExp{"election_id": filter.ElectionID})
+    }
+
+    var records []*pbModels.Record
+    if err := query.All(&records); err != nil {
+        return nil, fmt.Errorf("failed to fetch results for %s: %w", county, err)
+    }
+
+    rows := make([]models.ResultRow, 0, len(records))
+    for _, record := range records {
+        row := recordToResultRow(county, record)
+        if !filter.Matches(row) {
+            continue
+        }
+        rows = append(rows, row)
+    }
+    return rows, nil
+}
+
+// LatestResultRecords returns the records of a county's latest snapshot of
+// the given result type, or nil when the county has no results collection.
+// A non-empty electionID limits them to that election.
+func (s *PocketBaseStore) LatestResultRecords(county, electionID, resultType string) ([]*pbModels.Record, error) {
+    collection, err := s.app.Dao().FindCollectionByNameOrId(ResultsCollectionName(county))
+    if err != nil {
+        return nil, nil
+    }
+
+    query := s.app.Dao().RecordQuery(collection).
+        AndWhere(dbx.HashExp{"type": resultType})
+
+    visible, err := s.visibleRows(county, collection)
+    if err != nil {
+        return nil, err
+    }
+    if visible != nil {
+        query.AndWhere(visible)
+    }
+    if electionID != "" && collection.Schema.GetFieldByName("election_id") != nil {
+        query.AndWhere(dbx.HashExp{"election_id": electionID})
+    }
+
+    records := []*pbModels.Record{}
+    if err := query.All(&records); err != nil {
+        return nil, fmt.Errorf("failed to fetch results for %s: %w", county, err)
+    }
+    return records, nil
+}
+
+// visibleRows returns the condition selecting the rows readers may see. For
+// each election, only rows from its latest complete snapshot are visible;
+// until an election completes one, its rows stored before snapshots existed
+// are used instead.
+func (s *PocketBaseStore) visibleRows(county string, collection *pbModels.Collection) (dbx.Expression, error) {
+    if collection.Schema.GetFieldByName("snapshot") == nil {
+        return nil, nil
+    }
+
+    latest, err := s.LatestSnapshots(county)
+    if err != nil {
+        return nil, err
+    }
+    snapshotIDs := make([]interface{}, 0, len(latest))
+    elections := make([]interface{}, 0, len(latest))
+    for _, snapshot := range latest {
+        snapshotIDs = append(snapshotIDs, snapshot.ID)
+        elections = append(elections, snapshot.ElectionID)
+    }
+    return dbx.Or(
+        dbx.In("snapshot", snapshotIDs...),
+        dbx.And(dbx.HashExp{"snapshot": ""}, dbx.NotIn("election_id", elections...)),
+    ), nil
+}
+
+// sortColumns maps result sort fields to their columns
+var sortColumns = map[string]string{
+    models.SortContest:    "contest_name",
+    models.SortVotes:      "votes",
+    models.SortPercentage: "percentage",
+}
+
+// QueryResults returns one page of a county's filtered, sorted results, or
+// nil when the county has no results collection. Pages are addressed either
+// by number or by the cursor returned with the previous page; ties in the
+// sort column are broken by insertion order so cursors are stable.
+func (s *PocketBaseStore) QueryResults(county string, q models.ResultQuery) (*models.ResultPage, error) {
+    table := ResultsCollectionName(county)
+    collection, err := s.app.Dao().FindCollectionByNameOrId(table)
+    if err != nil {
+        return nil, nil
+    }
+
+    var where []dbx.Expression
+    visible, err := s.visibleRows(county, collection)
+    if err != nil {
+        return nil, err
+    }
+    if visible != nil {
+        where = append(where, visible)
+    }
+    if q.Type != "" {
+        where = append(where, dbx.HashExp{"type": q.Type})
+    }
+    if q.ElectionID != "" {
+        // Collections created before elections were tracked can't match
+        if collection.Schema.GetFieldByName("election_id") == nil {
+            where = append(where, dbx.NewExp("0=1"))
+        } else {
+            where = append(where, dbx.HashExp{"election_id": q.ElectionID})
+        }
+    }
+    if q.Contest != "" {
+        where = append(where, dbx.Like("contest_name", q.Contest))
+    }
+    if q.Choice != "" {
+        where = append(where, dbx.Like("choice_name", q.Choice))
+    }
+    if q.MinVotes > 0 {
+        where = append(where, dbx.NewExp("votes >= {:min_votes}", dbx.Params{"min_votes": q.MinVotes}))
+    }
+
+    page := &models.ResultPage{
+        Page:    q.Page,
+        PerPage: q.PerPage,
+        Results: []models.ResultRow{},
+    }
+    if err := s.app.Dao().DB().Select("COUNT(*)").From(table).Where(dbx.And(where...)).Row(&page.Total); err != nil {
+        return nil, fmt.Errorf("failed to count results for %s: %w", county, err)
+    }
+    page.TotalPages = (page.Total + q.PerPage - 1) / q.PerPage
+
+    field, desc := q.SortField()
+    column := sortColumns[field]
+    direction, comparison := "ASC", ">"
+    if desc {
+        direction, comparison = "DESC", "<"
+    }
+
+    query := s.app.Dao().DB().Select("*", "rowid AS _seq").From(table).
+        OrderBy(column+" "+direction, "rowid "+direction).
+        Limit(int64(q.PerPage + 1))
+
+    if q.Cursor != "" {
+        cursor, err := models.DecodeCursor(q.Cursor)
+        if err != nil {
+            return nil, err
+        }
+        where = append(where, dbx.NewExp(
+            fmt.Sprintf("(%[1]s %[2]s {:cursor_value} OR (%[1]s = {:cursor_value} AND rowid %[2]s {:cursor_seq}))", column, comparison),
+            dbx.Params{"cursor_value": cursor.Value, "cursor_seq": cursor.Seq},
+        ))
+        page.Page = 0
+    } else {
+        query.Offset(int64((q.Page - 1) * q.PerPage))
+    }
+
+    var data []dbx.NullStringMap
+    if err := query.Where(dbx.And(where...)).All(&data); err != nil {
+        return nil, fmt.Errorf("failed to fetch results for %s: %w", county, err)
+    }
+
+    for i, row := range data {
+        record := pbModels.NewRecordFromNullStringMap(collection, row)
+        if i == q.PerPage {
+            // The extra row only signals that another page exists
+            last := data[i-1]
+            seq, _ := strconv.ParseInt(last["_seq"].String, 10, 64)
+            page.NextCursor = models.Cursor{
+                Sort:  q.Sort,
+                Value: pbModels.NewRecordFromNullStringMap(collection, last).Get(column),
+                Seq:   seq,
+            }.Encode()
+            break
+        }
+        page.Results = append(page.Results, recordToResultRow(county, record))
+    }
+    return page, nil
+}
+
+// recordToResultRow converts a stored result record into a ResultRow
+func recordToResultRow(county string, record *pbModels.Record) models.ResultRow {
+    row := models.ResultRow{
+        ID:          record.Id,
+        ElectionID:  record.GetString("election_id"),
+        County:      county,
+        Type:        record.GetString("type"),
+        ContestName: record.GetString("contest_name"),
+        ChoiceName:  record.GetString("choice_name"),
+        Votes:       record.GetInt("votes"),
+        Percentage:  record.GetFloat("percentage"),
+        Party:       record.GetString("party"),
+        Precinct:    record.GetString("precinct"),
+        Description: record.GetString("description"),
+
+        PrecinctsTotal:     record.GetInt("precincts_total"),
+        PrecinctsReporting: record.GetInt("precincts_reporting"),
+        Updated:            record.GetDateTime("updated").Time(),
+    }
+    row.ContestID = models.ContestID(county, row.ContestName)
+    if row.Type == "measure" {
+        row.IsBond = record.GetBool("is_bond")
+    }
+    return row
+}
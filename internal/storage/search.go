@@ -0,0 +1,366 @@
+package storage
+
+import (
+    "era/internal/models"
+    "fmt"
+    "log/slog"
+    "math"
+    "sort"
+    "strings"
+    "unicode"
+
+    "github.com/pocketbase/dbx"
+    "github.com/pocketbase/pocketbase"
+    "github.com/pocketbase/pocketbase/daos"
+)
+
+// searchTable is an FTS5 index of every visible contest and choice. The
+// trigram tokenizer gives substring matches, which also back the fuzzy
+// matching below. SQLite builds without FTS5 (the cgo driver unless built
+// with the sqlite_fts5 tag) get a plain table searched with LIKE instead.
+const searchTable = "results_search"
+
+// searchCandidates caps how many index rows are ranked for a single query
+const searchCandidates = 500
+
+// minSimilarity is the share of a search term's trigrams a word must contain
+// to count as a fuzzy match
+const minSimilarity = 0.5
+
+// ensureSearchIndex creates the search index, reporting whether it is backed
+// by FTS5 and whether it is new and needs to be filled
+func ensureSearchIndex(app *pocketbase.PocketBase) (fts bool, created bool, err error) {
+    var existing []struct {
+        SQL string `db:"sql"`
+    }
+    if err := app.Dao().DB().Select("sql").From("sqlite_master").
+        Where(dbx.HashExp{"type": "table", "name": searchTable}).All(&existing); err != nil {
+        return false, false, fmt.Errorf("failed to check search index: %w", err)
+    }
+    if len(existing) > 0 {
+        return strings.Contains(strings.ToLower(existing[0].SQL), "fts5"), false, nil
+    }
+
+    _, err = app.Dao().DB().NewQuery(`CREATE VIRTUAL TABLE ` + searchTable + ` USING fts5(
+        contest_name,
+        choice_name,
+        description,
+        county UNINDEXED,
+        election_id UNINDEXED,
+        contest_id UNINDEXED,
+        type UNINDEXED,
+        tokenize = 'trigram'
+    )`).Execute()
+    if err == nil {
+        return true, true, nil
+    }
+    slog.Warn("FTS5 unavailable, falling back to a plain search table", "error", err)
+
+    _, err = app.Dao().DB().NewQuery(`CREATE TABLE ` + searchTable + ` (
+        contest_name TEXT NOT NULL DEFAULT '',
+        choice_name TEXT NOT NULL DEFAULT '',
+        description TEXT NOT NULL DEFAULT '',
+        county TEXT NOT NULL DEFAULT '',
+        election_id TEXT NOT NULL DEFAULT '',
+        contest_id TEXT NOT NULL DEFAULT '',
+        type TEXT NOT NULL DEFAULT ''
+    )`).Execute()
+    if err != nil {
+        return false, false, fmt.Errorf("failed to create search index: %w", err)
+    }
+    if _, err := app.Dao().DB().NewQuery(`CREATE INDEX idx_` + searchTable + `_county ON ` + searchTable + ` (county)`).Execute(); err != nil {
+        return false, false, fmt.Errorf("failed to index search table: %w", err)
+    }
+    return false, true, nil
+}
+
+// IndexCountySearch replaces a county's search entries with its currently
+// visible results
+func (s *PocketBaseStore) IndexCountySearch(county string) error {
+    rows, err := s.GetResults(models.ResultFilter{County: county})
+    if err != nil {
+        return err
+    }
+
+    return s.app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+        if _, err := txDao.DB().Delete(searchTable, dbx.HashExp{"county": county}).Execute(); err != nil {
+            return fmt.Errorf("failed to clear search index for %s: %w", county, err)
+        }
+
+        // Precinct rows repeat the same contest and choice
+        seen := make(map[string]bool)
+        for _, row := range rows {
+            key := row.ContestID + "\x00" + row.ChoiceName
+            if seen[key] {
+                continue
+            }
+            seen[key] = true
+
+            if _, err := txDao.DB().Insert(searchTable, dbx.Params{
+                "contest_name": row.ContestName,
+                "choice_name":  row.ChoiceName,
+                "description":  row.Description,
+                "county":       county,
+                "election_id":  row.ElectionID,
+                "contest_id":   row.ContestID,
+                "type":         row.Type,
+            }).Execute(); err != nil {
+                return fmt.Errorf("failed to index %s: %w", row.ContestID, err)
+            }
+        }
+        return nil
+    })
+}
+
+// RebuildSearchIndex re-indexes every county's visible results
+func (s *PocketBaseStore) RebuildSearchIndex() error {
+    if err := s.ClearSearchIndex(); err != nil {
+        return err
+    }
+
+    counties, err := s.ResultCounties()
+    if err != nil {
+        return err
+    }
+    for _, county := range counties {
+        if err := s.IndexCountySearch(county); err != nil {
+            return err
+        }
+    }
+    slog.Info("Rebuilt search index", "counties", len(counties))
+    return nil
+}
+
+// ClearSearchIndex removes every search entry
+func (s *PocketBaseStore) ClearSearchIndex() error {
+    if _, err := s.app.Dao().DB().Delete(searchTable, nil).Execute(); err != nil {
+        return fmt.Errorf("failed to clear search index: %w", err)
+    }
+    return nil
+}
+
+// searchRow is a single search index entry
+type searchRow struct {
+    ContestName string `db:"contest_name"`
+    ChoiceName  string `db:"choice_name"`
+    Description string `db:"description"`
+    County      string `db:"county"`
+    ElectionID  string `db:"election_id"`
+    ContestID   string `db:"contest_id"`
+    Type        string `db:"type"`
+}
+
+// Search finds contests whose name, choices or description match the query,
+// tolerating typos and partial words, ranked best match first
+func (s *PocketBaseStore) Search(q models.SearchQuery) ([]models.SearchHit, error) {
+    terms := searchTerms(q.Text)
+    if len(terms) == 0 {
+        return []models.SearchHit{}, nil
+    }
+
+    query := s.app.Dao().DB().
+        Select("contest_name", "choice_name", "description", "county", "election_id", "contest_id", "type").
+        From(searchTable).
+        Limit(searchCandidates)
+
+    where := []dbx.Expression{}
+    if match := trigramMatch(terms); match != "" && s.searchFTS {
+        // Any shared trigram makes a row a candidate; candidates are then
+        // filtered and scored below
+        where = append(where, dbx.NewExp(searchTable+" MATCH {:match}", dbx.Params{"match": match}))
+        query.OrderBy("rank")
+    } else if match != "" {
+        where = append(where, trigramLike(terms))
+    } else {
+        // Terms too short for trigrams fall back to substring matching
+        text := strings.Join(terms, " ")
+        where = append(where, dbx.Or(dbx.Like("contest_name", text), dbx.Like("choice_name", text)))
+    }
+    if q.ElectionID != "" {
+        where = append(where, dbx.HashExp{"election_id": q.ElectionID})
+    }
+    if q.County != "" {
+        where = append(where, dbx.HashExp{"county": q.County})
+    }
+    if q.Type != "" {
+        where = append(where, dbx.HashExp{"type": q.Type})
+    }
+
+    var rows []searchRow
+    if err := query.Where(dbx.And(where...)).All(&rows); err != nil {
+        return nil, fmt.Errorf("failed to search results: %w", err)
+    }
+
+    hits := make(map[string]*models.SearchHit)
+    var order []string
+    for _, row := range rows {
+        score, choiceMatched, ok := scoreSearchRow(terms, row)
+        if !ok {
+            continue
+        }
+
+        hit, exists := hits[row.ContestID]
+        if !exists {
+            hit = &models.SearchHit{
+                ContestID:   row.ContestID,
+                ContestName: row.ContestName,
+                County:      row.County,
+                ElectionID:  row.ElectionID,
+                Type:        row.Type,
+                Description: row.Description,
+            }
+            hits[row.ContestID] = hit
+            order = append(order, row.ContestID)
+        }
+        if score > hit.Score {
+            hit.Score = score
+        }
+        if choiceMatched {
+            hit.MatchedChoices = append(hit.MatchedChoices, row.ChoiceName)
+        }
+    }
+
+    results := make([]models.SearchHit, 0, len(order))
+    for _, id := range order {
+        hit := hits[id]
+        hit.Score = math.Round(hit.Score*1000) / 1000
+        results = append(results, *hit)
+    }
+    sort.SliceStable(results, func(i, j int) bool {
+        if results[i].Score != results[j].Score {
+            return results[i].Score > results[j].Score
+        }
+        if results[i].ContestName != results[j].ContestName {
+            return results[i].ContestName < results[j].ContestName
+        }
+        return results[i].County < results[j].County
+    })
+    if len(results) > q.Limit {
+        results = results[:q.Limit]
+    }
+    return results, nil
+}
+
+// scoreSearchRow scores an index row against the search terms. Every term
+// must match a word of the contest, choice or description, either exactly or
+// fuzzily; the score sums each term's best similarity and rewards the whole
+// query appearing as a phrase. choiceMatched reports whether the choice
+// itself matched.
+func scoreSearchRow(terms []string, row searchRow) (score float64, choiceMatched bool, ok bool) {
+    contest := searchTerms(row.ContestName)
+    choice := searchTerms(row.ChoiceName)
+    description := searchTerms(row.Description)
+
+    for _, term := range terms {
+        contestSim := bestSimilarity(term, contest)
+        choiceSim := bestSimilarity(term, choice)
+        descriptionSim := bestSimilarity(term, description) * 0.5
+
+        best := math.Max(contestSim, math.Max(choiceSim, descriptionSim))
+        if best == 0 {
+            return 0, false, false
+        }
+        if choiceSim > 0 && choiceSim >= contestSim {
+            choiceMatched = true
+        }
+        score += best
+    }
+
+    phrase := strings.Join(terms, " ")
+    if strings.Contains(strings.Join(contest, " "), phrase) {
+        score++
+    }
+    if strings.Contains(strings.Join(choice, " "), phrase) {
+        score++
+        choiceMatched = true
+    }
+    return score, choiceMatched, true
+}
+
+// bestSimilarity returns how well a term matches the closest word: 1 for an
+// exact or prefix match, otherwise the share of the term's trigrams found in
+// the word, or 0 below minSimilarity
+func bestSimilarity(term string, words []string) float64 {
+    best := 0.0
+    termTrigrams := trigrams(term)
+    for _, word := range words {
+        if word == term || (len(term) >= 3 && strings.HasPrefix(word, term)) {
+            return 1
+        }
+        if len(termTrigrams) == 0 {
+            continue
+        }
+
+        wordTrigrams := make(map[string]bool)
+        for _, t := range trigrams(word) {
+            wordTrigrams[t] = true
+        }
+        shared := 0
+        for _, t := range termTrigrams {
+            if wordTrigrams[t] {
+                shared++
+            }
+        }
+        similarity := float64(shared) / float64(len(termTrigrams))
+        if similarity >= minSimilarity && similarity > best {
+            best = similarity
+        }
+    }
+    return best
+}
+
+// trigramMatch builds an FTS5 query matching any trigram of any term
+func trigramMatch(terms []string) string {
+    seen := make(map[string]bool)
+    var parts []string
+    for _, term := range terms {
+        for _, t := range trigrams(term) {
+            if seen[t] {
+                continue
+            }
+            seen[t] = true
+            parts = append(parts, `"`+t+`"`)
+        }
+    }
+    return strings.Join(parts, " OR ")
+}
+
+// trigramLike matches any trigram of any term with LIKE, for indexes
+// without FTS5
+func trigramLike(terms []string) dbx.Expression {
+    seen := make(map[string]bool)
+    var exps []dbx.Expression
+    for _, term := range terms {
+        for _, t := range trigrams(term) {
+            if seen[t] {
+                continue
+            }
+            seen[t] = true
+            exps = append(exps,
+                dbx.Like("contest_name", t),
+                dbx.Like("choice_name", t),
+                dbx.Like("description", t),
+            )
+        }
+    }
+    return dbx.Or(exps...)
+}
+
+// searchTerms lowercases text and splits it into letter and digit runs
+func searchTerms(text string) []string {
+    return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    })
+}
+
+func trigrams(word string) []string {
+    runes := []rune(word)
+    if len(runes) < 3 {
+        return nil
+    }
+    result := make([]string, 0, len(runes)-2)
+    for i := 0; i+3 <= len(runes); i++ {
+        result = append(result, string(runes[i:i+3]))
+    }
+    return result
+}
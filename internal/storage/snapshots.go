@@ -0,0 +1,252 @@
+package storage
+
+import (
+    "era/internal/models"
+    "fmt"
+    "log/slog"
+
+    "github.com/pocketbase/dbx"
+    "github.com/pocketbase/pocketbase"
+    pbModels "github.com/pocketbase/pocketbase/models"
+    "github.com/pocketbase/pocketbase/models/schema"
+    "github.com/pocketbase/pocketbase/tools/types"
+)
+
+const snapshotsCollection = "snapshots"
+
+// snapshotsElectionIndex looks up a county's snapshots of one election
+const snapshotsElectionIndex = "CREATE INDEX idx_snapshots_county_election ON snapshots (county, election_id, status, created)"
+
+func ensureSnapshotsCollection(app *pocketbase.PocketBase) error {
+    if existing, err := app.Dao().FindCollectionByNameOrId(snapshotsCollection); err == nil {
+        // Collections created before snapshots were scoped by election lack
+        // the index of their lookups
+        for _, index := range existing.Indexes {
+            if index == snapshotsElectionIndex {
+                return nil
+            }
+        }
+        existing.Indexes = append(existing.Indexes, snapshotsElectionIndex)
+        if err := app.Dao().SaveCollection(existing); err != nil {
+            return fmt.Errorf("failed to add election index: %w", err)
+        }
+        return nil
+    }
+
+    collection := &pbModels.Collection{
+        Name: snapshotsCollection,
+        Type: pbModels.CollectionTypeBase,
+        Schema: schema.NewSchema(
+            &schema.SchemaField{Name: "county", Type: schema.FieldTypeText, Required: true},
+            &schema.SchemaField{Name: "election_id", Type: schema.FieldTypeText},
+            &schema.SchemaField{Name: "source", Type: schema.FieldTypeText},
+            &schema.SchemaField{Name: "method", Type: schema.FieldTypeText},
+            &schema.SchemaField{
+                Name:     "status",
+                Type:     schema.FieldTypeSelect,
+                Required: true,
+                Options: &schema.SelectOptions{
+                    Values: []string{models.SnapshotPending, models.SnapshotComplete},
+                },
+            },
+            &schema.SchemaField{Name: "row_count", Type: schema.FieldTypeNumber},
+        ),
+        Indexes: types.JsonArray[string]{
+            "CREATE INDEX idx_snapshots_county ON snapshots (county, status, created)",
+            snapshotsElectionIndex,
+        },
+    }
+    if err := app.Dao().SaveCollection(collection); err != nil {
+        return fmt.Errorf("failed to save collection: %w", err)
+    }
+    return nil
+}
+
+// CreateSnapshot starts a new pending snapshot of a county's results for
+// one election
+func (s *PocketBaseStore) CreateSnapshot(snapshot *models.Snapshot) error {
+    collection, err := s.app.Dao().FindCollectionByNameOrId(snapshotsCollection)
+    if err != nil {
+        return fmt.Errorf("failed to find collection: %w", err)
+    }
+
+    record := pbModels.NewRecord(collection)
+    record.Set("county", snapshot.County)
+    record.Set("election_id", snapshot.ElectionID)
+    record.Set("source", snapshot.Source)
+    record.Set("method", snapshot.Method)
+    record.Set("status", models.SnapshotPending)
+
+    if err := s.app.Dao().SaveRecord(record); err != nil {
+        return fmt.Errorf("failed to save snapshot: %w", err)
+    }
+
+    snapshot.ID = record.Id
+    snapshot.Status = models.SnapshotPending
+    snapshot.CreatedAt = record.GetDateTime("created").Time()
+    return nil
+}
+
+// CompleteSnapshot marks a snapshot as the county's latest results for its
+// election and removes the rows of the election's earlier snapshots. Rows of
+// other elections are kept.
+func (s *PocketBaseStore) CompleteSnapshot(snapshot *models.Snapshot) error {
+    record, err := s.app.Dao().FindRecordById(snapshotsCollection, snapshot.ID)
+    if err != nil {
+        return fmt.Errorf("failed to find snapshot: %w", err)
+    }
+
+    // A source without any rows never creates the results collection
+    table := ResultsCollectionName(snapshot.County)
+    collection, findErr := s.app.Dao().FindCollectionByNameOrId(table)
+    hasResults := findErr == nil && collection.Schema.GetFieldByName("snapshot") != nil
+
+    var count struct{ N int }
+    if hasResults {
+        if err := s.app.Dao().DB().Select("COUNT(*) AS n").From(table).
+            Where(dbx.HashExp{"snapshot": snapshot.ID}).One(&count); err != nil {
+            return fmt.Errorf("failed to count snapshot rows: %w", err)
+        }
+    }
+
+    record.Set("status", models.SnapshotComplete)
+    record.Set("row_count", count.N)
+    if err := s.app.Dao().SaveRecord(record); err != nil {
+        return fmt.Errorf("failed to complete snapshot: %w", err)
+    }
+    snapshot.Status = models.SnapshotComplete
+    snapshot.RowCount = count.N
+
+    // Rows of the election from earlier snapshots (and from before snapshots
+    // existed) are no longer visible to readers
+    if hasResults {
+        stale := dbx.And(
+            dbx.HashExp{"election_id": snapshot.ElectionID},
+            dbx.Not(dbx.HashExp{"snapshot": snapshot.ID}),
+        )
+        if _, err := s.app.Dao().DB().Delete(table, stale).Execute(); err != nil {
+            return fmt.Errorf("failed to prune old results: %w", err)
+        }
+    }
+
+    // The results are stored either way, so a stale search index is only
+    // logged; it is rebuilt on the next snapshot
+    if err := s.IndexCountySearch(snapshot.County); err != nil {
+        slog.Error("Error indexing results for search", "county", snapshot.County, "error", err)
+    }
+    return nil
+}
+
+// DiscardSnapshot removes a failed snapshot and any rows it wrote
+func (s *PocketBaseStore) DiscardSnapshot(snapshot *models.Snapshot) error {
+    table := ResultsCollectionName(snapshot.County)
+    if collection, err := s.app.Dao().FindCollectionByNameOrId(table); err == nil && collection.Schema.GetFieldByName("snapshot") != nil {
+        if _, err := s.app.Dao().DB().Delete(table, dbx.HashExp{"snapshot": snapshot.ID}).Execute(); err != nil {
+            return fmt.Errorf("failed to delete snapshot rows: %w", err)
+        }
+    }
+
+    record, err := s.app.Dao().FindRecordById(snapshotsCollection, snapshot.ID)
+    if err != nil {
+        return fmt.Errorf("failed to find snapshot: %w", err)
+    }
+    if err := s.app.Dao().DeleteRecord(record); err != nil {
+        return fmt.Errorf("failed to delete snapshot: %w", err)
+    }
+    return nil
+}
+
+// LatestSnapshot returns the most recent complete snapshot for a county of
+// any election, or nil when the county has never completed one
+func (s *PocketBaseStore) LatestSnapshot(county string) (*models.Snapshot, error) {
+    records, err := s.app.Dao().FindRecordsByFilter(
+        snapshotsCollection,
+        "county = {:county} && status = {:status}",
+        "-created",
+        1,
+        0,
+        dbx.Params{"county": county, "status": models.SnapshotComplete},
+    )
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch snapshots: %w", err)
+    }
+    if len(records) == 0 {
+        return nil, nil
+    }
+
+    snapshot := recordToSnapshot(records[0])
+    return &snapshot, nil
+}
+
+// LatestElectionSnapshot returns the most recent complete snapshot of a
+// county's results for one election, or nil when there is none
+func (s *PocketBaseStore) LatestElectionSnapshot(county, electionID string) (*models.Snapshot, error) {
+    records, err := s.app.Dao().FindRecordsByFilter(
+        snapshotsCollection,
+        "county = {:county} && election_id = {:election_id} && status = {:status}",
+        "-created",
+        1,
+        0,
+        dbx.Params{"county": county, "election_id": electionID, "status": models.SnapshotComplete},
+    )
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch snapshots: %w", err)
+    }
+    if len(records) == 0 {
+        return nil, nil
+    }
+
+    snapshot := recordToSnapshot(records[0])
+    return &snapshot, nil
+}
+
+// LatestSnapshots returns the most recent complete snapshot of each election
+// a county has results for
+func (s *PocketBaseStore) LatestSnapshots(county string) ([]models.Snapshot, error) {
+    records, err := s.app.Dao().FindRecordsByExpr(snapshotsCollection, dbx.NewExp(
+        `county = {:county} AND status = {:status} AND created = (
+            SELECT MAX(latest.created) FROM `+snapshotsCollection+` latest
+            WHERE latest.county = `+snapshotsCollection+`.county
+                AND latest.election_id = `+snapshotsCollection+`.election_id
+                AND latest.status = `+snapshotsCollection+`.status
+        )`,
+        dbx.Params{"county": county, "status": models.SnapshotComplete},
+    ))
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch snapshots: %w", err)
+    }
+
+    snapshots := make([]models.Snapshot, 0, len(records))
+    seen := make(map[string]bool)
+    for _, record := range records {
+        snapshot := recordToSnapshot(record)
+        // Snapshots stored within the same millisecond tie on created
+        if seen[snapshot.ElectionID] {
+            continue
+        }
+        seen[snapshot.ElectionID] = true
+        snapshots = append(snapshots, snapshot)
+    }
+    return snapshots, nil
+}
+
+// ClearSnapshots deletes every snapshot record
+func (s *PocketBaseStore) ClearSnapshots() error {
+    if _, err := s.app.Dao().DB().Delete(snapshotsCollection, nil).Execute(); err != nil {
+        return fmt.Errorf("failed to clear snapshots: %w", err)
+    }
+    return nil
+}
+
+func recordToSnapshot(record *pbModels.Record) models.Snapshot {
+    return models.Snapshot{
+        ID:         record.Id,
+        County:     record.GetString("county"),
+        ElectionID: record.GetString("election_id"),
+        Source:     record.GetString("source"),
+        Method:     record.GetString("method"),
+        Status:     record.GetString("status"),
+        RowCount:   record.GetInt("row_count"),
+        CreatedAt:  record.GetDateTime("created").Time(),
+    }
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+    "era/internal/models"
+    "fmt"
+
+    "github.com/pocketbase/dbx"
+    "github.com/pocketbase/pocketbase"
+    pbModels "github.com/pocketbase/pocketbase/models"
+    "github.com/pocketbase/pocketbase/models/schema"
+    "github.com/pocketbase/pocketbase/tools/types"
+)
+
+const templateSetsCollection = "template_sets"
+
+// maxTemplatesSize bounds the JSON of a set's template overrides
+const maxTemplatesSize = 1 << 20
+
+func ensureTemplateSetsCollection(app *pocketbase.PocketBase) error {
+    if _, err := app.Dao().FindCollectionByNameOrId(templateSetsCollection); err == nil {
+        return nil
+    }
+
+    collection := &pbModels.Collection{
+        Name: templateSetsCollection,
+        Type: pbModels.CollectionTypeBase,
+        Schema: schema.NewSchema(
+            &schema.SchemaField{Name: "name", Type: schema.FieldTypeText, Required: true},
+            &schema.SchemaField{Name: "description", Type: schema.FieldTypeText},
+            &schema.SchemaField{
+                Name:    "templates",
+                Type:    schema.FieldTypeJson,
+                Options: &schema.JsonOptions{MaxSize: maxTemplatesSize},
+            },
+            &schema.SchemaField{Name: "stylesheet", Type: schema.FieldTypeText},
+            &schema.SchemaField{
+                Name:    "partners",
+                Type:    schema.FieldTypeJson,
+                Options: &schema.JsonOptions{MaxSize: 64 << 10},
+            },
+        ),
+        Indexes: types.JsonArray[string]{
+            "CREATE UNIQUE INDEX idx_template_sets_name ON template_sets (name)",
+        },
+    }
+    if err := app.Dao().SaveCollection(collection); err != nil {
+        return fmt.Errorf("failed to save collection: %w", err)
+    }
+    return nil
+}
+
+// SaveTemplateSet creates a template set
+func (s *PocketBaseStore) SaveTemplateSet(set *models.TemplateSet) error {
+    collection, err := s.app.Dao().FindCollectionByNameOrId(templateSetsCollection)
+    if err != nil {
+        return fmt.Errorf("failed to find collection: %w", err)
+    }
+
+    record := pbModels.NewRecord(collection)
+    setTemplateSetFields(record, set)
+
+    if err := s.app.Dao().SaveRecord(record); err != nil {
+        return fmt.Errorf("failed to save template set: %w", err)
+    }
+    *set = recordToTemplateSet(record)
+    return nil
+}
+
+// UpdateTemplateSet replaces an existing template set
+func (s *PocketBaseStore) UpdateTemplateSet(set *models.TemplateSet) error {
+    record, err := s.app.Dao().FindRecordById(templateSetsCollection, set.ID)
+    if err != nil {
+        return fmt.Errorf("failed to find template set: %w", err)
+    }
+
+    setTemplateSetFields(record, set)
+
+    if err := s.app.Dao().SaveRecord(record); err != nil {
+        return fmt.Errorf("failed to update template set: %w", err)
+    }
+    *set = recordToTemplateSet(record)
+    return nil
+}
+
+// GetTemplateSet retrieves a template set by name
+func (s *PocketBaseStore) GetTemplateSet(name string) (*models.TemplateSet, error) {
+    record, err := s.app.Dao().FindFirstRecordByData(templateSetsCollection, "name", name)
+    if err != nil {
+        return nil, fmt.Errorf("failed to find template set: %w", err)
+    }
+
+    set := recordToTemplateSet(record)
+    return &set, nil
+}
+
+// GetTemplateSets retrieves every template set, ordered by name
+func (s *PocketBaseStore) GetTemplateSets() ([]models.TemplateSet, error) {
+    records, err := s.app.Dao().FindRecordsByFilter(templateSetsCollection, "id != ''", "name", 0, 0)
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch template sets: %w", err)
+    }
+
+    sets := make([]models.TemplateSet, 0, len(records))
+    for _, record := range records {
+        sets = append(sets, recordToTemplateSet(record))
+    }
+    return sets, nil
+}
+
+// PartnerTemplateSet returns the template set assigned to a partner, or nil
+// when the partner has none
+func (s *PocketBaseStore) PartnerTemplateSet(partner string) (*models.TemplateSet, error) {
+    records, err := s.app.Dao().FindRecordsByFilter(
+        templateSetsCollection,
+        "partners ~ {:partner}",
+        "name",
+        0,
+        0,
+        dbx.Params{"partner": `"` + partner + `"`},
+    )
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch template sets: %w", err)
+    }
+
+    // The filter matches the quoted name anywhere in the JSON, so confirm
+    // the partner is listed
+    for _, record := range records {
+        set := recordToTemplateSet(record)
+        for _, p := range set.Partners {
+            if p == partner {
+                return &set, nil
+            }
+        }
+    }
+    return nil, nil
+}
+
+// DeleteTemplateSet removes a template set by name
+func (s *PocketBaseStore) DeleteTemplateSet(name string) error {
+    record, err := s.app.Dao().FindFirstRecordByData(templateSetsCollection, "name", name)
+    if err != nil {
+        return fmt.Errorf("failed to find template set: %w", err)
+    }
+
+    if err := s.app.Dao().DeleteRecord(record); err != nil {
+        return fmt.Errorf("failed to delete template set: %w", err)
+    }
+    return nil
+}
+
+func setTemplateSetFields(record *pbModels.Record, set *models.TemplateSet) {
+    templates := set.Templates
+    if templates == nil {
+        templates = map[string]string{}
+    }
+    partners := set.Partners
+    if partners == nil {
+        partners = []string{}
+    }
+
+    record.Set("name", set.Name)
+    record.Set("description", set.Description)
+    record.Set("templates", templates)
+    record.Set("stylesheet", set.Stylesheet)
+    record.Set("partners", partners)
+}
+
+func recordToTemplateSet(record *pbModels.Record) models.TemplateSet {
+    set := models.TemplateSet{
+        ID:          record.Id,
+        Name:        record.GetString("name"),
+        Description: record.GetString("description"),
+        Stylesheet:  record.GetString("stylesheet"),
+        CreatedAt:   record.GetDateTime("created").Time(),
+        UpdatedAt:   record.GetDateTime("updated").Time(),
+    }
+    record.UnmarshalJSONField("templates", &set.Templates)
+    record.UnmarshalJSONField("partners", &set.Partners)
+    return set
+}
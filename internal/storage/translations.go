@@ -0,0 +1,154 @@
+package storage
+
+import (
+    "era/internal/models"
+    "fmt"
+
+    "github.com/pocketbase/dbx"
+    "github.com/pocketbase/pocketbase"
+    pbModels "github.com/pocketbase/pocketbase/models"
+    "github.com/pocketbase/pocketbase/models/schema"
+    "github.com/pocketbase/pocketbase/tools/types"
+)
+
+const translationsCollection = "contest_translations"
+
+func ensureTranslationsCollection(app *pocketbase.PocketBase) error {
+    if _, err := app.Dao().FindCollectionByNameOrId(translationsCollection); err == nil {
+        return nil
+    }
+
+    collection := &pbModels.Collection{
+        Name: translationsCollection,
+        Type: pbModels.CollectionTypeBase,
+        Schema: schema.NewSchema(
+            &schema.SchemaField{Name: "contest_id", Type: schema.FieldTypeText, Required: true},
+            &schema.SchemaField{Name: "lang", Type: schema.FieldTypeText, Required: true},
+            &schema.SchemaField{Name: "title", Type: schema.FieldTypeText, Required: true},
+            &schema.SchemaField{Name: "description", Type: schema.FieldTypeText},
+            &schema.SchemaField{
+                Name:    "choices",
+                Type:    schema.FieldTypeJson,
+                Options: &schema.JsonOptions{MaxSize: 64 << 10},
+            },
+        ),
+        Indexes: types.JsonArray[string]{
+            "CREATE UNIQUE INDEX idx_contest_translations_contest ON contest_translations (contest_id, lang)",
+        },
+    }
+    if err := app.Dao().SaveCollection(collection); err != nil {
+        return fmt.Errorf("failed to save collection: %w", err)
+    }
+    return nil
+}
+
+// SaveContestTranslation creates or replaces a contest's translation into
+// one language
+func (s *PocketBaseStore) SaveContestTranslation(t *models.ContestTranslation) error {
+    record, err := s.app.Dao().FindFirstRecordByFilter(
+        translationsCollection,
+        "contest_id = {:contest} && lang = {:lang}",
+        dbx.Params{"contest": t.ContestID, "lang": t.Lang},
+    )
+    if err != nil {
+        collection, err := s.app.Dao().FindCollectionByNameOrId(translationsCollection)
+        if err != nil {
+            return fmt.Errorf("failed to find collection: %w", err)
+        }
+        record = pbModels.NewRecord(collection)
+    }
+
+    choices := t.Choices
+    if choices == nil {
+        choices = map[string]string{}
+    }
+    record.Set("contest_id", t.ContestID)
+    record.Set("lang", t.Lang)
+    record.Set("title", t.Title)
+    record.Set("description", t.Description)
+    record.Set("choices", choices)
+
+    if err := s.app.Dao().SaveRecord(record); err != nil {
+        return fmt.Errorf("failed to save contest translation: %w", err)
+    }
+    t.UpdatedAt = record.GetDateTime("updated").Time()
+    return nil
+}
+
+// GetContestTranslations retrieves every translation of a contest
+func (s *PocketBaseStore) GetContestTranslations(contestID string) ([]models.ContestTranslation, error) {
+    records, err := s.app.Dao().FindRecordsByFilter(
+        translationsCollection,
+        "contest_id = {:contest}",
+        "lang",
+        0,
+        0,
+        dbx.Params{"contest": contestID},
+    )
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch contest translations: %w", err)
+    }
+
+    translations := make([]models.ContestTranslation, 0, len(records))
+    for _, record := range records {
+        translations = append(translations, recordToTranslation(record))
+    }
+    return translations, nil
+}
+
+// ContestTranslations returns the translations of the given contests into
+// one language, keyed by contest ID. Contests without one are left out.
+func (s *PocketBaseStore) ContestTranslations(lang string, contestIDs []string) (map[string]models.ContestTranslation, error) {
+    result := make(map[string]models.ContestTranslation)
+    if len(contestIDs) == 0 {
+        return result, nil
+    }
+
+    ids := make([]interface{}, len(contestIDs))
+    for i, id := range contestIDs {
+        ids[i] = id
+    }
+    records, err := s.app.Dao().FindRecordsByExpr(
+        translationsCollection,
+        dbx.HashExp{"lang": lang},
+        dbx.In("contest_id", ids...),
+    )
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch contest translations: %w", err)
+    }
+
+    for _, record := range records {
+        t := recordToTranslation(record)
+        result[t.ContestID] = t
+    }
+    return result, nil
+}
+
+// DeleteContestTranslation removes a contest's translation into one language
+func (s *PocketBaseStore) DeleteContestTranslation(contestID, lang string) error {
+    record, err := s.app.Dao().FindFirstRecordByFilter(
+        translationsCollection,
+        "contest_id = {:contest} && lang = {:lang}",
+        dbx.Params{"contest": contestID, "lang": lang},
+    )
+    if err != nil {
+        return fmt.Errorf("failed to find contest translation: %w", err)
+    }
+
+    if err := s.app.Dao().DeleteRecord(record); err != nil {
+        return fmt.Errorf("failed to delete contest translation: %w", err)
+    }
+    return nil
+}
+
+func recordToTranslation(record *pbModels.Record) models.ContestTranslation {
+    t := models.ContestTranslation{
+        ContestID:   record.GetString("contest_id"),
+        Lang:        record.GetString("lang"),
+        Title:       record.GetString("title"),
+        Description: record.GetString("description"),
+        UpdatedAt:   record.GetDateTime("updated").Time(),
+    }
+    record.UnmarshalJSONField("choices", &t.Choices)
+    return t
+}
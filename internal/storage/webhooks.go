@@ -0,0 +1,247 @@
+package storage
+
+import (
+    "era/internal/models"
+    "fmt"
+    "time"
+
+    "github.com/pocketbase/dbx"
+    "github.com/pocketbase/pocketbase"
+    pbModels "github.com/pocketbase/pocketbase/models"
+    "github.com/pocketbase/pocketbase/models/schema"
+    "github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+    webhooksCollection          = "webhooks"
+    webhookDeliveriesCollection = "webhook_deliveries"
+)
+
+func ensureWebhooksCollection(app *pocketbase.PocketBase) error {
+    if _, err := app.Dao().FindCollectionByNameOrId(webhooksCollection); err != nil {
+        collection := &pbModels.Collection{
+            Name: webhooksCollection,
+            Type: pbModels.CollectionTypeBase,
+            Schema: schema.NewSchema(
+                &schema.SchemaField{Name: "url", Type: schema.FieldTypeUrl, Required: true},
+                &schema.SchemaField{Name: "secret", Type: schema.FieldTypeText, Required: true},
+                &schema.SchemaField{
+                    Name:     "events",
+                    Type:     schema.FieldTypeSelect,
+                    Required: true,
+                    Options: &schema.SelectOptions{
+                        MaxSelect: len(models.WebhookEventTypes),
+                        Values:    models.WebhookEventTypes,
+                    },
+                },
+                &schema.SchemaField{Name: "election_id", Type: schema.FieldTypeText},
+                &schema.SchemaField{Name: "county", Type: schema.FieldTypeText},
+                &schema.SchemaField{Name: "contest_id", Type: schema.FieldTypeText},
+                &schema.SchemaField{Name: "active", Type: schema.FieldTypeBool},
+            ),
+        }
+        if err := app.Dao().SaveCollection(collection); err != nil {
+            return fmt.Errorf("failed to save collection: %w", err)
+        }
+    }
+
+    if _, err := app.Dao().FindCollectionByNameOrId(webhookDeliveriesCollection); err != nil {
+        collection := &pbModels.Collection{
+            Name: webhookDeliveriesCollection,
+            Type: pbModels.CollectionTypeBase,
+            Schema: schema.NewSchema(
+                &schema.SchemaField{Name: "webhook", Type: schema.FieldTypeText, Required: true},
+                &schema.SchemaField{Name: "delivery_id", Type: schema.FieldTypeText, Required: true},
+                &schema.SchemaField{Name: "event", Type: schema.FieldTypeText},
+                &schema.SchemaField{Name: "attempt", Type: schema.FieldTypeNumber},
+                &schema.SchemaField{Name: "status_code", Type: schema.FieldTypeNumber},
+                &schema.SchemaField{Name: "success", Type: schema.FieldTypeBool},
+                &schema.SchemaField{Name: "error", Type: schema.FieldTypeText},
+                &schema.SchemaField{Name: "duration_ms", Type: schema.FieldTypeNumber},
+            ),
+            Indexes: types.JsonArray[string]{
+                "CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries (webhook, created)",
+            },
+        }
+        if err := app.Dao().SaveCollection(collection); err != nil {
+            return fmt.Errorf("failed to save collection: %w", err)
+        }
+    }
+    return nil
+}
+
+// SaveWebhook creates a webhook subscription
+func (s *PocketBaseStore) SaveWebhook(webhook *models.Webhook) error {
+    collection, err := s.app.Dao().FindCollectionByNameOrId(webhooksCollection)
+    if err != nil {
+        return fmt.Errorf("failed to find collection: %w", err)
+    }
+
+    record := pbModels.NewRecord(collection)
+    setWebhookFields(record, webhook)
+
+    if err := s.app.Dao().SaveRecord(record); err != nil {
+        return fmt.Errorf("failed to save webhook: %w", err)
+    }
+    webhook.ID = record.Id
+    webhook.CreatedAt = record.GetDateTime("created").Time()
+    return nil
+}
+
+// UpdateWebhook replaces an existing webhook subscription
+func (s *PocketBaseStore) UpdateWebhook(webhook *models.Webhook) error {
+    record, err := s.app.Dao().FindRecordById(webhooksCollection, webhook.ID)
+    if err != nil {
+        return fmt.Errorf("failed to find webhook: %w", err)
+    }
+
+    setWebhookFields(record, webhook)
+
+    if err := s.app.Dao().SaveRecord(record); err != nil {
+        return fmt.Errorf("failed to update webhook: %w", err)
+    }
+    webhook.CreatedAt = record.GetDateTime("created").Time()
+    return nil
+}
+
+// GetWebhook retrieves a webhook subscription by ID
+func (s *PocketBaseStore) GetWebhook(id string) (*models.Webhook, error) {
+    record, err := s.app.Dao().FindRecordById(webhooksCollection, id)
+    if err != nil {
+        return nil, fmt.Errorf("failed to find webhook: %w", err)
+    }
+
+    webhook := recordToWebhook(record)
+    return &webhook, nil
+}
+
+// GetWebhooks retrieves every webhook subscription
+func (s *PocketBaseStore) GetWebhooks() ([]models.Webhook, error) {
+    records, err := s.app.Dao().FindRecordsByExpr(webhooksCollection)
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch webhooks: %w", err)
+    }
+
+    webhooks := make([]models.Webhook, 0, len(records))
+    for _, record := range records {
+        webhooks = append(webhooks, recordToWebhook(record))
+    }
+    return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook subscription and its delivery log
+func (s *PocketBaseStore) DeleteWebhook(id string) error {
+    record, err := s.app.Dao().FindRecordById(webhooksCollection, id)
+    if err != nil {
+        return fmt.Errorf("failed to find webhook: %w", err)
+    }
+
+    if err := s.app.Dao().DeleteRecord(record); err != nil {
+        return fmt.Errorf("failed to delete webhook: %w", err)
+    }
+    if _, err := s.app.Dao().DB().Delete(webhookDeliveriesCollection, dbx.HashExp{"webhook": id}).Execute(); err != nil {
+        return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+    }
+    return nil
+}
+
+// PruneWebhookDeliveries removes the delivery attempts logged before a time,
+// returning how many were removed
+func (s *PocketBaseStore) PruneWebhookDeliveries(before time.Time) (int64, error) {
+    cutoff, err := types.ParseDateTime(before)
+    if err != nil {
+        return 0, fmt.Errorf("invalid cutoff: %w", err)
+    }
+
+    result, err := s.app.Dao().DB().Delete(
+        webhookDeliveriesCollection,
+        dbx.NewExp("created < {:cutoff}", dbx.Params{"cutoff": cutoff.String()}),
+    ).Execute()
+    if err != nil {
+        return 0, fmt.Errorf("failed to prune webhook deliveries: %w", err)
+    }
+    removed, _ := result.RowsAffected()
+    return removed, nil
+}
+
+// LogWebhookDelivery records a delivery attempt
+func (s *PocketBaseStore) LogWebhookDelivery(delivery *models.WebhookDelivery) error {
+    collection, err := s.app.Dao().FindCollectionByNameOrId(webhookDeliveriesCollection)
+    if err != nil {
+        return fmt.Errorf("failed to find collection: %w", err)
+    }
+
+    record := pbModels.NewRecord(collection)
+    record.Set("webhook", delivery.WebhookID)
+    record.Set("delivery_id", delivery.DeliveryID)
+    record.Set("event", delivery.Event)
+    record.Set("attempt", delivery.Attempt)
+    record.Set("status_code", delivery.StatusCode)
+    record.Set("success", delivery.Success)
+    record.Set("error", delivery.Error)
+    record.Set("duration_ms", delivery.Duration)
+
+    if err := s.app.Dao().SaveRecord(record); err != nil {
+        return fmt.Errorf("failed to save webhook delivery: %w", err)
+    }
+    delivery.ID = record.Id
+    delivery.CreatedAt = record.GetDateTime("created").Time()
+    return nil
+}
+
+// GetWebhookDeliveries returns the most recent delivery attempts for a
+// webhook, newest first
+func (s *PocketBaseStore) GetWebhookDeliveries(webhookID string, limit int) ([]models.WebhookDelivery, error) {
+    records, err := s.app.Dao().FindRecordsByFilter(
+        webhookDeliveriesCollection,
+        "webhook = {:webhook}",
+        "-created",
+        limit,
+        0,
+        dbx.Params{"webhook": webhookID},
+    )
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch webhook deliveries: %w", err)
+    }
+
+    deliveries := make([]models.WebhookDelivery, 0, len(records))
+    for _, record := range records {
+        deliveries = append(deliveries, models.WebhookDelivery{
+            ID:         record.Id,
+            WebhookID:  record.GetString("webhook"),
+            DeliveryID: record.GetString("delivery_id"),
+            Event:      record.GetString("event"),
+            Attempt:    record.GetInt("attempt"),
+            StatusCode: record.GetInt("status_code"),
+            Success:    record.GetBool("success"),
+            Error:      record.GetString("error"),
+            Duration:   int64(record.GetInt("duration_ms")),
+            CreatedAt:  record.GetDateTime("created").Time(),
+        })
+    }
+    return deliveries, nil
+}
+
+func setWebhookFields(record *pbModels.Record, webhook *models.Webhook) {
+    record.Set("url", webhook.URL)
+    record.Set("secret", webhook.Secret)
+    record.Set("events", webhook.Events)
+    record.Set("election_id", webhook.ElectionID)
+    record.Set("county", webhook.County)
+    record.Set("contest_id", webhook.ContestID)
+    record.Set("active", webhook.Active)
+}
+
+func recordToWebhook(record *pbModels.Record) models.Webhook {
+    return models.Webhook{
+        ID:         record.Id,
+        URL:        record.GetString("url"),
+        Secret:     record.GetString("secret"),
+        Events:     record.GetStringSlice("events"),
+        ElectionID: record.GetString("election_id"),
+        County:     record.GetString("county"),
+        ContestID:  record.GetString("contest_id"),
+        Active:     record.GetBool("active"),
+        CreatedAt:  record.GetDateTime("created").Time(),
+    }
+}
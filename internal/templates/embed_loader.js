@@ -0,0 +1,67 @@
+// Election results embed loader.
+//
+// Usage:
+//   <div data-era-contest="marin.measure-a" data-compact="1"></div>
+//   <script src="https://results.example.com/embed/loader.js" async></script>
+//
+// Every element with data-era-contest is replaced with an iframe showing the
+// contest's live results. Optional attributes: data-title-color,
+// data-accent-color, data-votes-color, data-background, data-compact,
+// data-refresh and data-lang (the page language is used by default). Each
+// iframe resizes itself to fit the widget.
+(function () {
+    var script = document.currentScript;
+    var base = script ? new URL(script.src).origin : "";
+    var themeAttributes = ["title_color", "accent_color", "votes_color", "background", "compact", "refresh", "lang"];
+
+    function widgetURL(el) {
+        var params = new URLSearchParams();
+        themeAttributes.forEach(function (name) {
+            var value = el.getAttribute("data-" + name.replace("_", "-"));
+            if (value) {
+                params.set(name, value);
+            }
+        });
+        var query = params.toString();
+        return base + "/embed/contests/" + encodeURIComponent(el.getAttribute("data-era-contest")) +
+            (query ? "?" + query : "");
+    }
+
+    function mount() {
+        var placeholders = document.querySelectorAll("[data-era-contest]:not([data-era-mounted])");
+        Array.prototype.forEach.call(placeholders, function (el) {
+            var frame = document.createElement("iframe");
+            frame.src = widgetURL(el);
+            frame.title = "Election results";
+            frame.loading = "lazy";
+            frame.style.width = "100%";
+            frame.style.border = "0";
+            frame.style.height = "200px";
+            frame.setAttribute("scrolling", "no");
+            el.setAttribute("data-era-mounted", "1");
+            el.appendChild(frame);
+        });
+    }
+
+    window.addEventListener("message", function (event) {
+        if (base && event.origin !== base) {
+            return;
+        }
+        var data = event.data;
+        if (!data || data.type !== "era:resize") {
+            return;
+        }
+        var frames = document.querySelectorAll("[data-era-mounted] iframe");
+        Array.prototype.forEach.call(frames, function (frame) {
+            if (frame.contentWindow === event.source) {
+                frame.style.height = data.height + "px";
+            }
+        });
+    });
+
+    if (document.readyState === "loading") {
+        document.addEventListener("DOMContentLoaded", mount);
+    } else {
+        mount();
+    }
+})();
@@ -0,0 +1,128 @@
+// Package templates renders the HTML results pages. The default templates
+// are embedded in the binary and parsed once; named template sets stored in
+// PocketBase can override any page or partial by name.
+package templates
+
+import (
+	"embed"
+	"era/internal/models"
+	"fmt"
+	"html/template"
+	"io"
+	"sync"
+	"time"
+)
+
+//go:embed *.html *.js
+var files embed.FS
+
+// Pages rendered from a template set
+const (
+	Candidates = "candidates"
+	Measures   = "measures"
+	Results    = "results"
+)
+
+// Names lists every template a set may override: the pages, and the
+// partials they share for the document head, styles, header, footer and
+// timestamps
+var Names = []string{Candidates, Measures, Results, "head", "styles", "header", "footer", "timestamp"}
+
+// base holds the default pages and partials. It is never executed so it
+// can still be cloned for each template set.
+var base = template.Must(template.ParseFS(files, "partials.html", "candidates.html", "measures.html", "results.html"))
+
+var defaultSet = mustClone()
+
+// EmbedContest is the template of the embeddable contest widget
+var EmbedContest = template.Must(template.ParseFS(files, "embed_contest.html"))
+
+// EmbedLoader is the script that turns placeholders on partner pages into
+// widget iframes
+var EmbedLoader = mustRead("embed_loader.js")
+
+// Set is a parsed template set ready to render pages
+type Set struct {
+	tmpl *template.Template
+}
+
+// Default returns the embedded templates
+func Default() *Set {
+	return defaultSet
+}
+
+// Execute renders one of the pages with data
+func (s *Set) Execute(w io.Writer, page string, data interface{}) error {
+	return s.tmpl.ExecuteTemplate(w, page, data)
+}
+
+// Compile parses a set's overrides on top of the default templates.
+// Templates the set doesn't override keep their default.
+func Compile(overrides map[string]string) (*Set, error) {
+	tmpl, err := base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	for name, source := range overrides {
+		if !isName(name) {
+			return nil, fmt.Errorf("unknown template: %s", name)
+		}
+		if _, err := tmpl.New(name).Parse(source); err != nil {
+			return nil, fmt.Errorf("invalid %s template: %w", name, err)
+		}
+	}
+	return &Set{tmpl: tmpl}, nil
+}
+
+// Sets compiles stored template sets, keeping each until the set changes
+type Sets struct {
+	mu       sync.Mutex
+	compiled map[string]compiled
+}
+
+type compiled struct {
+	updated time.Time
+	set     *Set
+}
+
+// NewSets creates an empty compiled template set cache
+func NewSets() *Sets {
+	return &Sets{compiled: make(map[string]compiled)}
+}
+
+// Get returns the compiled templates of a stored set
+func (s *Sets) Get(ts *models.TemplateSet) (*Set, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.compiled[ts.Name]; ok && c.updated.Equal(ts.UpdatedAt) {
+		return c.set, nil
+	}
+
+	set, err := Compile(ts.Templates)
+	if err != nil {
+		return nil, err
+	}
+	s.compiled[ts.Name] = compiled{updated: ts.UpdatedAt, set: set}
+	return set, nil
+}
+
+func isName(name string) bool {
+	for _, n := range Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func mustClone() *Set {
+	return &Set{tmpl: template.Must(base.Clone())}
+}
+
+func mustRead(name string) []byte {
+	data, err := files.ReadFile(name)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -0,0 +1,277 @@
+// Package urlpolicy decides which result source URLs the server may fetch.
+// Parse requests name arbitrary URLs, so without a policy they could make
+// the server reach internal services or download unbounded archives.
+package urlpolicy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrBlocked is wrapped by errors for URLs the policy refuses to fetch
+	ErrBlocked = errors.New("URL not allowed")
+
+	// ErrTooLarge is wrapped by errors for downloads over a size limit
+	ErrTooLarge = errors.New("size limit exceeded")
+)
+
+// IsViolation reports whether err is the policy refusing a URL or download
+func IsViolation(err error) bool {
+	return errors.Is(err, ErrBlocked) || errors.Is(err, ErrTooLarge)
+}
+
+// Defaults for a policy's limits
+const (
+	DefaultMaxRedirects        = 5
+	DefaultMaxDownloadSize     = 100 << 20
+	DefaultMaxDecompressedSize = 500 << 20
+)
+
+// blockedNetworks are the address ranges sources may never resolve to, on
+// top of what net.IP's classification methods catch
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved
+	"64:ff9b::/96",  // NAT64, which can reach private IPv4 addresses
+)
+
+// Policy limits what a parse may fetch
+type Policy struct {
+	// AllowedHosts are host patterns sources must match: an exact host, or
+	// "*.example.com" for any subdomain of example.com. Empty allows any
+	// public host.
+	AllowedHosts []string
+
+	// AllowPrivate permits loopback, private and link-local addresses, for
+	// development against local fixtures
+	AllowPrivate bool
+
+	MaxRedirects        int
+	MaxDownloadSize     int64
+	MaxDecompressedSize int64
+}
+
+// Default returns a policy allowing any public host within the default
+// limits
+func Default() *Policy {
+	return &Policy{
+		MaxRedirects:        DefaultMaxRedirects,
+		MaxDownloadSize:     DefaultMaxDownloadSize,
+		MaxDecompressedSize: DefaultMaxDecompressedSize,
+	}
+}
+
+// Check refuses URLs that aren't http(s), whose host isn't allowlisted, or
+// that name a blocked IP address. Hostnames are checked again once
+// resolved, when the Client connects.
+func (p *Policy) Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBlocked, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q, only http and https are fetched", ErrBlocked, u.Scheme)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: URLs with credentials aren't fetched", ErrBlocked)
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return fmt.Errorf("%w: no host", ErrBlocked)
+	}
+	if !p.hostAllowed(host) {
+		return fmt.Errorf("%w: host %q isn't on the allowlist", ErrBlocked, host)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return p.checkIP(ip)
+	}
+	return nil
+}
+
+func (p *Policy) hostAllowed(host string) bool {
+	if len(p.AllowedHosts) == 0 {
+		return true
+	}
+	for _, pattern := range p.AllowedHosts {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) checkIP(ip net.IP) error {
+	if p.AllowPrivate || !blockedIP(ip) {
+		return nil
+	}
+	return fmt.Errorf("%w: address %s is private or reserved", ErrBlocked, ip)
+}
+
+// IsPrivateHost reports whether a URL host is localhost or a loopback,
+// private or reserved IP address. Other names can only be caught once
+// resolved, which Client does when it connects.
+func IsPrivateHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && blockedIP(ip)
+}
+
+func blockedIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Client returns an HTTP client that only connects to addresses the policy
+// allows, after DNS resolution so a host can't resolve to an internal
+// address, and re-checks every redirect. Proxy settings are ignored, since
+// a proxy would connect on the client's behalf.
+func (p *Policy) Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrBlocked, err)
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("%w: unresolved address %q", ErrBlocked, host)
+			}
+			return p.checkIP(ip)
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > p.MaxRedirects {
+				return fmt.Errorf("%w: more than %d redirects", ErrBlocked, p.MaxRedirects)
+			}
+			return p.Check(req.URL.String())
+		},
+	}
+}
+
+// LimitReader reads at most limit bytes from r, failing with ErrTooLarge
+// rather than truncating when there is more. A limit of 0 or less doesn't
+// limit r.
+func LimitReader(r io.Reader, limit int64, what string) io.Reader {
+	if limit <= 0 {
+		return r
+	}
+	return &limitedReader{r: r, remaining: limit, limit: limit, what: what}
+}
+
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+	what      string
+}
+
+func (l *limitedReader) Read(b []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, l.err()
+	}
+	// Read one byte past the limit to tell a file of exactly the limit
+	// from a larger one
+	if int64(len(b)) > l.remaining+1 {
+		b = b[:l.remaining+1]
+	}
+	n, err := l.r.Read(b)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), l.err()
+	}
+	return n, err
+}
+
+func (l *limitedReader) err() error {
+	return fmt.Errorf("%w: %s is larger than %s", ErrTooLarge, l.what, FormatSize(l.limit))
+}
+
+// ParseSize parses a size in bytes such as "100MB", "1GB" or "65536"
+func ParseSize(size string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if number, ok := strings.CutSuffix(value, unit.suffix); ok {
+			value, multiplier = strings.TrimSpace(number), unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return n * multiplier, nil
+}
+
+// FormatSize prints a size in the largest whole unit ParseSize accepts
+func FormatSize(size int64) string {
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}} {
+		if size >= unit.size && size%unit.size == 0 {
+			return fmt.Sprintf("%d%s", size/unit.size, unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,432 @@
+// Package webhooks delivers result changes to partner webhook subscriptions
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"era/internal/models"
+	"era/internal/storage"
+	"era/internal/urlpolicy"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventPing is sent when a webhook is tested
+const EventPing = "ping"
+
+// Request headers sent with every delivery
+const (
+	HeaderEvent     = "X-Era-Event"
+	HeaderDelivery  = "X-Era-Delivery"
+	HeaderTimestamp = "X-Era-Timestamp"
+	HeaderSignature = "X-Era-Signature"
+)
+
+const (
+	maxAttempts    = 5
+	initialBackoff = 2 * time.Second
+	requestTimeout = 10 * time.Second
+	queueSize      = 1000
+	// stalledAfter is how long deliveries may stay queued without a worker
+	// taking one before Check reports the workers stalled
+	stalledAfter = time.Minute
+	// pruneEvery is how often delivery attempts past their retention are
+	// removed
+	pruneEvery = time.Hour
+)
+
+// Payload is the JSON body posted to webhook URLs
+type Payload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// job is a single payload on its way to a single webhook
+type job struct {
+	webhook models.Webhook
+	event   string
+	id      string
+	body    []byte
+	attempt int
+}
+
+// Dispatcher signs and delivers webhook payloads in the background, retrying
+// failed deliveries with exponential backoff and logging every attempt
+type Dispatcher struct {
+	store  *storage.PocketBaseStore
+	client *http.Client
+	queue  chan job
+	// ctx is cancelled when Close gives up waiting, aborting deliveries in
+	// flight
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	closed  bool
+	pending sync.WaitGroup
+	workers sync.WaitGroup
+	// retries holds the jobs waiting out their backoff, by their timer
+	retries map[*time.Timer]job
+	// lastTaken is when a worker last took a delivery off the queue
+	lastTaken time.Time
+
+	// retention is how long delivery attempts are kept; 0 keeps them
+	retention  time.Duration
+	lastPruned time.Time
+}
+
+// NewDispatcher creates a dispatcher and starts its delivery workers.
+// Deliveries go through policy's client, so webhook URLs can't reach
+// internal addresses, even once resolved or redirected. Delivery attempts
+// older than retention are removed as snapshots are stored.
+func NewDispatcher(store *storage.PocketBaseStore, workers int, policy *urlpolicy.Policy, retention time.Duration) *Dispatcher {
+	d := &Dispatcher{
+		store:   store,
+		client:  policy.Client(requestTimeout),
+		queue:   make(chan job, queueSize),
+		retries: make(map[*time.Timer]job),
+		// Deliveries queued right after startup aren't overdue yet
+		lastTaken: time.Now(),
+		retention: retention,
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	for i := 0; i < workers; i++ {
+		d.workers.Add(1)
+		go d.work()
+	}
+	return d
+}
+
+// HandleIngest queues deliveries for every webhook interested in a new
+// snapshot. It is registered as a parser manager ingest listener.
+func (d *Dispatcher) HandleIngest(result *models.IngestResult) {
+	d.prune()
+
+	webhooks, err := d.store.GetWebhooks()
+	if err != nil {
+		slog.Error("Error fetching webhooks", "error", err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	snapshot := result.Snapshot
+	snapshotData := map[string]interface{}{
+		"snapshot":         snapshot,
+		"contests_changed": len(result.Changes),
+	}
+	for _, webhook := range webhooks {
+		if webhook.Wants(models.WebhookEventSnapshot, snapshot.ElectionID, snapshot.County, "") {
+			d.Send(webhook, models.WebhookEventSnapshot, snapshotData)
+		}
+	}
+
+	for _, change := range result.Changes {
+		// A contest's first appearance isn't a leader change
+		leaderChanged := change.LeaderChanged && change.PreviousLeader != ""
+		for _, webhook := range webhooks {
+			if webhook.Wants(models.WebhookEventContest, change.ElectionID, change.County, change.ContestID) {
+				d.Send(webhook, models.WebhookEventContest, change)
+			}
+			if leaderChanged && webhook.Wants(models.WebhookEventLeaderChanged, change.ElectionID, change.County, change.ContestID) {
+				d.Send(webhook, models.WebhookEventLeaderChanged, change)
+			}
+		}
+	}
+}
+
+// Send queues a payload for delivery to a webhook and returns its delivery ID
+func (d *Dispatcher) Send(webhook models.Webhook, event string, data interface{}) (string, error) {
+	id, err := newDeliveryID()
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(Payload{
+		ID:        id,
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	if !d.enqueue(job{webhook: webhook, event: event, id: id, body: body, attempt: 1}) {
+		return "", fmt.Errorf("webhook queue is full")
+	}
+	return id, nil
+}
+
+// Queued returns the number of deliveries waiting for a worker
+func (d *Dispatcher) Queued() int {
+	return len(d.queue)
+}
+
+// Check returns an error when deliveries have stopped: the dispatcher is
+// closed, or deliveries are queued but no worker has taken one lately
+func (d *Dispatcher) Check() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return fmt.Errorf("dispatcher is closed")
+	}
+	if queued := len(d.queue); queued > 0 && time.Since(d.lastTaken) > stalledAfter {
+		return fmt.Errorf("%d deliveries queued but none taken by a worker for %s", queued, time.Since(d.lastTaken).Round(time.Second))
+	}
+	return nil
+}
+
+// Close stops accepting new deliveries and waits for queued deliveries,
+// including pending retries, to finish. When ctx expires first, deliveries
+// in flight are aborted, and queued deliveries and retries still waiting
+// out their backoff are logged as failed without another attempt.
+func (d *Dispatcher) Close(ctx context.Context) {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		d.abandon()
+		<-done
+	}
+
+	d.cancel()
+	close(d.queue)
+	d.workers.Wait()
+}
+
+// abandon gives up on every delivery not yet made: deliveries in flight are
+// aborted and fail, and queued deliveries and pending retries are logged as
+// failed
+func (d *Dispatcher) abandon() {
+	d.cancel()
+
+	var abandoned []job
+	d.mu.Lock()
+	for timer, j := range d.retries {
+		// A timer that already fired is enqueueing its job, which a
+		// worker then fails
+		if timer.Stop() {
+			abandoned = append(abandoned, j)
+			d.pending.Done()
+		}
+		delete(d.retries, timer)
+	}
+	d.mu.Unlock()
+
+drain:
+	for {
+		select {
+		case j := <-d.queue:
+			abandoned = append(abandoned, j)
+			d.pending.Done()
+		default:
+			break drain
+		}
+	}
+
+	for _, j := range abandoned {
+		delivery := models.WebhookDelivery{
+			WebhookID:  j.webhook.ID,
+			DeliveryID: j.id,
+			Event:      j.event,
+			Attempt:    j.attempt,
+			Error:      "not delivered before the server shut down",
+		}
+		if err := d.store.LogWebhookDelivery(&delivery); err != nil {
+			slog.Error("Error logging webhook delivery", "delivery_id", j.id, "error", err)
+		}
+	}
+	if len(abandoned) > 0 {
+		slog.Warn("Webhook deliveries abandoned at shutdown", "deliveries", len(abandoned))
+	}
+}
+
+// prune removes delivery attempts older than the retention, at most once
+// every pruneEvery
+func (d *Dispatcher) prune() {
+	if d.retention <= 0 {
+		return
+	}
+	d.mu.Lock()
+	now := time.Now()
+	due := now.Sub(d.lastPruned) >= pruneEvery
+	if due {
+		d.lastPruned = now
+	}
+	d.mu.Unlock()
+	if !due {
+		return
+	}
+
+	removed, err := d.store.PruneWebhookDeliveries(now.Add(-d.retention))
+	if err != nil {
+		slog.Error("Error pruning webhook deliveries", "error", err)
+		return
+	}
+	if removed > 0 {
+		slog.Info("Pruned webhook deliveries", "deliveries", removed, "older_than", d.retention.String())
+	}
+}
+
+func (d *Dispatcher) enqueue(j job) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed && j.attempt == 1 {
+		return false
+	}
+
+	d.pending.Add(1)
+	select {
+	case d.queue <- j:
+		return true
+	default:
+		d.pending.Done()
+		slog.Warn("Webhook queue full, dropping delivery", "event", j.event, "delivery_id", j.id, "url", j.webhook.URL)
+		return false
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.workers.Done()
+	for j := range d.queue {
+		d.mu.Lock()
+		d.lastTaken = time.Now()
+		d.mu.Unlock()
+		d.deliver(j)
+		d.pending.Done()
+	}
+}
+
+// deliver makes one delivery attempt and schedules a retry on failure
+func (d *Dispatcher) deliver(j job) {
+	start := time.Now()
+	statusCode, err := d.post(j)
+
+	delivery := models.WebhookDelivery{
+		WebhookID:  j.webhook.ID,
+		DeliveryID: j.id,
+		Event:      j.event,
+		Attempt:    j.attempt,
+		StatusCode: statusCode,
+		Success:    err == nil,
+		Duration:   time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	if logErr := d.store.LogWebhookDelivery(&delivery); logErr != nil {
+		slog.Error("Error logging webhook delivery", "delivery_id", j.id, "error", logErr)
+	}
+
+	if err == nil {
+		return
+	}
+	if !retryable(statusCode) || urlpolicy.IsViolation(err) || j.attempt >= maxAttempts || d.ctx.Err() != nil {
+		slog.Warn("Webhook delivery failed", "delivery_id", j.id, "url", j.webhook.URL, "attempts", j.attempt, "error", err)
+		return
+	}
+
+	// Hold the retry as pending so Close waits for it, and keep its timer
+	// so Close can cancel it
+	backoff := initialBackoff << (j.attempt - 1)
+	j.attempt++
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending.Add(1)
+	var timer *time.Timer
+	timer = time.AfterFunc(backoff, func() {
+		defer d.pending.Done()
+		d.mu.Lock()
+		delete(d.retries, timer)
+		d.mu.Unlock()
+		d.enqueue(j)
+	})
+	d.retries[timer] = j
+}
+
+func (d *Dispatcher) post(j job) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, j.webhook.URL, bytes.NewReader(j.body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "era-webhooks/1.0")
+	req.Header.Set(HeaderEvent, j.event)
+	req.Header.Set(HeaderDelivery, j.id)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(j.webhook.Secret, timestamp, j.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value for a payload: the hex HMAC-SHA256
+// of "<timestamp>.<body>" keyed with the webhook secret. Receivers recompute
+// it to verify the payload came from us and reject stale timestamps.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryable reports whether a failed attempt may succeed later. Network
+// errors (status 0), rate limiting and server errors are retried.
+func retryable(statusCode int) bool {
+	return statusCode == 0 || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// NewSecret generates a random webhook signing secret
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func newDeliveryID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate delivery ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}